@@ -12,8 +12,11 @@ import (
 	"github.com/stretchr/testify/assert"
 
 	"github.com/Fred78290/kubernetes-aws-autoscaler/aws"
+	"github.com/Fred78290/kubernetes-aws-autoscaler/aws/fake"
 	"github.com/Fred78290/kubernetes-aws-autoscaler/types"
 	"github.com/Fred78290/kubernetes-aws-autoscaler/utils"
+	awssdk "github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
 )
 
 type ConfigurationTest struct {
@@ -25,6 +28,7 @@ type ConfigurationTest struct {
 }
 
 var testConfig ConfigurationTest
+var fakeClients *fake.ClientProvider
 
 func getConfFile() string {
 	if config := os.Getenv("TEST_CONFIG"); config != "" {
@@ -34,10 +38,45 @@ func getConfFile() string {
 	return "../test/local_aws.json"
 }
 
+func newCreateInput(config *ConfigurationTest, nodeIndex int) *aws.CreateInput {
+	return &aws.CreateInput{
+		NodeIndex:    nodeIndex,
+		NodeGroup:    "test-aws-autoscaler",
+		InstanceType: config.InstanceType,
+		DiskType:     config.DiskType,
+		DiskSize:     config.DiskSize,
+	}
+}
+
+// newFakeConfiguration return a configuration backed by in-memory ec2 & route53 when no real config is provided
+func newFakeConfiguration() ConfigurationTest {
+	var conf *aws.Configuration
+
+	conf, fakeClients = fake.NewConfiguration("eu-west-1")
+
+	conf.IamRole = "arn:aws:iam::12345678:instance-profile/kubernetes-worker-profile"
+	conf.KeyName = "aws-k8s-key"
+	conf.DiskType = "gp3"
+	conf.DiskSize = 10
+	conf.Network.ZoneID = "Z0123456789"
+	conf.Network.PrivateZoneName = "aws.acme.com"
+
+	return ConfigurationTest{
+		Configuration: *conf,
+		InstanceName:  "test-kubernetes-aws-fake",
+		InstanceType:  "t3a.micro",
+		inited:        true,
+	}
+}
+
 func loadFromJson(fileName string) *ConfigurationTest {
 	if !testConfig.inited {
 		if configStr, err := os.ReadFile(fileName); err != nil {
-			glog.Fatalf("failed to open config file:%s, error:%v", fileName, err)
+			if os.Getenv("TEST_CONFIG") != "" {
+				glog.Fatalf("failed to open config file:%s, error:%v", fileName, err)
+			}
+
+			testConfig = newFakeConfiguration()
 		} else {
 			err = json.Unmarshal(configStr, &testConfig)
 
@@ -50,7 +89,23 @@ func loadFromJson(fileName string) *ConfigurationTest {
 	return &testConfig
 }
 
+// loadFakeConfiguration return the test configuration, tests relying on the in-memory backend are skipped with a real config
+func loadFakeConfiguration(t *testing.T) *ConfigurationTest {
+	config := loadFromJson(getConfFile())
+
+	if fakeClients == nil {
+		t.Skip("only with in-memory backend")
+	}
+
+	return config
+}
+
 func (config *ConfigurationTest) WaitSSHReady(nodename, address string) error {
+	// No ssh with in-memory backend
+	if fakeClients != nil {
+		return nil
+	}
+
 	return utils.PollImmediate(time.Second, time.Duration(config.SSH.WaitSshReadyInSeconds)*time.Second, func() (done bool, err error) {
 		// Set hostname
 		if _, err := utils.Sudo(&config.SSH, address, time.Second, fmt.Sprintf("hostnamectl set-hostname %s", nodename)); err != nil {
@@ -68,6 +123,10 @@ func Test_AuthMethodKey(t *testing.T) {
 	if utils.ShouldTestFeature("Test_AuthMethodKey") {
 		config := loadFromJson(getConfFile())
 
+		if fakeClients != nil {
+			t.Skip("ssh not available with in-memory backend")
+		}
+
 		_, err := utils.AuthMethodFromPrivateKeyFile(config.SSH.GetAuthKeys())
 
 		if assert.NoError(t, err) {
@@ -80,6 +139,10 @@ func Test_Sudo(t *testing.T) {
 	if utils.ShouldTestFeature("Test_Sudo") {
 		config := loadFromJson(getConfFile())
 
+		if fakeClients != nil {
+			t.Skip("ssh not available with in-memory backend")
+		}
+
 		out, err := utils.Sudo(&config.SSH, "localhost", 10, "ls")
 
 		if assert.NoError(t, err) {
@@ -112,7 +175,7 @@ func Test_createInstance(t *testing.T) {
 	if utils.ShouldTestFeature("Test_createInstance") {
 		config := loadFromJson(getConfFile())
 
-		_, err := config.Create(0, "test-aws-autoscaler", config.InstanceName, config.InstanceType, config.DiskType, config.DiskSize, nil, nil)
+		_, err := config.Create(config.InstanceName, newCreateInput(config, 0))
 
 		if assert.NoError(t, err, "Can't create VM") {
 			t.Logf("VM created")
@@ -243,3 +306,1246 @@ func Test_deleteInstance(t *testing.T) {
 		}
 	}
 }
+
+func Test_registerDNS(t *testing.T) {
+	if utils.ShouldTestFeature("Test_registerDNS") {
+		config := loadFakeConfiguration(t)
+
+		name := fmt.Sprintf("%s.%s", config.InstanceName, config.Network.PrivateZoneName)
+
+		if provider := config.GetDNSProvider(); assert.NotNil(t, provider, "Route53 provider expected") {
+			if err := provider.Register(name, "10.0.0.1", true); assert.NoError(t, err, "Can't register DNS") {
+				assert.Len(t, fakeClients.Route53.Zones[config.Network.ZoneID], 1)
+
+				if err = provider.Register(name, "2001:db8::1", true); assert.NoError(t, err, "Can't register AAAA") {
+					assert.Len(t, fakeClients.Route53.Zones[config.Network.ZoneID], 2)
+				}
+
+				if err = provider.Unregister(name, true); assert.NoError(t, err, "Can't unregister DNS") {
+					assert.Empty(t, fakeClients.Route53.Zones[config.Network.ZoneID])
+				}
+			}
+		}
+	}
+}
+
+func Test_createInstanceWithLaunchTemplate(t *testing.T) {
+	if utils.ShouldTestFeature("Test_createInstanceWithLaunchTemplate") {
+		config := loadFakeConfiguration(t)
+
+		fakeClients.EC2.LaunchTemplates["kubernetes-worker"] = &ec2.ResponseLaunchTemplateData{
+			TagSpecifications: []*ec2.LaunchTemplateTagSpecification{
+				{
+					ResourceType: awssdk.String(ec2.ResourceTypeInstance),
+					Tags: []*ec2.Tag{
+						{
+							Key:   awssdk.String("Name"),
+							Value: awssdk.String("template"),
+						},
+						{
+							Key:   awssdk.String("Hardened"),
+							Value: awssdk.String("true"),
+						},
+					},
+				},
+			},
+		}
+
+		templateConfig := config.Configuration
+		templateConfig.LaunchTemplate = &aws.LaunchTemplate{
+			Name: "kubernetes-worker",
+		}
+
+		instanceName := config.InstanceName + "-template"
+
+		if instance, err := templateConfig.Create(instanceName, newCreateInput(config, 1)); assert.NoError(t, err, "Can't create VM") {
+			ec2Instance := fakeClients.EC2.Instances[*instance.InstanceID]
+
+			assert.Nil(t, ec2Instance.ImageId, "AMI must come from launch template")
+			assert.Equal(t, "subnet-2", *ec2Instance.SubnetId)
+
+			tags := map[string]string{}
+
+			for _, tag := range ec2Instance.Tags {
+				tags[*tag.Key] = *tag.Value
+			}
+
+			assert.Equal(t, instanceName, tags["Name"])
+			assert.Equal(t, "true", tags["Hardened"])
+			assert.NoError(t, instance.Delete())
+		}
+	}
+}
+
+func Test_multiRegionIsolation(t *testing.T) {
+	if utils.ShouldTestFeature("Test_multiRegionIsolation") {
+		config := loadFakeConfiguration(t)
+
+		euConfig := config.Configuration
+		usConfig := config.Configuration
+		usConfig.Region = "us-east-1"
+
+		instanceName := config.InstanceName + "-region"
+
+		create := newCreateInput(config, 2)
+
+		if instance, err := euConfig.Create(instanceName, create); assert.NoError(t, err, "Can't create VM") {
+			assert.Equal(t, "eu-west-1", *instance.Region)
+			assert.True(t, euConfig.Exists(create.NodeGroup, instanceName))
+			assert.False(t, usConfig.Exists(create.NodeGroup, instanceName), "instance must not be visible from another region")
+			assert.Empty(t, fakeClients.GetRegionEC2("us-east-1").Instances)
+			assert.NoError(t, instance.Delete())
+		}
+	}
+}
+
+func Test_createInstanceWithBlockDevices(t *testing.T) {
+	if utils.ShouldTestFeature("Test_createInstanceWithBlockDevices") {
+		config := loadFakeConfiguration(t)
+
+		blockDevices := []aws.BlockDevice{
+			{
+				Encrypted: true,
+				KmsKeyID:  "arn:aws:kms:eu-west-1:12345678:key/root",
+			},
+			{
+				DeviceName: "/dev/xvdb",
+				VolumeType: "gp3",
+				VolumeSize: 100,
+				Iops:       4000,
+				Throughput: 250,
+			},
+		}
+
+		instanceName := config.InstanceName + "-volumes"
+
+		create := newCreateInput(config, 3)
+		create.DiskType = "gp3"
+		create.DiskSize = 20
+		create.BlockDevices = blockDevices
+
+		if instance, err := config.Create(instanceName, create); assert.NoError(t, err, "Can't create VM") {
+			mappings := fakeClients.EC2.BlockDevices[*instance.InstanceID]
+
+			if assert.Len(t, mappings, 2) {
+				root := mappings[0]
+				data := mappings[1]
+
+				assert.Equal(t, "/dev/xvda", *root.DeviceName, "root device must come from AMI")
+				assert.Equal(t, int64(20), *root.Ebs.VolumeSize)
+				assert.True(t, *root.Ebs.Encrypted)
+				assert.Equal(t, "arn:aws:kms:eu-west-1:12345678:key/root", *root.Ebs.KmsKeyId)
+
+				assert.Equal(t, "/dev/xvdb", *data.DeviceName)
+				assert.Equal(t, "gp3", *data.Ebs.VolumeType)
+				assert.Equal(t, int64(4000), *data.Ebs.Iops)
+				assert.Equal(t, int64(250), *data.Ebs.Throughput)
+				assert.Nil(t, data.Ebs.Encrypted)
+			}
+
+			assert.NoError(t, instance.Delete())
+		}
+
+		// Explicit root volume override the machine disk
+		create.BlockDevices = []aws.BlockDevice{
+			{
+				VolumeType: "io2",
+				VolumeSize: 50,
+			},
+		}
+
+		if instance, err := config.Create(instanceName+"-root", create); assert.NoError(t, err, "Can't create VM") {
+			if mappings := fakeClients.EC2.BlockDevices[*instance.InstanceID]; assert.Len(t, mappings, 1) {
+				assert.Equal(t, "io2", *mappings[0].Ebs.VolumeType)
+				assert.Equal(t, int64(50), *mappings[0].Ebs.VolumeSize)
+			}
+
+			assert.NoError(t, instance.Delete())
+		}
+
+		create = newCreateInput(config, 4)
+		create.BlockDevices = []aws.BlockDevice{
+			{
+				DeviceName: "/dev/xvdc",
+			},
+		}
+
+		_, err := config.Create(instanceName+"-invalid", create)
+
+		assert.Error(t, err, "volume without size must be rejected")
+	}
+}
+
+func Test_createInstanceInZone(t *testing.T) {
+	if utils.ShouldTestFeature("Test_createInstanceInZone") {
+		config := loadFakeConfiguration(t)
+
+		create := newCreateInput(config, 0)
+		create.Zone = "eu-west-1b"
+
+		if instance, err := config.Create(config.InstanceName+"-zone", create); assert.NoError(t, err, "Can't create VM") {
+			assert.Equal(t, "eu-west-1b", *instance.Zone)
+			assert.NoError(t, instance.Delete())
+		}
+
+		create.Zone = "eu-west-1c"
+
+		_, err := config.Create(config.InstanceName+"-nozone", create)
+
+		assert.Error(t, err, "zone without subnet must be rejected")
+	}
+}
+
+func Test_insufficientCapacity(t *testing.T) {
+	if utils.ShouldTestFeature("Test_insufficientCapacity") {
+		config := loadFakeConfiguration(t)
+
+		fakeClients.EC2.InsufficientCapacity["t3a.large"] = true
+		defer delete(fakeClients.EC2.InsufficientCapacity, "t3a.large")
+
+		create := newCreateInput(config, 0)
+		create.InstanceType = "t3a.large"
+
+		_, err := config.Create(config.InstanceName+"-capacity", create)
+
+		assert.True(t, aws.IsCapacityError(err), "expected capacity error, got: %v", err)
+		assert.False(t, aws.IsCapacityError(fmt.Errorf("another error")))
+	}
+}
+
+func Test_zoneBalancedSubnets(t *testing.T) {
+	if utils.ShouldTestFeature("Test_zoneBalancedSubnets") {
+		config := loadFakeConfiguration(t)
+
+		// Own region to not share zone cool-down with other tests
+		balancedConfig := config.Configuration
+		balancedConfig.Region = "eu-west-3"
+		balancedConfig.Network.ENI = []aws.NetworkInterface{
+			{
+				SubnetsID:       []string{"subnet-3a", "subnet-3b", "subnet-3c"},
+				SecurityGroupID: "sg-1234",
+			},
+		}
+
+		backend := fakeClients.GetRegionEC2(balancedConfig.Region)
+		backend.Images = fakeClients.EC2.Images
+
+		for _, zone := range []string{"a", "b", "c"} {
+			backend.Subnets["subnet-3"+zone] = &ec2.Subnet{
+				SubnetId:         awssdk.String("subnet-3" + zone),
+				AvailabilityZone: awssdk.String("eu-west-3" + zone),
+			}
+		}
+
+		create := newCreateInput(config, 0)
+		create.NodeGroup = "test-balanced"
+
+		// Same node index reused, modulo would always land in eu-west-3a
+		for index, expected := range []string{"eu-west-3a", "eu-west-3b", "eu-west-3c", "eu-west-3a"} {
+			if instance, err := balancedConfig.Create(fmt.Sprintf("%s-balanced-%d", config.InstanceName, index), create); assert.NoError(t, err, "Can't create VM") {
+				assert.Equal(t, expected, *instance.Zone)
+			}
+		}
+
+		// Nodes are counted from the instance cache, refreshed once
+		assert.Equal(t, 1, backend.DescribeInstancesCalls)
+
+		// Zone recently failed is skipped
+		backend.InsufficientCapacity[config.InstanceType+"/eu-west-3b"] = true
+
+		_, err := balancedConfig.Create(config.InstanceName+"-balanced-failed", create)
+
+		assert.True(t, aws.IsCapacityError(err))
+		assert.True(t, balancedConfig.IsZoneCoolingDown("eu-west-3b"))
+		assert.False(t, balancedConfig.IsZoneCoolingDown("eu-west-3c"))
+
+		if instance, err := balancedConfig.Create(config.InstanceName+"-balanced-cooldown", create); assert.NoError(t, err, "Can't create VM") {
+			assert.Equal(t, "eu-west-3c", *instance.Zone)
+		}
+	}
+}
+
+func Test_instanceCache(t *testing.T) {
+	if utils.ShouldTestFeature("Test_instanceCache") {
+		config := loadFakeConfiguration(t)
+
+		// Own region to count describe calls
+		cacheConfig := config.Configuration
+		cacheConfig.Region = "eu-central-1"
+
+		backend := fakeClients.GetRegionEC2(cacheConfig.Region)
+		backend.Images = fakeClients.EC2.Images
+		backend.Subnets = fakeClients.EC2.Subnets
+
+		create := newCreateInput(config, 0)
+		create.NodeGroup = "test-cache"
+		names := make([]string, 0, 3)
+
+		for index := 0; index < 3; index++ {
+			name := fmt.Sprintf("%s-cache-%d", config.InstanceName, index)
+			create.NodeIndex = index
+
+			if _, err := cacheConfig.Create(name, create); assert.NoError(t, err, "Can't create VM") {
+				names = append(names, name)
+			}
+		}
+
+		cache := aws.NewInstanceCache(&cacheConfig, create.NodeGroup)
+
+		assert.False(t, cache.IsFresh())
+
+		if assert.NoError(t, cache.Refresh()) {
+			assert.True(t, cache.IsFresh())
+
+			calls := backend.DescribeInstancesCalls
+
+			for _, name := range names {
+				assert.True(t, cache.Exists(name))
+
+				if instance, err := cache.GetEc2Instance(name); assert.NoError(t, err) {
+					if status, err := instance.Status(); assert.NoError(t, err) {
+						assert.True(t, status.Powered)
+					}
+				}
+			}
+
+			assert.Equal(t, calls, backend.DescribeInstancesCalls, "lookups must be served by the cache")
+
+			// Fresh cache is trusted for missing instances
+			assert.False(t, cache.Exists(config.InstanceName+"-cache-unknown"))
+			assert.Equal(t, calls, backend.DescribeInstancesCalls)
+
+			// Stale cache is refreshed once
+			cache = aws.NewInstanceCache(&cacheConfig, create.NodeGroup)
+
+			assert.True(t, cache.Exists(names[1]))
+			assert.False(t, cache.Exists(config.InstanceName+"-cache-unknown"))
+			assert.Equal(t, calls+1, backend.DescribeInstancesCalls)
+
+			// Power off invalidate the cached state
+			if instance, err := cache.GetEc2Instance(names[0]); assert.NoError(t, err) {
+				assert.NoError(t, instance.PowerOff())
+
+				if status, err := instance.Status(); assert.NoError(t, err) {
+					assert.False(t, status.Powered)
+				}
+
+				assert.True(t, cache.Exists(names[0]))
+			}
+
+			// Shared cache see created and deleted instances without refresh
+			shared := cacheConfig.GetInstanceCache(create.NodeGroup)
+			name := config.InstanceName + "-cache-created"
+
+			if assert.NoError(t, shared.Refresh()) {
+				if instance, err := cacheConfig.Create(name, create); assert.NoError(t, err, "Can't create VM") {
+					calls = backend.DescribeInstancesCalls
+
+					assert.True(t, shared.Exists(name))
+					assert.True(t, cacheConfig.Exists(create.NodeGroup, name))
+					assert.Equal(t, calls, backend.DescribeInstancesCalls, "existence checks must be served by the cache")
+
+					// Duplicate name refused
+					_, err = cacheConfig.Create(name, create)
+					assert.Error(t, err)
+
+					assert.NoError(t, instance.Delete())
+					assert.False(t, shared.Exists(name))
+				}
+			}
+		}
+	}
+}
+
+func Test_createDualStackInstance(t *testing.T) {
+	if utils.ShouldTestFeature("Test_createDualStackInstance") {
+		config := loadFakeConfiguration(t)
+
+		dualStackConfig := config.Configuration
+		dualStackConfig.Network.ENI = []aws.NetworkInterface{
+			{
+				SubnetsID:        []string{"subnet-1", "subnet-2"},
+				SecurityGroupID:  "sg-1234",
+				IPv6AddressCount: 1,
+				IPv6PrefixCount:  1,
+			},
+		}
+
+		assert.True(t, dualStackConfig.Network.IsDualStack())
+		assert.False(t, config.Network.IsDualStack())
+
+		if instance, err := dualStackConfig.Create(config.InstanceName+"-dualstack", newCreateInput(config, 0)); assert.NoError(t, err, "Can't create VM") {
+			if _, err = instance.WaitForIP(config); assert.NoError(t, err) && assert.NotNil(t, instance.AddressIPv6) {
+				assert.Contains(t, *instance.AddressIPv6, ":")
+			}
+
+			if status, err := instance.Status(); assert.NoError(t, err) {
+				assert.Equal(t, awssdk.StringValue(instance.AddressIPv6), status.AddressIPv6)
+			}
+
+			ec2Instance := fakeClients.EC2.Instances[*instance.InstanceID]
+
+			assert.Len(t, ec2Instance.NetworkInterfaces[0].Ipv6Prefixes, 1)
+			assert.NoError(t, instance.Delete())
+		}
+
+		// Requested IPv6 address
+		create := newCreateInput(config, 1)
+		create.DesiredENI = &aws.UserDefinedNetworkInterface{
+			SubnetID:    "subnet-2",
+			IPv6Address: "2001:db8:1234::10",
+		}
+
+		if instance, err := config.Create(config.InstanceName+"-ipv6address", create); assert.NoError(t, err, "Can't create VM") {
+			if assert.NotNil(t, instance.AddressIPv6) {
+				assert.Equal(t, "2001:db8:1234::10", *instance.AddressIPv6)
+			}
+
+			assert.NoError(t, instance.Delete())
+		}
+	}
+}
+
+func newInstanceTypeInfo(name string, vcpus, memory int64, arch string) *ec2.InstanceTypeInfo {
+	return &ec2.InstanceTypeInfo{
+		InstanceType: awssdk.String(name),
+		VCpuInfo: &ec2.VCpuInfo{
+			DefaultVCpus: awssdk.Int64(vcpus),
+		},
+		MemoryInfo: &ec2.MemoryInfo{
+			SizeInMiB: awssdk.Int64(memory),
+		},
+		ProcessorInfo: &ec2.ProcessorInfo{
+			SupportedArchitectures: []*string{awssdk.String(arch)},
+		},
+		NetworkInfo: &ec2.NetworkInfo{
+			MaximumNetworkInterfaces:  awssdk.Int64(3),
+			Ipv4AddressesPerInterface: awssdk.Int64(10),
+		},
+	}
+}
+
+func Test_discoverMachineTypes(t *testing.T) {
+	if utils.ShouldTestFeature("Test_discoverMachineTypes") {
+		config := loadFakeConfiguration(t)
+
+		// Own region to count describe calls
+		discoveryConfig := config.Configuration
+		discoveryConfig.Region = "ap-southeast-1"
+
+		backend := fakeClients.GetRegionEC2(discoveryConfig.Region)
+
+		gpu := newInstanceTypeInfo("g4dn.xlarge", 4, 16384, "x86_64")
+		gpu.GpuInfo = &ec2.GpuInfo{
+			Gpus: []*ec2.GpuDeviceInfo{{Count: awssdk.Int64(1)}},
+		}
+		gpu.InstanceStorageInfo = &ec2.InstanceStorageInfo{
+			TotalSizeInGB: awssdk.Int64(125),
+		}
+
+		backend.InstanceTypes["g4dn.xlarge"] = gpu
+		backend.InstanceTypes["m6g.large"] = newInstanceTypeInfo("m6g.large", 2, 8192, "arm64")
+		backend.InstanceTypes["m5.large"] = newInstanceTypeInfo("m5.large", 2, 8192, "x86_64")
+		backend.InstanceTypes["m5.xlarge"] = newInstanceTypeInfo("m5.xlarge", 4, 16384, "x86_64")
+		backend.InstanceTypes["t3a.micro"] = newInstanceTypeInfo("t3a.micro", 2, 1024, "x86_64")
+
+		discovery := &aws.MachineTypesDiscovery{
+			Enabled:   true,
+			Families:  []string{"m5", "m6g", "g4dn"},
+			Deny:      []string{"m5.x*"},
+			CacheFile: t.TempDir() + "/machines.json",
+		}
+
+		machineTypes, err := discoveryConfig.DiscoverMachineTypes(discovery)
+
+		if assert.NoError(t, err) {
+			assert.Len(t, machineTypes, 3)
+			assert.NotContains(t, machineTypes, "m5.xlarge")
+			assert.NotContains(t, machineTypes, "t3a.micro")
+
+			if machine := machineTypes["g4dn.xlarge"]; assert.NotNil(t, machine) {
+				assert.Equal(t, 4, machine.Vcpu)
+				assert.Equal(t, 16384, machine.Memory)
+				assert.Equal(t, 1, machine.GPU)
+				assert.Equal(t, 125, machine.InstanceStorage)
+				assert.Equal(t, 3, machine.MaxENI)
+				assert.Equal(t, 10, machine.MaxIPv4PerENI)
+			}
+
+			if machine := machineTypes["m6g.large"]; assert.NotNil(t, machine) {
+				assert.Equal(t, []string{"arm64"}, machine.Architectures)
+			}
+		}
+
+		// Served by the cache, filters are applied on cached types
+		calls := backend.DescribeInstanceTypesCalls
+		discovery.Allow = []string{"m*"}
+
+		if machineTypes, err = discoveryConfig.DiscoverMachineTypes(discovery); assert.NoError(t, err) {
+			assert.Len(t, machineTypes, 2)
+			assert.Equal(t, calls, backend.DescribeInstanceTypesCalls)
+		}
+
+		// Offline with a stale cache
+		var cache map[string]interface{}
+
+		content, _ := os.ReadFile(discovery.CacheFile)
+
+		if assert.NoError(t, json.Unmarshal(content, &cache)) {
+			cache["updatedAt"] = time.Now().Add(-48 * time.Hour)
+			content, _ = json.Marshal(cache)
+
+			assert.NoError(t, os.WriteFile(discovery.CacheFile, content, 0644))
+		}
+
+		backend.DescribeInstanceTypesError = fmt.Errorf("api unreachable")
+
+		if machineTypes, err = discoveryConfig.DiscoverMachineTypes(discovery); assert.NoError(t, err) {
+			assert.Len(t, machineTypes, 2)
+			assert.Greater(t, backend.DescribeInstanceTypesCalls, calls)
+		}
+
+		// Offline without cache
+		discovery.CacheFile = ""
+
+		_, err = discoveryConfig.DiscoverMachineTypes(discovery)
+
+		assert.Error(t, err)
+	}
+}
+
+func Test_pricingProvider(t *testing.T) {
+	if utils.ShouldTestFeature("Test_pricingProvider") {
+		config := loadFakeConfiguration(t)
+
+		catalogFile := t.TempDir() + "/prices.json"
+		catalog := aws.PriceCatalog{
+			config.Region: {
+				"t3a.medium": {OnDemand: 0.0408, Spot: 0.0135},
+				"m5.large":   {OnDemand: 0.107},
+			},
+		}
+
+		assert.NoError(t, catalog.Save(catalogFile))
+
+		options := &aws.PricingOptions{
+			CatalogFile: catalogFile,
+		}
+
+		provider, err := aws.NewPricingProvider(&config.Configuration, options)
+
+		if assert.NoError(t, err) {
+			price, found := provider.HourlyPrice("t3a.medium", false)
+
+			assert.True(t, found)
+			assert.Equal(t, 0.0408, price)
+
+			price, _ = provider.HourlyPrice("t3a.medium", true)
+			assert.Equal(t, 0.0135, price)
+
+			// Spot without spot price use on-demand price
+			price, _ = provider.HourlyPrice("m5.large", true)
+			assert.Equal(t, 0.107, price)
+
+			now := time.Now()
+			price, _ = provider.Price("m5.large", false, now, now.Add(2*time.Hour))
+			assert.InDelta(t, 0.214, price, 1e-9)
+
+			_, found = provider.HourlyPrice("c5.large", false)
+			assert.False(t, found)
+		}
+
+		// Refresh on-demand prices from the price list api
+		fakeClients.Pricing.SetPrice(config.Region, "t3a.medium", 0.0376)
+		fakeClients.Pricing.SetPrice(config.Region, "c5.large", 0.096)
+
+		options.RefreshFromAPI = true
+
+		if provider, err = aws.NewPricingProvider(&config.Configuration, options); assert.NoError(t, err) {
+			// Catalog price served until refreshed in background
+			price, _ := provider.HourlyPrice("t3a.medium", false)
+			assert.Equal(t, 0.0408, price)
+
+			assert.Eventually(t, func() bool {
+				price, _ := provider.HourlyPrice("t3a.medium", false)
+
+				return price == 0.0376
+			}, time.Second, 10*time.Millisecond)
+
+			price, _ = provider.HourlyPrice("t3a.medium", true)
+			assert.Equal(t, 0.0135, price, "spot price come from the catalog")
+
+			_, found := provider.HourlyPrice("c5.large", false)
+			assert.False(t, found, "price unknown until refreshed")
+
+			assert.Eventually(t, func() bool {
+				price, found := provider.HourlyPrice("c5.large", false)
+
+				return found && price == 0.096
+			}, time.Second, 10*time.Millisecond)
+
+			calls := fakeClients.Pricing.GetProductsCalls
+
+			provider.HourlyPrice("c5.large", false)
+			assert.Equal(t, calls, fakeClients.Pricing.GetProductsCalls, "price must not be refreshed before the interval")
+
+			// Refreshed prices are saved in catalog
+			assert.Eventually(t, func() bool {
+				saved, err := aws.LoadPriceCatalog(catalogFile)
+
+				return err == nil && saved[config.Region]["c5.large"] != nil && saved[config.Region]["c5.large"].OnDemand == 0.096
+			}, time.Second, 10*time.Millisecond)
+		}
+
+		// Unreachable api use the catalog
+		fakeClients.Pricing.Lock()
+		fakeClients.Pricing.GetProductsError = fmt.Errorf("api unreachable")
+		calls := fakeClients.Pricing.GetProductsCalls
+		fakeClients.Pricing.Unlock()
+
+		defer func() {
+			fakeClients.Pricing.Lock()
+			fakeClients.Pricing.GetProductsError = nil
+			fakeClients.Pricing.Unlock()
+		}()
+
+		if provider, err = aws.NewPricingProvider(&config.Configuration, options); assert.NoError(t, err) {
+			price, found := provider.HourlyPrice("m5.large", false)
+
+			assert.True(t, found)
+			assert.Equal(t, 0.107, price)
+
+			// Wait the failed refresh
+			assert.Eventually(t, func() bool {
+				fakeClients.Pricing.Lock()
+				defer fakeClients.Pricing.Unlock()
+
+				return fakeClients.Pricing.GetProductsCalls > calls
+			}, time.Second, 10*time.Millisecond)
+
+			price, _ = provider.HourlyPrice("m5.large", false)
+			assert.Equal(t, 0.107, price)
+		}
+	}
+}
+
+func Test_resolveImage(t *testing.T) {
+	if utils.ShouldTestFeature("Test_resolveImage") {
+		config := loadFakeConfiguration(t)
+
+		// Own region to isolate resolved images cache
+		imageConfig := config.Configuration
+		imageConfig.Region = "sa-east-1"
+		imageConfig.Images = map[string]*aws.ImageSelector{
+			"arm64": {
+				Owners: []string{"137112412989"},
+				Name:   "al2023-ami-*-arm64",
+			},
+			"x86_64": {
+				SSMParameter: "/aws/service/ami-amazon-linux-latest/al2023-ami-kernel-default-x86_64",
+			},
+		}
+
+		backend := fakeClients.GetRegionEC2(imageConfig.Region)
+		backend.Subnets = fakeClients.EC2.Subnets
+		backend.InstanceTypes["t4g.micro"] = newInstanceTypeInfo("t4g.micro", 2, 1024, "arm64")
+		backend.InstanceTypes["t3a.micro"] = newInstanceTypeInfo("t3a.micro", 2, 1024, "x86_64")
+
+		newImage := func(imageID, name, architecture, creationDate string) {
+			backend.Images[imageID] = &ec2.Image{
+				ImageId:        awssdk.String(imageID),
+				Name:           awssdk.String(name),
+				OwnerId:        awssdk.String("137112412989"),
+				Architecture:   awssdk.String(architecture),
+				State:          awssdk.String(ec2.ImageStateAvailable),
+				CreationDate:   awssdk.String(creationDate),
+				RootDeviceName: awssdk.String("/dev/xvda"),
+			}
+		}
+
+		newImage("ami-arm-old", "al2023-ami-2023.1.20230705.0-kernel-6.1-arm64", "arm64", "2023-07-05T00:00:00.000Z")
+		newImage("ami-arm-new", "al2023-ami-2023.2.20231002.0-kernel-6.1-arm64", "arm64", "2023-10-02T00:00:00.000Z")
+		newImage("ami-x86-old", "al2023-ami-2023.2.20231002.0-kernel-6.1-x86_64", "x86_64", "2023-10-02T00:00:00.000Z")
+		newImage("ami-x86-ssm", "al2023-ami-2023.2.20231016.0-kernel-6.1-x86_64", "x86_64", "2023-10-16T00:00:00.000Z")
+
+		fakeClients.SSM.Parameters["/aws/service/ami-amazon-linux-latest/al2023-ami-kernel-default-x86_64"] = "ami-x86-ssm"
+
+		create := newCreateInput(config, 0)
+		create.NodeGroup = "test-images"
+
+		launch := func(name, instanceType string) *aws.Ec2Instance {
+			create.InstanceType = instanceType
+
+			if instance, err := aws.NewEc2Instance(&imageConfig, name); assert.NoError(t, err) {
+				if assert.NoError(t, instance.Create(create)) {
+					return instance
+				}
+			}
+
+			return nil
+		}
+
+		if instance := launch(config.InstanceName+"-arm64", "t4g.micro"); instance != nil {
+			assert.Equal(t, "ami-arm-new", instance.AMI(), "newest image matching filters")
+
+			if described, err := aws.GetEc2Instance(&imageConfig, instance.InstanceName); assert.NoError(t, err) {
+				assert.Equal(t, "ami-arm-new", described.AMI())
+			}
+		}
+
+		if instance := launch(config.InstanceName+"-x86-1", "t3a.micro"); instance != nil {
+			assert.Equal(t, "ami-x86-ssm", instance.AMI(), "image from ssm parameter")
+		}
+
+		calls := fakeClients.SSM.GetParameterCalls
+
+		if instance := launch(config.InstanceName+"-x86-2", "t3a.micro"); instance != nil {
+			assert.Equal(t, "ami-x86-ssm", instance.AMI())
+			assert.Equal(t, calls, fakeClients.SSM.GetParameterCalls, "resolved image must be cached")
+		}
+
+		// Another node group of the region with its own selector
+		otherConfig := imageConfig
+		otherConfig.Images = map[string]*aws.ImageSelector{
+			"x86_64": {
+				SSMParameter: "/aws/service/canonical/ubuntu/server/jammy/stable/current/amd64/hvm/ebs-gp2/ami-id",
+			},
+		}
+
+		fakeClients.SSM.Parameters["/aws/service/canonical/ubuntu/server/jammy/stable/current/amd64/hvm/ebs-gp2/ami-id"] = "ami-x86-old"
+
+		if instance, err := aws.NewEc2Instance(&otherConfig, config.InstanceName+"-x86-3"); assert.NoError(t, err) {
+			// Unknown instance type of the backend, the architecture is given
+			create.InstanceType = "c7a.medium"
+			create.Architectures = []string{"x86_64"}
+
+			if assert.NoError(t, instance.Create(create)) {
+				assert.Equal(t, "ami-x86-old", instance.AMI(), "image of the node group selector")
+			}
+
+			create.Architectures = nil
+		}
+
+		if instance := launch(config.InstanceName+"-x86-4", "t3a.micro"); instance != nil {
+			assert.Equal(t, "ami-x86-ssm", instance.AMI(), "cached image of the first node group")
+		}
+	}
+}
+
+func Test_placementAndCapacityReservation(t *testing.T) {
+	if utils.ShouldTestFeature("Test_placementAndCapacityReservation") {
+		config := loadFakeConfiguration(t)
+
+		placementConfig := config.Configuration
+		placementConfig.Placement = &aws.PlacementOptions{
+			GroupName:       "hpc-cluster",
+			PartitionNumber: 2,
+			Tenancy:         ec2.TenancyDedicated,
+		}
+		placementConfig.CapacityReservation = &aws.CapacityReservationOptions{
+			ID: "cr-0123456789",
+		}
+
+		create := newCreateInput(config, 0)
+		create.NodeGroup = "test-placement"
+
+		launch := func(name string) (*ec2.Instance, error) {
+			instance, err := aws.NewEc2Instance(&placementConfig, name)
+
+			if err == nil {
+				if err = instance.Create(create); err == nil {
+					return fakeClients.EC2.Instances[*instance.InstanceID], nil
+				}
+			}
+
+			return nil, err
+		}
+
+		if ec2Instance, err := launch(config.InstanceName + "-placement-1"); assert.NoError(t, err) {
+			assert.Equal(t, "hpc-cluster", awssdk.StringValue(ec2Instance.Placement.GroupName))
+			assert.Equal(t, int64(2), awssdk.Int64Value(ec2Instance.Placement.PartitionNumber))
+			assert.Equal(t, ec2.TenancyDedicated, awssdk.StringValue(ec2Instance.Placement.Tenancy))
+			assert.Equal(t, "cr-0123456789", awssdk.StringValue(ec2Instance.CapacityReservationId))
+		}
+
+		// Exhausted reservation fallback to open preference
+		fakeClients.EC2.ExhaustedReservations["cr-0123456789"] = true
+
+		if ec2Instance, err := launch(config.InstanceName + "-placement-2"); assert.NoError(t, err) {
+			assert.Nil(t, ec2Instance.CapacityReservationId)
+			assert.Equal(t, "hpc-cluster", awssdk.StringValue(ec2Instance.Placement.GroupName))
+		}
+
+		// Without fallback the launch fail
+		placementConfig.CapacityReservation.DisableFallback = true
+
+		_, err := launch(config.InstanceName + "-placement-3")
+
+		assert.True(t, aws.IsReservationExhaustedError(err))
+
+		// Spot instances don't target reservations
+		placementConfig.CapacityReservation.DisableFallback = false
+		create.Spot = &aws.SpotOptions{Enabled: true}
+
+		if ec2Instance, err := launch(config.InstanceName + "-placement-4"); assert.NoError(t, err) {
+			assert.Nil(t, ec2Instance.CapacityReservationId)
+		}
+	}
+}
+
+func Test_tagPropagation(t *testing.T) {
+	if utils.ShouldTestFeature("Test_tagPropagation") {
+		config := loadFakeConfiguration(t)
+
+		tagsConfig := config.Configuration
+		tagsConfig.ClusterName = "acme"
+		tagsConfig.PropagateTags = true
+		tagsConfig.Tags = []aws.Tag{
+			{Key: "CostCenter", Value: "k8s"},
+			{Key: "Owner", Value: "{{ .ClusterName }}/{{ .NodeGroup }}/{{ .NodeName }}-{{ .NodeIndex }}/{{ .MachineType }}"},
+		}
+
+		tagValues := func(tags []*ec2.Tag) map[string]string {
+			values := make(map[string]string)
+
+			for _, tag := range tags {
+				values[awssdk.StringValue(tag.Key)] = awssdk.StringValue(tag.Value)
+			}
+
+			return values
+		}
+
+		create := newCreateInput(config, 2)
+		create.NodeGroup = "test-tags"
+
+		instance, err := aws.NewEc2Instance(&tagsConfig, config.InstanceName+"-tags")
+
+		if assert.NoError(t, err) && assert.NoError(t, instance.Create(create)) {
+			ec2Instance := fakeClients.EC2.Instances[*instance.InstanceID]
+			owner := fmt.Sprintf("acme/test-tags/%s-tags-2/%s", config.InstanceName, config.InstanceType)
+
+			tags := tagValues(ec2Instance.Tags)
+
+			assert.Equal(t, "k8s", tags["CostCenter"])
+			assert.Equal(t, owner, tags["Owner"])
+			assert.Equal(t, "owned", tags["kubernetes.io/cluster/acme"])
+
+			// Volumes and network interfaces get the same tags
+			for _, mapping := range ec2Instance.BlockDeviceMappings {
+				assert.Equal(t, owner, tagValues(fakeClients.EC2.ResourceTags[*mapping.Ebs.VolumeId])["Owner"])
+			}
+
+			for _, inf := range ec2Instance.NetworkInterfaces {
+				assert.Equal(t, owner, tagValues(fakeClients.EC2.ResourceTags[*inf.NetworkInterfaceId])["Owner"])
+			}
+
+			// Node labels copied after join
+			if assert.NoError(t, instance.TagResources(map[string]string{"topology.kubernetes.io/zone": "eu-west-1a"})) {
+				assert.Equal(t, "eu-west-1a", tagValues(ec2Instance.Tags)["topology.kubernetes.io/zone"])
+
+				for _, inf := range ec2Instance.NetworkInterfaces {
+					assert.Equal(t, "eu-west-1a", tagValues(fakeClients.EC2.ResourceTags[*inf.NetworkInterfaceId])["topology.kubernetes.io/zone"])
+				}
+			}
+		}
+
+		// Invalid template
+		tagsConfig.Tags = []aws.Tag{
+			{Key: "Owner", Value: "{{ .Unknown }}"},
+		}
+
+		instance, err = aws.NewEc2Instance(&tagsConfig, config.InstanceName+"-tags-invalid")
+
+		if assert.NoError(t, err) {
+			assert.Error(t, instance.Create(create))
+		}
+
+		// No cluster tag without cluster name
+		tagsConfig.ClusterName = ""
+		tagsConfig.Tags = []aws.Tag{
+			{Key: "Owner", Value: "{{ .ClusterName }}"},
+		}
+
+		instance, err = aws.NewEc2Instance(&tagsConfig, config.InstanceName+"-tags-nocluster")
+
+		if assert.NoError(t, err) && assert.NoError(t, instance.Create(create)) {
+			tags := tagValues(fakeClients.EC2.Instances[*instance.InstanceID].Tags)
+
+			assert.Equal(t, "test-tags", tags["Owner"])
+
+			for key := range tags {
+				assert.NotContains(t, key, "kubernetes.io/cluster/")
+			}
+		}
+	}
+}
+
+func Test_metadataOptions(t *testing.T) {
+	if utils.ShouldTestFeature("Test_metadataOptions") {
+		config := loadFakeConfiguration(t)
+
+		metadataConfig := config.Configuration
+		metadataConfig.MetadataOptions = &aws.MetadataOptions{
+			HTTPTokens:              ec2.HttpTokensStateRequired,
+			HTTPPutResponseHopLimit: 2,
+			HTTPEndpoint:            ec2.InstanceMetadataEndpointStateEnabled,
+			InstanceMetadataTags:    ec2.InstanceMetadataTagsStateEnabled,
+		}
+
+		instance, err := aws.NewEc2Instance(&metadataConfig, config.InstanceName+"-imdsv2")
+
+		if assert.NoError(t, err) && assert.NoError(t, instance.Create(newCreateInput(config, 0))) {
+			options := fakeClients.EC2.Instances[*instance.InstanceID].MetadataOptions
+
+			if assert.NotNil(t, options) {
+				assert.Equal(t, ec2.HttpTokensStateRequired, awssdk.StringValue(options.HttpTokens))
+				assert.Equal(t, int64(2), awssdk.Int64Value(options.HttpPutResponseHopLimit))
+				assert.Equal(t, ec2.InstanceMetadataEndpointStateEnabled, awssdk.StringValue(options.HttpEndpoint))
+				assert.Equal(t, ec2.InstanceMetadataTagsStateEnabled, awssdk.StringValue(options.InstanceMetadataTags))
+			}
+		}
+
+		// Without options, instance use the account defaults
+		instance, err = aws.NewEc2Instance(&config.Configuration, config.InstanceName+"-imds-default")
+
+		if assert.NoError(t, err) && assert.NoError(t, instance.Create(newCreateInput(config, 1))) {
+			assert.Nil(t, fakeClients.EC2.Instances[*instance.InstanceID].MetadataOptions)
+		}
+	}
+}
+
+func Test_networkDiscovery(t *testing.T) {
+	if utils.ShouldTestFeature("Test_networkDiscovery") {
+		config := loadFakeConfiguration(t)
+
+		// Own region to not share discovered subnets with other tests
+		networkConfig := config.Configuration
+		networkConfig.Region = "ap-northeast-1"
+		networkConfig.Network.ENI = []aws.NetworkInterface{
+			{
+				SubnetSelector: map[string]string{
+					"kubernetes.io/role/internal-elb": "",
+					"cluster":                         "acme",
+				},
+				SecurityGroupSelector: map[string]string{
+					"cluster": "acme",
+				},
+				MinFreeIPAddresses: 8,
+				VpcID:              "vpc-acme",
+			},
+		}
+
+		backend := fakeClients.GetRegionEC2(networkConfig.Region)
+		backend.Images = fakeClients.EC2.Images
+
+		subnetTags := []*ec2.Tag{
+			{Key: awssdk.String("kubernetes.io/role/internal-elb"), Value: awssdk.String("1")},
+			{Key: awssdk.String("cluster"), Value: awssdk.String("acme")},
+		}
+
+		for subnetID, freeIPAddresses := range map[string]int64{"subnet-full": 2, "subnet-c": 250, "subnet-a": 100} {
+			backend.Subnets[subnetID] = &ec2.Subnet{
+				SubnetId:                awssdk.String(subnetID),
+				AvailabilityZone:        awssdk.String("ap-northeast-1a"),
+				AvailableIpAddressCount: awssdk.Int64(freeIPAddresses),
+				State:                   awssdk.String(ec2.SubnetStateAvailable),
+				VpcId:                   awssdk.String("vpc-acme"),
+				Tags:                    subnetTags,
+			}
+		}
+
+		// Same tags in another vpc
+		backend.Subnets["subnet-b"] = &ec2.Subnet{
+			SubnetId:                awssdk.String("subnet-b"),
+			AvailabilityZone:        awssdk.String("ap-northeast-1a"),
+			AvailableIpAddressCount: awssdk.Int64(250),
+			State:                   awssdk.String(ec2.SubnetStateAvailable),
+			VpcId:                   awssdk.String("vpc-other"),
+			Tags:                    subnetTags,
+		}
+
+		// Not tagged for the cluster
+		backend.Subnets["subnet-other"] = &ec2.Subnet{
+			SubnetId:                awssdk.String("subnet-other"),
+			AvailabilityZone:        awssdk.String("ap-northeast-1a"),
+			AvailableIpAddressCount: awssdk.Int64(250),
+			State:                   awssdk.String(ec2.SubnetStateAvailable),
+			VpcId:                   awssdk.String("vpc-acme"),
+			Tags:                    subnetTags[:1],
+		}
+
+		for vpcID, groupID := range map[string]string{"vpc-acme": "sg-acme", "vpc-other": "sg-other"} {
+			backend.SecurityGroups[groupID] = &ec2.SecurityGroup{
+				GroupId: awssdk.String(groupID),
+				VpcId:   awssdk.String(vpcID),
+				Tags: []*ec2.Tag{
+					{Key: awssdk.String("cluster"), Value: awssdk.String("acme")},
+				},
+			}
+		}
+
+		create := newCreateInput(config, 0)
+		create.NodeGroup = "test-network"
+
+		// Subnets sorted by ID, node index select the subnet
+		for index, expected := range []string{"subnet-a", "subnet-c"} {
+			create.NodeIndex = index
+
+			if instance, err := networkConfig.Create(fmt.Sprintf("%s-network-%d", config.InstanceName, index), create); assert.NoError(t, err, "Can't create VM") {
+				inf := backend.Instances[*instance.InstanceID].NetworkInterfaces[0]
+
+				assert.Equal(t, expected, awssdk.StringValue(inf.SubnetId))
+
+				if assert.Len(t, inf.Groups, 1) {
+					assert.Equal(t, "sg-acme", awssdk.StringValue(inf.Groups[0].GroupId))
+				}
+			}
+		}
+
+		// Discovered security groups are cached
+		assert.Equal(t, 1, backend.DescribeSecurityGroupsCalls)
+
+		// Cached subnet exhausted since discovery, the next one is chosen
+		backend.Subnets["subnet-a"].AvailableIpAddressCount = awssdk.Int64(2)
+		create.NodeIndex = 0
+
+		if instance, err := networkConfig.Create(config.InstanceName+"-network-exhausted", create); assert.NoError(t, err, "Can't create VM") {
+			assert.Equal(t, "subnet-c", awssdk.StringValue(backend.Instances[*instance.InstanceID].NetworkInterfaces[0].SubnetId))
+		}
+
+		// Vpc of the declared security group
+		derivedConfig := networkConfig
+		derivedConfig.Network.ENI = []aws.NetworkInterface{
+			{
+				SubnetSelector:  networkConfig.Network.ENI[0].SubnetSelector,
+				SecurityGroupID: "sg-other",
+			},
+		}
+
+		if instance, err := derivedConfig.Create(config.InstanceName+"-network-derived", create); assert.NoError(t, err, "Can't create VM") {
+			assert.Equal(t, "subnet-b", awssdk.StringValue(backend.Instances[*instance.InstanceID].NetworkInterfaces[0].SubnetId))
+		}
+
+		// Vpc unknown
+		derivedConfig.Network.ENI[0].SecurityGroupID = ""
+
+		_, err := derivedConfig.Create(config.InstanceName+"-network-novpc", create)
+
+		assert.Error(t, err)
+
+		// No security group match the selector
+		networkConfig.Network.ENI[0].SecurityGroupSelector = map[string]string{
+			"cluster": "unknown",
+		}
+
+		_, err = networkConfig.Create(config.InstanceName+"-network-nosg", create)
+
+		assert.Error(t, err)
+	}
+}
+
+func Test_targetGroups(t *testing.T) {
+	if utils.ShouldTestFeature("Test_targetGroups") {
+		config := loadFakeConfiguration(t)
+
+		nlbARN := "arn:aws:elasticloadbalancing:eu-west-1:123456789012:targetgroup/nodeport/0123456789abcdef"
+
+		fakeClients.ELBv2.AddTargetGroup(nlbARN)
+		fakeClients.ELBv2.DrainingPolls = 1
+
+		targetConfig := config.Configuration
+		targetConfig.DeregistrationDelay = 10
+		targetConfig.TargetGroups = []aws.TargetGroup{
+			{ARN: nlbARN, Port: 30080},
+		}
+
+		instance, err := aws.NewEc2Instance(&targetConfig, config.InstanceName+"-targets")
+
+		if assert.NoError(t, err) && assert.NoError(t, instance.Create(newCreateInput(config, 0))) {
+			if assert.NoError(t, instance.RegisterTargets()) && assert.Len(t, fakeClients.ELBv2.Targets[nlbARN], 1) {
+				target := fakeClients.ELBv2.Targets[nlbARN][0]
+
+				assert.Equal(t, *instance.InstanceID, awssdk.StringValue(target.Id))
+				assert.Equal(t, int64(30080), awssdk.Int64Value(target.Port))
+			}
+
+			// Wait the target is no more draining
+			assert.NoError(t, instance.DeregisterTargets())
+			assert.Empty(t, fakeClients.ELBv2.Targets[nlbARN])
+		}
+
+		// Unknown target group
+		targetConfig.TargetGroups = []aws.TargetGroup{
+			{ARN: nlbARN + "-unknown"},
+		}
+
+		assert.Error(t, instance.RegisterTargets())
+	}
+}
+
+func Test_interruptionQueue(t *testing.T) {
+	if utils.ShouldTestFeature("Test_interruptionQueue") {
+		config := loadFakeConfiguration(t)
+
+		queueURL := "https://sqs.eu-west-1.amazonaws.com/123456789012/interruptions"
+
+		queueConfig := config.Configuration
+		queueConfig.InterruptionQueue = &aws.InterruptionQueueOptions{
+			URL: queueURL,
+		}
+
+		fakeClients.SQS.AddQueue(queueURL)
+		fakeClients.SQS.PushMessage(queueURL, `{"detail-type":"EC2 Spot Instance Interruption Warning","source":"aws.ec2","time":"2023-05-01T10:00:00Z","detail":{"instance-id":"i-0123456789abcdef0","instance-action":"terminate"}}`)
+		fakeClients.SQS.PushMessage(queueURL, `{"detail-type":"EC2 Instance Rebalance Recommendation","source":"aws.ec2","time":"2023-05-01T10:00:00Z","detail":{"instance-id":"i-0123456789abcdef1"}}`)
+		fakeClients.SQS.PushMessage(queueURL, `{"detail-type":"EC2 Instance State-change Notification","source":"aws.ec2","detail":{"instance-id":"i-0123456789abcdef2","state":"running"}}`)
+		fakeClients.SQS.PushMessage(queueURL, `not a json event`)
+
+		if events, err := queueConfig.ReceiveInterruptionEvents(); assert.NoError(t, err) && assert.Len(t, events, 2) {
+			assert.Equal(t, aws.SpotInterruptionWarning, events[0].Kind)
+			assert.Equal(t, "i-0123456789abcdef0", events[0].InstanceID)
+			assert.Equal(t, aws.RebalanceRecommendation, events[1].Kind)
+			assert.Equal(t, "i-0123456789abcdef1", events[1].InstanceID)
+
+			// Other messages are deleted
+			assert.Len(t, fakeClients.SQS.InFlight, 2)
+
+			for _, event := range events {
+				assert.NoError(t, queueConfig.DeleteInterruptionEvent(event))
+			}
+
+			assert.Empty(t, fakeClients.SQS.InFlight)
+			assert.Empty(t, fakeClients.SQS.Queues[queueURL])
+		}
+	}
+}
+
+func Test_scheduledEvents(t *testing.T) {
+	if utils.ShouldTestFeature("Test_scheduledEvents") {
+		config := loadFakeConfiguration(t)
+
+		instance, err := aws.NewEc2Instance(&config.Configuration, config.InstanceName+"-events")
+
+		if assert.NoError(t, err) && assert.NoError(t, instance.Create(newCreateInput(config, 0))) {
+			reboot := time.Now().Add(24 * time.Hour)
+			retirement := time.Now().Add(72 * time.Hour)
+
+			fakeClients.EC2.ScheduledEvents[*instance.InstanceID] = []*ec2.InstanceStatusEvent{
+				{
+					Code:      awssdk.String(ec2.EventCodeInstanceRetirement),
+					NotBefore: awssdk.Time(retirement),
+				},
+				{
+					Code:        awssdk.String(ec2.EventCodeSystemMaintenance),
+					Description: awssdk.String("[Canceled] Scheduled maintenance"),
+					NotBefore:   awssdk.Time(time.Now()),
+				},
+				{
+					Code:      awssdk.String(ec2.EventCodeSystemReboot),
+					NotBefore: awssdk.Time(reboot),
+				},
+			}
+
+			// Earliest event not canceled
+			if events, err := config.DescribeScheduledEvents([]string{*instance.InstanceID}); assert.NoError(t, err) && assert.Len(t, events, 1) {
+				event := events[*instance.InstanceID]
+
+				assert.Equal(t, ec2.EventCodeSystemReboot, event.Code)
+				assert.True(t, reboot.Equal(event.NotBefore))
+			}
+
+			delete(fakeClients.EC2.ScheduledEvents, *instance.InstanceID)
+		}
+	}
+}
+
+func Test_remoteExec(t *testing.T) {
+	if utils.ShouldTestFeature("Test_remoteExec") {
+		config := loadFakeConfiguration(t)
+
+		remoteConfig := config.Configuration
+		remoteConfig.RemoteExec = &aws.RemoteExecOptions{
+			Transport:    aws.RemoteTransportSSM,
+			PollInterval: 10,
+		}
+
+		instance, err := aws.NewEc2Instance(&remoteConfig, config.InstanceName+"-remote")
+
+		if assert.NoError(t, err) && assert.NoError(t, instance.Create(newCreateInput(config, 0))) {
+			instanceID := *instance.InstanceID
+
+			fakeClients.SSM.Offline[instanceID] = true
+
+			managed, err := instance.IsManaged()
+			assert.NoError(t, err)
+			assert.False(t, managed)
+
+			delete(fakeClients.SSM.Offline, instanceID)
+
+			managed, err = instance.IsManaged()
+			assert.NoError(t, err)
+			assert.True(t, managed)
+
+			fakeClients.SSM.CommandHandler = func(instanceID string, commands []string) (string, error) {
+				if strings.Contains(strings.Join(commands, ";"), "false") {
+					return "", fmt.Errorf("exit status 1")
+				}
+
+				return "ip-10-0-0-1.eu-west-1.compute.internal\n", nil
+			}
+
+			defer func() {
+				fakeClients.SSM.CommandHandler = nil
+			}()
+
+			// Commands stop at the first failure
+			if out, err := instance.RunCommand("hostname"); assert.NoError(t, err) {
+				assert.Equal(t, "ip-10-0-0-1.eu-west-1.compute.internal", out)
+				assert.Equal(t, []string{"set -e", "hostname"}, fakeClients.SSM.Commands[instanceID][0])
+			}
+
+			_, err = instance.RunCommand("false")
+			assert.Error(t, err)
+
+			// Command completion is polled
+			fakeClients.SSM.InProgressPolls = 2
+			calls := fakeClients.SSM.GetCommandInvocationCalls
+
+			if out, err := instance.RunCommand("hostname"); assert.NoError(t, err) {
+				assert.Equal(t, "ip-10-0-0-1.eu-west-1.compute.internal", out)
+				assert.Equal(t, calls+3, fakeClients.SSM.GetCommandInvocationCalls)
+			}
+
+			fakeClients.SSM.InProgressPolls = 0
+
+			// Small archive in a standard SSM parameter
+			parameter := fmt.Sprintf("/kubernetes-aws-autoscaler/%s/pki.tgz", instanceID)
+
+			if fetch, err := instance.StageArchive("pki.tgz", []byte("archive"), "/tmp/pki.tgz"); assert.NoError(t, err) {
+				assert.Contains(t, fetch, parameter)
+				assert.Equal(t, "Standard", fakeClients.SSM.ParameterTiers[parameter])
+				assert.NoError(t, instance.UnstageArchive("pki.tgz"))
+				assert.NotContains(t, fakeClients.SSM.Parameters, parameter)
+			}
+
+			// Larger archive in an advanced SSM parameter, too large archive require a bucket
+			if _, err := instance.StageArchive("pki.tgz", make([]byte, 4096), "/tmp/pki.tgz"); assert.NoError(t, err) {
+				assert.Equal(t, "Advanced", fakeClients.SSM.ParameterTiers[parameter])
+				assert.NoError(t, instance.UnstageArchive("pki.tgz"))
+			}
+
+			_, err = instance.StageArchive("pki.tgz", make([]byte, 8192), "/tmp/pki.tgz")
+			assert.Error(t, err)
+
+			remoteConfig.RemoteExec.Bucket = "bootstrap"
+
+			if fetch, err := instance.StageArchive("pki.tgz", make([]byte, 8192), "/tmp/pki.tgz"); assert.NoError(t, err) {
+				object := fmt.Sprintf("bootstrap/kubernetes-aws-autoscaler/%s/pki.tgz", instanceID)
+
+				assert.Contains(t, fetch, "s3://"+object)
+				assert.Len(t, fakeClients.S3.Objects[object], 8192)
+				assert.NoError(t, instance.UnstageArchive("pki.tgz"))
+				assert.NotContains(t, fakeClients.S3.Objects, object)
+			}
+		}
+	}
+}
@@ -0,0 +1,219 @@
+package aws
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Fred78290/kubernetes-aws-autoscaler/constantes"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	glog "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultBreakerThreshold = 5
+	defaultBreakerCoolDown  = 60
+
+	// ErrCodeCircuitBreakerOpen error code returned while the circuit breaker is open
+	ErrCodeCircuitBreakerOpen = "CircuitBreakerOpen"
+)
+
+// CircuitBreakerState state of the circuit breaker
+type CircuitBreakerState int
+
+const (
+	// CircuitBreakerClosed calls are allowed
+	CircuitBreakerClosed CircuitBreakerState = iota
+
+	// CircuitBreakerOpen calls fail fast
+	CircuitBreakerOpen
+
+	// CircuitBreakerHalfOpen one call is allowed to probe the api
+	CircuitBreakerHalfOpen
+)
+
+var circuitBreakerStateString = []string{
+	"closed",
+	"open",
+	"half-open",
+}
+
+func (s CircuitBreakerState) String() string {
+	return circuitBreakerStateString[s]
+}
+
+// circuitBreakers registry of circuit breaker by region
+var circuitBreakers sync.Map
+
+// CircuitBreaker open after consecutive throttling or server errors of a region
+// and close when a probe call succeed after the cool-down
+type CircuitBreaker struct {
+	sync.Mutex
+	region    string
+	threshold int
+	coolDown  time.Duration
+	failures  int
+	state     CircuitBreakerState
+	openedAt  time.Time
+	probing   bool
+}
+
+// GetBreakerThreshold return the number of consecutive failures opening the circuit breaker
+func (options *RetryOptions) GetBreakerThreshold() int {
+	if options == nil || options.BreakerThreshold <= 0 {
+		return defaultBreakerThreshold
+	}
+
+	return options.BreakerThreshold
+}
+
+// GetBreakerCoolDown return the duration the circuit breaker stay open
+func (options *RetryOptions) GetBreakerCoolDown() time.Duration {
+	if options == nil || options.BreakerCoolDown <= 0 {
+		return defaultBreakerCoolDown * time.Second
+	}
+
+	return options.BreakerCoolDown * time.Second
+}
+
+// GetCircuitBreaker return the circuit breaker of the configuration region
+func (conf *Configuration) GetCircuitBreaker() *CircuitBreaker {
+	return conf.getRegionCircuitBreaker(conf.Region)
+}
+
+// getRegionCircuitBreaker return the circuit breaker of a region, used by clients not served by the configuration region
+func (conf *Configuration) getRegionCircuitBreaker(region string) *CircuitBreaker {
+	breaker, _ := circuitBreakers.LoadOrStore(region, &CircuitBreaker{
+		region:    region,
+		threshold: conf.Retry.GetBreakerThreshold(),
+		coolDown:  conf.Retry.GetBreakerCoolDown(),
+	})
+
+	return breaker.(*CircuitBreaker)
+}
+
+func (b *CircuitBreaker) setState(state CircuitBreakerState) {
+	if b.state != state {
+		glog.Warnf(constantes.WarnCircuitBreakerStateChanged, b.region, b.state, state)
+
+		b.state = state
+	}
+}
+
+// State return the current state
+func (b *CircuitBreaker) State() CircuitBreakerState {
+	b.Lock()
+	defer b.Unlock()
+
+	return b.state
+}
+
+// IsOpen return true while the cool-down is not elapsed or a probe call is pending
+func (b *CircuitBreaker) IsOpen() bool {
+	b.Lock()
+	defer b.Unlock()
+
+	return b.isOpen()
+}
+
+func (b *CircuitBreaker) isOpen() bool {
+	switch b.state {
+	case CircuitBreakerOpen:
+		return time.Since(b.openedAt) < b.coolDown
+	case CircuitBreakerHalfOpen:
+		return b.probing
+	default:
+		return false
+	}
+}
+
+// Check return an error if the api is considered unhealthy
+func (b *CircuitBreaker) Check() error {
+	if b.IsOpen() {
+		return awserr.New(ErrCodeCircuitBreakerOpen, fmt.Sprintf(constantes.ErrCircuitBreakerOpen, b.region), nil)
+	}
+
+	return nil
+}
+
+// Allow return an error while open, after the cool-down only one probe call is allowed until its outcome is recorded
+func (b *CircuitBreaker) Allow() error {
+	b.Lock()
+	defer b.Unlock()
+
+	if b.isOpen() {
+		return awserr.New(ErrCodeCircuitBreakerOpen, fmt.Sprintf(constantes.ErrCircuitBreakerOpen, b.region), nil)
+	}
+
+	if b.state != CircuitBreakerClosed {
+		b.probing = true
+		b.setState(CircuitBreakerHalfOpen)
+	}
+
+	return nil
+}
+
+// Success close the circuit breaker
+func (b *CircuitBreaker) Success() {
+	b.Lock()
+	defer b.Unlock()
+
+	b.failures = 0
+	b.probing = false
+	b.setState(CircuitBreakerClosed)
+}
+
+// Failure open the circuit breaker after consecutive failures or a failed probe
+func (b *CircuitBreaker) Failure() {
+	b.Lock()
+	defer b.Unlock()
+
+	b.failures++
+	b.probing = false
+
+	if b.state == CircuitBreakerHalfOpen || b.failures >= b.threshold {
+		b.openedAt = time.Now()
+		b.setState(CircuitBreakerOpen)
+	}
+}
+
+// isUnhealthyRequest return true if the request failed because of throttling, server or network error
+func isUnhealthyRequest(req *request.Request) bool {
+	if req.Error == nil {
+		return false
+	}
+
+	if req.IsErrorThrottle() || req.IsErrorRetryable() {
+		return true
+	}
+
+	return req.HTTPResponse != nil && req.HTTPResponse.StatusCode >= 500
+}
+
+// installCircuitBreaker fail fast requests while open and record the outcome of completed requests
+func installCircuitBreaker(handlers *request.Handlers, breaker *CircuitBreaker) {
+	handlers.Validate.PushFrontNamed(request.NamedHandler{
+		Name: "autoscaler.CircuitBreakerAllow",
+		Fn: func(req *request.Request) {
+			if err := breaker.Allow(); err != nil {
+				req.Error = err
+			}
+		},
+	})
+
+	handlers.Complete.PushBackNamed(request.NamedHandler{
+		Name: "autoscaler.CircuitBreakerRecord",
+		Fn: func(req *request.Request) {
+			if aerr, ok := req.Error.(awserr.Error); ok && aerr.Code() == ErrCodeCircuitBreakerOpen {
+				return
+			}
+
+			if isUnhealthyRequest(req) {
+				breaker.Failure()
+			} else {
+				breaker.Success()
+			}
+		},
+	})
+}
@@ -0,0 +1,366 @@
+package aws
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Fred78290/kubernetes-aws-autoscaler/constantes"
+	"github.com/Fred78290/kubernetes-aws-autoscaler/context"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	glog "github.com/sirupsen/logrus"
+)
+
+// defaultInstanceCacheTTL is the time in seconds the instance cache is used after a refresh
+const defaultInstanceCacheTTL = 30
+
+// describeInstancesMaxResults page size used to fill the instance cache
+const describeInstancesMaxResults = 1000
+
+// InstanceCache keep the instances of a node group, filled by one paginated DescribeInstances filtered by the NodeGroup tag.
+// Lookups fallback to a direct DescribeInstances when the cache is expired or the instance is not found, except Exists trusting a fresh cache.
+// Instances created or deleted by the autoscaler are added or removed without waiting the next refresh
+type InstanceCache struct {
+	sync.RWMutex
+	config      *Configuration
+	nodeGroup   string
+	refreshedAt time.Time
+	byID        map[string]*ec2.Instance
+	byName      map[string]*ec2.Instance
+	launched    map[string]launchedInstance
+}
+
+// launchedInstance instance added to the cache after its creation, kept until a refresh started after the creation
+type launchedInstance struct {
+	name       string
+	instance   *ec2.Instance
+	launchedAt time.Time
+}
+
+// instanceCacheKey identify the shared cache of a node group
+type instanceCacheKey struct {
+	config    *Configuration
+	nodeGroup string
+}
+
+// instanceCaches registry of instance cache by configuration and node group
+var instanceCaches sync.Map
+
+// NewInstanceCache create an empty instance cache for the node group
+func NewInstanceCache(conf *Configuration, nodeGroup string) *InstanceCache {
+	return &InstanceCache{
+		config:    conf,
+		nodeGroup: nodeGroup,
+		byID:      make(map[string]*ec2.Instance),
+		byName:    make(map[string]*ec2.Instance),
+		launched:  make(map[string]launchedInstance),
+	}
+}
+
+// GetInstanceCache return the instance cache of the node group shared by the configuration, created on first call
+func (conf *Configuration) GetInstanceCache(nodeGroup string) *InstanceCache {
+	cache, _ := instanceCaches.LoadOrStore(instanceCacheKey{config: conf, nodeGroup: nodeGroup}, NewInstanceCache(conf, nodeGroup))
+
+	return cache.(*InstanceCache)
+}
+
+// lookupInstanceCache return the instance cache of the node group if already created
+func (conf *Configuration) lookupInstanceCache(nodeGroup string) *InstanceCache {
+	if cache, found := instanceCaches.Load(instanceCacheKey{config: conf, nodeGroup: nodeGroup}); found {
+		return cache.(*InstanceCache)
+	}
+
+	return nil
+}
+
+// GetInstanceCacheTTL return the duration the instance cache is used after a refresh
+func (conf *Configuration) GetInstanceCacheTTL() time.Duration {
+	if conf.InstanceCacheTTL <= 0 {
+		return defaultInstanceCacheTTL * time.Second
+	}
+
+	return conf.InstanceCacheTTL * time.Second
+}
+
+func instanceName(instance *ec2.Instance) string {
+	return tagValue(instance, "Name")
+}
+
+func tagValue(instance *ec2.Instance, key string) string {
+	for _, tag := range instance.Tags {
+		if aws.StringValue(tag.Key) == key {
+			return aws.StringValue(tag.Value)
+		}
+	}
+
+	return ""
+}
+
+// isInstanceAlive return false for terminated or shutting-down instance
+func isInstanceAlive(instance *ec2.Instance) bool {
+	code := aws.Int64Value(instance.State.Code)
+
+	// Assume EC2 shutting-down is terminated after
+	return code != 48 && code != 32
+}
+
+// Refresh fill the cache with the instances of the node group
+func (c *InstanceCache) Refresh() error {
+	client, err := createClient(c.config)
+
+	if err != nil {
+		return err
+	}
+
+	ctx := context.NewContext(c.config.Timeout)
+	defer ctx.Cancel()
+
+	startedAt := time.Now()
+	byID := make(map[string]*ec2.Instance)
+	byName := make(map[string]*ec2.Instance)
+	input := &ec2.DescribeInstancesInput{
+		MaxResults: aws.Int64(describeInstancesMaxResults),
+		Filters: []*ec2.Filter{
+			{
+				Name: aws.String("tag:NodeGroup"),
+				Values: []*string{
+					aws.String(c.nodeGroup),
+				},
+			},
+		},
+	}
+
+	for {
+		output, err := client.DescribeInstancesWithContext(ctx, input)
+
+		if err != nil {
+			return fmt.Errorf(constantes.ErrUnableToRefreshInstanceCache, c.nodeGroup, err)
+		}
+
+		for _, reservation := range output.Reservations {
+			for _, instance := range reservation.Instances {
+				byID[aws.StringValue(instance.InstanceId)] = instance
+
+				if isInstanceAlive(instance) {
+					byName[instanceName(instance)] = instance
+				}
+			}
+		}
+
+		if aws.StringValue(output.NextToken) == "" {
+			break
+		}
+
+		input.NextToken = output.NextToken
+	}
+
+	c.Lock()
+	defer c.Unlock()
+
+	// Keep the instances launched during the refresh, they could be missing in the result
+	for instanceID, launched := range c.launched {
+		if launched.launchedAt.Before(startedAt) {
+			delete(c.launched, instanceID)
+		} else if byName[launched.name] == nil {
+			byName[launched.name] = launched.instance
+		}
+	}
+
+	c.byID = byID
+	c.byName = byName
+	c.refreshedAt = time.Now()
+
+	glog.Debugf("InstanceCache::Refresh, node group %s, found %d instances", c.nodeGroup, len(byID))
+
+	return nil
+}
+
+// IsFresh return true if the cache was refreshed during the TTL
+func (c *InstanceCache) IsFresh() bool {
+	c.RLock()
+	defer c.RUnlock()
+
+	return time.Since(c.refreshedAt) < c.config.GetInstanceCacheTTL()
+}
+
+// Add put the name of the instance just created in the cache, Exists see it before the next refresh.
+// The state is not cached, status lookups describe the instance until the next refresh
+func (c *InstanceCache) Add(name string, instance *ec2.Instance) {
+	if c == nil || instance == nil || instance.InstanceId == nil {
+		return
+	}
+
+	c.Lock()
+	defer c.Unlock()
+
+	c.byName[name] = instance
+	c.launched[*instance.InstanceId] = launchedInstance{
+		name:       name,
+		instance:   instance,
+		launchedAt: time.Now(),
+	}
+}
+
+// Invalidate remove the cached state of the instance, next lookups by ID will describe it.
+// The instance is still known by name
+func (c *InstanceCache) Invalidate(instanceID *string) {
+	if c == nil || instanceID == nil {
+		return
+	}
+
+	c.Lock()
+	defer c.Unlock()
+
+	delete(c.byID, *instanceID)
+}
+
+// Remove forget the deleted instance, its name could be reused
+func (c *InstanceCache) Remove(name string, instanceID *string) {
+	if c == nil || instanceID == nil {
+		return
+	}
+
+	c.Lock()
+	defer c.Unlock()
+
+	delete(c.byID, *instanceID)
+	delete(c.launched, *instanceID)
+
+	if instance := c.byName[name]; instance != nil && aws.StringValue(instance.InstanceId) == *instanceID {
+		delete(c.byName, name)
+	}
+}
+
+func (c *InstanceCache) findByID(instanceID *string) *ec2.Instance {
+	if c == nil || instanceID == nil || !c.IsFresh() {
+		return nil
+	}
+
+	c.RLock()
+	defer c.RUnlock()
+
+	return c.byID[*instanceID]
+}
+
+func (c *InstanceCache) findByName(name string) *ec2.Instance {
+	if c == nil || !c.IsFresh() {
+		return nil
+	}
+
+	c.RLock()
+	defer c.RUnlock()
+
+	return c.byName[name]
+}
+
+// GetEc2Instance return an existing instance from name, the returned instance use the cache for status
+func (c *InstanceCache) GetEc2Instance(name string) (*Ec2Instance, error) {
+	if instance := c.findByName(name); instance != nil {
+		if client, err := createClient(c.config); err != nil {
+			return nil, err
+		} else {
+			ec2Instance := newEc2InstanceFrom(client, c.config, name, instance)
+			ec2Instance.cache = c
+
+			return ec2Instance, nil
+		}
+	}
+
+	if ec2Instance, err := GetEc2Instance(c.config, name); err != nil {
+		return nil, err
+	} else {
+		ec2Instance.cache = c
+
+		return ec2Instance, nil
+	}
+}
+
+// Exists return true if the named instance exists. A stale cache is refreshed once,
+// the instance is described only if the refresh failed
+func (c *InstanceCache) Exists(name string) bool {
+	if !c.IsFresh() {
+		if err := c.Refresh(); err != nil {
+			glog.Errorf(err.Error())
+
+			_, err = GetEc2Instance(c.config, name)
+
+			return err == nil
+		}
+	}
+
+	c.RLock()
+	defer c.RUnlock()
+
+	return c.byName[name] != nil
+}
+
+// CountByZone return the number of live instances of the node group in each availability zone, a stale cache is refreshed
+func (c *InstanceCache) CountByZone() (map[string]int, error) {
+	if !c.IsFresh() {
+		if err := c.Refresh(); err != nil {
+			return nil, fmt.Errorf(constantes.ErrUnableToCountNodesByZone, c.nodeGroup, err)
+		}
+	}
+
+	c.RLock()
+	defer c.RUnlock()
+
+	counts := make(map[string]int)
+
+	for _, instance := range c.byName {
+		if instance.Placement != nil {
+			counts[aws.StringValue(instance.Placement.AvailabilityZone)]++
+		}
+	}
+
+	return counts, nil
+}
+
+func hasTag(instance *ec2.Instance, key string) bool {
+	for _, tag := range instance.Tags {
+		if aws.StringValue(tag.Key) == key {
+			return true
+		}
+	}
+
+	return false
+}
+
+// AliveInstances refresh the cache and return the instances of the node group not terminated.
+// When the cluster name is declared, instances without the cluster tag are omitted, they could belong to a same named node group of another cluster
+func (c *InstanceCache) AliveInstances() ([]*Ec2Instance, error) {
+	if err := c.Refresh(); err != nil {
+		return nil, err
+	}
+
+	client, err := createClient(c.config)
+
+	if err != nil {
+		return nil, err
+	}
+
+	c.RLock()
+	defer c.RUnlock()
+
+	clusterName := c.config.ClusterName
+	instances := make([]*Ec2Instance, 0, len(c.byName))
+
+	for name, instance := range c.byName {
+		if !isNullOrEmpty(clusterName) && !hasTag(instance, ClusterTagKey(clusterName)) {
+			continue
+		}
+
+		ec2Instance := newEc2InstanceFrom(client, c.config, name, instance)
+		ec2Instance.cache = c
+
+		instances = append(instances, ec2Instance)
+	}
+
+	sort.Slice(instances, func(i, j int) bool {
+		return instances[i].InstanceName < instances[j].InstanceName
+	})
+
+	return instances, nil
+}
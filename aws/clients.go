@@ -0,0 +1,380 @@
+package aws
+
+import (
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+	"github.com/aws/aws-sdk-go/service/elbv2"
+	"github.com/aws/aws-sdk-go/service/elbv2/elbv2iface"
+	"github.com/aws/aws-sdk-go/service/pricing"
+	"github.com/aws/aws-sdk-go/service/pricing/pricingiface"
+	"github.com/aws/aws-sdk-go/service/route53"
+	"github.com/aws/aws-sdk-go/service/route53/route53iface"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/aws/aws-sdk-go/service/sqs/sqsiface"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/aws/aws-sdk-go/service/ssm/ssmiface"
+	glog "github.com/sirupsen/logrus"
+)
+
+// ClientProvider create the api clients used to talk with aws.
+// Implement it to inject an alternate backend like an in-memory fake
+type ClientProvider interface {
+	GetEC2Client(conf *Configuration) (ec2iface.EC2API, error)
+	GetRoute53Client(conf *Configuration) (route53iface.Route53API, error)
+	GetPricingClient(conf *Configuration) (pricingiface.PricingAPI, error)
+	GetSSMClient(conf *Configuration) (ssmiface.SSMAPI, error)
+	GetELBv2Client(conf *Configuration) (elbv2iface.ELBV2API, error)
+	GetSQSClient(conf *Configuration) (sqsiface.SQSAPI, error)
+	GetS3Client(conf *Configuration) (s3iface.S3API, error)
+}
+
+// assumeRole declare a role to assume with sts
+type assumeRole struct {
+	roleARN    string
+	externalID string
+}
+
+// sessionOptions declare how to build credentials, also used as key to share clients
+// between configurations with same credentials and region
+type sessionOptions struct {
+	accessKey            string
+	secretKey            string
+	token                string
+	filename             string
+	profile              string
+	region               string
+	webIdentityTokenFile string
+	roles                [2]assumeRole
+}
+
+// sqsClientKey share sqs clients by credentials, region and endpoint
+type sqsClientKey struct {
+	options  sessionOptions
+	endpoint string
+}
+
+// sessionClientProvider create clients from aws session, clients are shared by configurations with same credentials and region
+type sessionClientProvider struct {
+	sync.Mutex
+	ec2Clients     map[sessionOptions]ec2iface.EC2API
+	route53Clients map[sessionOptions]route53iface.Route53API
+	pricingClients map[sessionOptions]pricingiface.PricingAPI
+	ssmClients     map[sessionOptions]ssmiface.SSMAPI
+	elbv2Clients   map[sessionOptions]elbv2iface.ELBV2API
+	sqsClients     map[sqsClientKey]sqsiface.SQSAPI
+	s3Clients      map[sessionOptions]s3iface.S3API
+}
+
+var defaultClientProvider = NewSessionClientProvider()
+
+// NewSessionClientProvider return a client provider creating real aws clients
+func NewSessionClientProvider() ClientProvider {
+	return &sessionClientProvider{
+		ec2Clients:     make(map[sessionOptions]ec2iface.EC2API),
+		route53Clients: make(map[sessionOptions]route53iface.Route53API),
+		pricingClients: make(map[sessionOptions]pricingiface.PricingAPI),
+		ssmClients:     make(map[sessionOptions]ssmiface.SSMAPI),
+		elbv2Clients:   make(map[sessionOptions]elbv2iface.ELBV2API),
+		sqsClients:     make(map[sqsClientKey]sqsiface.SQSAPI),
+		s3Clients:      make(map[sessionOptions]s3iface.S3API),
+	}
+}
+
+func ec2SessionOptions(conf *Configuration) sessionOptions {
+	return sessionOptions{
+		accessKey:            conf.AccessKey,
+		secretKey:            conf.SecretKey,
+		token:                conf.Token,
+		filename:             conf.Filename,
+		profile:              conf.Profile,
+		region:               conf.Region,
+		webIdentityTokenFile: conf.WebIdentityTokenFile,
+		roles: [2]assumeRole{
+			{
+				roleARN:    conf.RoleARN,
+				externalID: conf.ExternalID,
+			},
+		},
+	}
+}
+
+// route53SessionOptions use network credentials if declared.
+// Without network credentials, the network role is assumed on top of the node group credentials and role
+func route53SessionOptions(conf *Configuration) sessionOptions {
+	networkRole := assumeRole{
+		roleARN:    conf.Network.RoleARN,
+		externalID: conf.Network.ExternalID,
+	}
+
+	if conf.Network.hasCredentials() {
+		return sessionOptions{
+			accessKey:            conf.GetRoute53AccessKey(),
+			secretKey:            conf.GetRoute53SecretKey(),
+			token:                conf.GetRoute53AccessToken(),
+			filename:             conf.GetFileName(),
+			profile:              conf.GetRoute53Profile(),
+			region:               conf.GetRoute53Region(),
+			webIdentityTokenFile: conf.Network.WebIdentityTokenFile,
+			roles: [2]assumeRole{
+				networkRole,
+			},
+		}
+	}
+
+	options := ec2SessionOptions(conf)
+
+	options.region = conf.GetRoute53Region()
+	options.roles[1] = networkRole
+
+	return options
+}
+
+// newEC2Client create the EC2 service client with the retry policy and the circuit breaker of the region
+func newEC2Client(sess *session.Session, conf *Configuration) *ec2.EC2 {
+	var client *ec2.EC2
+
+	config := request.WithRetryer(aws.NewConfig(), newRetryer(conf.Retry))
+
+	if glog.GetLevel() >= glog.DebugLevel {
+		config = config.WithLogger(conf).WithLogLevel(aws.LogDebugWithHTTPBody).WithLogLevel(aws.LogDebugWithSigning)
+	}
+
+	client = ec2.New(sess, config)
+
+	installCircuitBreaker(&client.Handlers, conf.GetCircuitBreaker())
+
+	return client
+}
+
+// GetEC2Client return the ec2 client for the credentials and region of the configuration
+func (p *sessionClientProvider) GetEC2Client(conf *Configuration) (ec2iface.EC2API, error) {
+	p.Lock()
+	defer p.Unlock()
+
+	key := ec2SessionOptions(conf)
+
+	if client, found := p.ec2Clients[key]; found {
+		return client, nil
+	}
+
+	var err error
+	var sess *session.Session
+	var client *ec2.EC2
+
+	if sess, err = newSessionWithOptions(key); err != nil {
+		return nil, err
+	}
+
+	client = newEC2Client(sess, conf)
+
+	p.ec2Clients[key] = client
+
+	return client, nil
+}
+
+// GetRoute53Client return the route53 client for the network credentials and region of the configuration
+func (p *sessionClientProvider) GetRoute53Client(conf *Configuration) (route53iface.Route53API, error) {
+	p.Lock()
+	defer p.Unlock()
+
+	key := route53SessionOptions(conf)
+
+	if client, found := p.route53Clients[key]; found {
+		return client, nil
+	}
+
+	if sess, err := newSessionWithOptions(key); err != nil {
+		return nil, err
+	} else {
+		client := route53.New(sess, request.WithRetryer(aws.NewConfig(), newRetryer(conf.Retry)))
+
+		installCircuitBreaker(&client.Handlers, conf.getRegionCircuitBreaker(key.region))
+
+		p.route53Clients[key] = client
+
+		return client, nil
+	}
+}
+
+// GetPricingClient return the price list client for the credentials of the configuration, the api is served by us-east-1
+func (p *sessionClientProvider) GetPricingClient(conf *Configuration) (pricingiface.PricingAPI, error) {
+	p.Lock()
+	defer p.Unlock()
+
+	key := ec2SessionOptions(conf)
+	key.region = pricingRegion
+
+	if client, found := p.pricingClients[key]; found {
+		return client, nil
+	}
+
+	if sess, err := newSessionWithOptions(key); err != nil {
+		return nil, err
+	} else {
+		client := pricing.New(sess, request.WithRetryer(aws.NewConfig(), newRetryer(conf.Retry)))
+
+		installCircuitBreaker(&client.Handlers, conf.getRegionCircuitBreaker(pricingRegion))
+
+		p.pricingClients[key] = client
+
+		return client, nil
+	}
+}
+
+// GetSSMClient return the ssm client for the credentials and region of the configuration
+func (p *sessionClientProvider) GetSSMClient(conf *Configuration) (ssmiface.SSMAPI, error) {
+	p.Lock()
+	defer p.Unlock()
+
+	key := ec2SessionOptions(conf)
+
+	if client, found := p.ssmClients[key]; found {
+		return client, nil
+	}
+
+	if sess, err := newSessionWithOptions(key); err != nil {
+		return nil, err
+	} else {
+		client := ssm.New(sess, request.WithRetryer(aws.NewConfig(), newRetryer(conf.Retry)))
+
+		installCircuitBreaker(&client.Handlers, conf.GetCircuitBreaker())
+
+		p.ssmClients[key] = client
+
+		return client, nil
+	}
+}
+
+// GetELBv2Client return the elastic load balancing client for the credentials and region of the configuration
+func (p *sessionClientProvider) GetELBv2Client(conf *Configuration) (elbv2iface.ELBV2API, error) {
+	p.Lock()
+	defer p.Unlock()
+
+	key := ec2SessionOptions(conf)
+
+	if client, found := p.elbv2Clients[key]; found {
+		return client, nil
+	}
+
+	if sess, err := newSessionWithOptions(key); err != nil {
+		return nil, err
+	} else {
+		client := elbv2.New(sess, request.WithRetryer(aws.NewConfig(), newRetryer(conf.Retry)))
+
+		installCircuitBreaker(&client.Handlers, conf.GetCircuitBreaker())
+
+		p.elbv2Clients[key] = client
+
+		return client, nil
+	}
+}
+
+// GetSQSClient return the sqs client for the credentials and region of the configuration.
+// The endpoint of the interruption queue override the aws endpoint, to use a local SQS compatible service
+func (p *sessionClientProvider) GetSQSClient(conf *Configuration) (sqsiface.SQSAPI, error) {
+	p.Lock()
+	defer p.Unlock()
+
+	key := sqsClientKey{
+		options: ec2SessionOptions(conf),
+	}
+
+	if conf.InterruptionQueue != nil {
+		key.endpoint = conf.InterruptionQueue.Endpoint
+	}
+
+	if client, found := p.sqsClients[key]; found {
+		return client, nil
+	}
+
+	if sess, err := newSessionWithOptions(key.options); err != nil {
+		return nil, err
+	} else {
+		config := request.WithRetryer(aws.NewConfig(), newRetryer(conf.Retry))
+
+		if len(key.endpoint) > 0 {
+			config = config.WithEndpoint(key.endpoint)
+		}
+
+		client := sqs.New(sess, config)
+
+		installCircuitBreaker(&client.Handlers, conf.GetCircuitBreaker())
+
+		p.sqsClients[key] = client
+
+		return client, nil
+	}
+}
+
+// GetS3Client return the s3 client for the credentials and region of the configuration
+func (p *sessionClientProvider) GetS3Client(conf *Configuration) (s3iface.S3API, error) {
+	p.Lock()
+	defer p.Unlock()
+
+	key := ec2SessionOptions(conf)
+
+	if client, found := p.s3Clients[key]; found {
+		return client, nil
+	}
+
+	if sess, err := newSessionWithOptions(key); err != nil {
+		return nil, err
+	} else {
+		client := s3.New(sess, request.WithRetryer(aws.NewConfig(), newRetryer(conf.Retry)))
+
+		installCircuitBreaker(&client.Handlers, conf.GetCircuitBreaker())
+
+		p.s3Clients[key] = client
+
+		return client, nil
+	}
+}
+
+// SetClientProvider inject the client provider used by the configuration
+func (conf *Configuration) SetClientProvider(provider ClientProvider) {
+	conf.clients = provider
+}
+
+// GetClientProvider return the injected client provider or the default one
+func (conf *Configuration) GetClientProvider() ClientProvider {
+	if conf.clients == nil {
+		return defaultClientProvider
+	}
+
+	return conf.clients
+}
+
+func createClient(conf *Configuration) (ec2iface.EC2API, error) {
+	return conf.GetClientProvider().GetEC2Client(conf)
+}
+
+func createRoute53Client(conf *Configuration) (route53iface.Route53API, error) {
+	return conf.GetClientProvider().GetRoute53Client(conf)
+}
+
+func createPricingClient(conf *Configuration) (pricingiface.PricingAPI, error) {
+	return conf.GetClientProvider().GetPricingClient(conf)
+}
+
+func createSSMClient(conf *Configuration) (ssmiface.SSMAPI, error) {
+	return conf.GetClientProvider().GetSSMClient(conf)
+}
+
+func createELBv2Client(conf *Configuration) (elbv2iface.ELBV2API, error) {
+	return conf.GetClientProvider().GetELBv2Client(conf)
+}
+
+func createSQSClient(conf *Configuration) (sqsiface.SQSAPI, error) {
+	return conf.GetClientProvider().GetSQSClient(conf)
+}
+
+func createS3Client(conf *Configuration) (s3iface.S3API, error) {
+	return conf.GetClientProvider().GetS3Client(conf)
+}
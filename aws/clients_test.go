@@ -0,0 +1,160 @@
+package aws
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/route53"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_clientsIsolatedByRegion(t *testing.T) {
+	provider := NewSessionClientProvider()
+	euConfig := &Configuration{AccessKey: "AKIA1", SecretKey: "secret1", Region: "eu-west-1"}
+	usConfig := &Configuration{AccessKey: "AKIA1", SecretKey: "secret1", Region: "us-east-1"}
+
+	euClient, err := provider.GetEC2Client(euConfig)
+	assert.NoError(t, err)
+
+	usClient, err := provider.GetEC2Client(usConfig)
+	assert.NoError(t, err)
+
+	assert.NotSame(t, euClient, usClient)
+	assert.Equal(t, "eu-west-1", *euClient.(*ec2.EC2).Config.Region)
+	assert.Equal(t, "us-east-1", *usClient.(*ec2.EC2).Config.Region)
+}
+
+func Test_clientsIsolatedByAccount(t *testing.T) {
+	provider := NewSessionClientProvider()
+	firstAccount := &Configuration{AccessKey: "AKIA1", SecretKey: "secret1", Region: "eu-west-1"}
+	secondAccount := &Configuration{AccessKey: "AKIA2", SecretKey: "secret2", Region: "eu-west-1"}
+
+	firstClient, err := provider.GetEC2Client(firstAccount)
+	assert.NoError(t, err)
+
+	secondClient, err := provider.GetEC2Client(secondAccount)
+	assert.NoError(t, err)
+
+	assert.NotSame(t, firstClient, secondClient)
+
+	firstCreds, _ := firstClient.(*ec2.EC2).Config.Credentials.Get()
+	secondCreds, _ := secondClient.(*ec2.EC2).Config.Credentials.Get()
+
+	assert.Equal(t, "AKIA1", firstCreds.AccessKeyID)
+	assert.Equal(t, "AKIA2", secondCreds.AccessKeyID)
+}
+
+func Test_clientsSharedBySameCredentials(t *testing.T) {
+	provider := NewSessionClientProvider()
+	firstNodeGroup := &Configuration{AccessKey: "AKIA1", SecretKey: "secret1", Region: "eu-west-1"}
+	secondNodeGroup := &Configuration{AccessKey: "AKIA1", SecretKey: "secret1", Region: "eu-west-1", KeyName: "other"}
+
+	firstClient, err := provider.GetEC2Client(firstNodeGroup)
+	assert.NoError(t, err)
+
+	secondClient, err := provider.GetEC2Client(secondNodeGroup)
+	assert.NoError(t, err)
+
+	assert.Same(t, firstClient, secondClient)
+}
+
+func Test_route53ClientUseNetworkCredentials(t *testing.T) {
+	provider := NewSessionClientProvider()
+	conf := &Configuration{
+		AccessKey: "AKIA1",
+		SecretKey: "secret1",
+		Region:    "eu-west-1",
+		Network: Network{
+			AccessKey: "AKIA3",
+			SecretKey: "secret3",
+			Region:    "us-east-1",
+		},
+	}
+
+	client, err := provider.GetRoute53Client(conf)
+	assert.NoError(t, err)
+
+	creds, _ := client.(*route53.Route53).Config.Credentials.Get()
+
+	assert.Equal(t, "AKIA3", creds.AccessKeyID)
+	assert.Equal(t, "us-east-1", *client.(*route53.Route53).Config.Region)
+}
+
+func Test_route53AssumeRoleFromNodeGroupCredentials(t *testing.T) {
+	conf := &Configuration{
+		AccessKey:  "AKIA1",
+		SecretKey:  "secret1",
+		Region:     "eu-west-1",
+		RoleARN:    "arn:aws:iam::111111111111:role/autoscaler",
+		ExternalID: "external-1",
+		Network: Network{
+			RoleARN:    "arn:aws:iam::222222222222:role/route53",
+			ExternalID: "external-2",
+		},
+	}
+
+	options := route53SessionOptions(conf)
+
+	assert.Equal(t, "AKIA1", options.accessKey)
+	assert.Equal(t, assumeRole{roleARN: conf.RoleARN, externalID: conf.ExternalID}, options.roles[0])
+	assert.Equal(t, assumeRole{roleARN: conf.Network.RoleARN, externalID: conf.Network.ExternalID}, options.roles[1])
+	assert.NotEqual(t, ec2SessionOptions(conf), options)
+}
+
+func Test_route53AssumeRoleFromNetworkCredentials(t *testing.T) {
+	conf := &Configuration{
+		AccessKey: "AKIA1",
+		SecretKey: "secret1",
+		Region:    "eu-west-1",
+		RoleARN:   "arn:aws:iam::111111111111:role/autoscaler",
+		Network: Network{
+			AccessKey: "AKIA3",
+			SecretKey: "secret3",
+			RoleARN:   "arn:aws:iam::222222222222:role/route53",
+		},
+	}
+
+	options := route53SessionOptions(conf)
+
+	assert.Equal(t, "AKIA3", options.accessKey)
+	assert.Equal(t, "eu-west-1", options.region)
+	assert.Equal(t, assumeRole{roleARN: conf.Network.RoleARN}, options.roles[0])
+	assert.Empty(t, options.roles[1].roleARN)
+}
+
+func Test_sessionWithAssumeRole(t *testing.T) {
+	provider := NewSessionClientProvider()
+	conf := &Configuration{
+		AccessKey:  "AKIA1",
+		SecretKey:  "secret1",
+		Region:     "eu-west-1",
+		RoleARN:    "arn:aws:iam::111111111111:role/autoscaler",
+		ExternalID: "external-1",
+	}
+
+	client, err := provider.GetEC2Client(conf)
+	assert.NoError(t, err)
+
+	staticClient, err := provider.GetEC2Client(&Configuration{AccessKey: "AKIA1", SecretKey: "secret1", Region: "eu-west-1"})
+	assert.NoError(t, err)
+
+	assert.NotSame(t, client, staticClient)
+	assert.NotSame(t, client.(*ec2.EC2).Config.Credentials, staticClient.(*ec2.EC2).Config.Credentials)
+}
+
+func Test_sessionWithWebIdentity(t *testing.T) {
+	sess, err := newSessionWithOptions(sessionOptions{
+		region:               "eu-west-1",
+		webIdentityTokenFile: "/var/run/secrets/eks.amazonaws.com/serviceaccount/token",
+		roles: [2]assumeRole{
+			{
+				roleARN: "arn:aws:iam::111111111111:role/autoscaler",
+			},
+		},
+	})
+
+	if assert.NoError(t, err) {
+		assert.NotNil(t, sess.Config.Credentials)
+		assert.Equal(t, "eu-west-1", *sess.Config.Region)
+	}
+}
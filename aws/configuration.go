@@ -25,21 +25,109 @@ const (
 
 // Configuration declares aws connection info
 type Configuration struct {
-	AccessKey string        `json:"accessKey,omitempty"`
-	SecretKey string        `json:"secretKey,omitempty"`
-	Token     string        `json:"token,omitempty"`
-	Filename  string        `json:"filename,omitempty"`
-	Profile   string        `json:"profile,omitempty"`
-	Region    string        `json:"region,omitempty"`
-	Timeout   time.Duration `json:"timeout"`
-	ImageID   string        `json:"ami"`
-	IamRole   string        `json:"iam-role-arn"`
-	KeyName   string        `json:"keyName"`
-	Tags      []Tag         `json:"tags,omitempty"`
-	Network   Network       `json:"network"`
-	DiskType  string        `default:"standard" json:"diskType"`
-	DiskSize  int           `default:"10" json:"diskSize"`
-	TestMode  bool          `json:"-"`
+	AccessKey            string                      `json:"accessKey,omitempty"`
+	SecretKey            string                      `json:"secretKey,omitempty"`
+	Token                string                      `json:"token,omitempty"`
+	Filename             string                      `json:"filename,omitempty"`
+	Profile              string                      `json:"profile,omitempty"`
+	RoleARN              string                      `json:"roleArn,omitempty"`
+	ExternalID           string                      `json:"externalId,omitempty"`
+	WebIdentityTokenFile string                      `json:"webIdentityTokenFile,omitempty"`
+	Region               string                      `json:"region,omitempty"`
+	Timeout              time.Duration               `json:"timeout"`
+	ImageID              string                      `json:"ami"`
+	Images               map[string]*ImageSelector   `json:"images,omitempty"`
+	ImageCacheTTL        time.Duration               `json:"imageCacheTTL,omitempty"`
+	IamRole              string                      `json:"iam-role-arn"`
+	KeyName              string                      `json:"keyName"`
+	ClusterName          string                      `json:"clusterName,omitempty"`
+	Tags                 []Tag                       `json:"tags,omitempty"`
+	PropagateTags        bool                        `json:"propagateTags,omitempty"`
+	NodeLabelsToTags     []string                    `json:"nodeLabelsToTags,omitempty"`
+	Network              Network                     `json:"network"`
+	NetworkDiscoveryTTL  time.Duration               `json:"networkDiscoveryTTL,omitempty"`
+	DiskType             string                      `default:"standard" json:"diskType"`
+	DiskSize             int                         `default:"10" json:"diskSize"`
+	Spot                 *SpotOptions                `json:"spot,omitempty"`
+	BlockDevices         []BlockDevice               `json:"blockDevices,omitempty"`
+	Fallback             *FallbackOptions            `json:"fallback,omitempty"`
+	ZoneCoolDown         time.Duration               `json:"zoneCoolDown,omitempty"`
+	InstanceCacheTTL     time.Duration               `json:"instanceCacheTTL,omitempty"`
+	Retry                *RetryOptions               `json:"retry,omitempty"`
+	LaunchTemplate       *LaunchTemplate             `json:"launchTemplate,omitempty"`
+	Placement            *PlacementOptions           `json:"placement,omitempty"`
+	CapacityReservation  *CapacityReservationOptions `json:"capacityReservation,omitempty"`
+	WarmPool             *WarmPoolOptions            `json:"warmPool,omitempty"`
+	MetadataOptions      *MetadataOptions            `json:"metadataOptions,omitempty"`
+	TargetGroups         []TargetGroup               `json:"targetGroups,omitempty"`
+	DeregistrationDelay  time.Duration               `json:"deregistrationDelay,omitempty"`
+	InterruptionQueue    *InterruptionQueueOptions   `json:"interruptionQueue,omitempty"`
+	RemoteExec           *RemoteExecOptions          `json:"remoteExec,omitempty"`
+	TestMode             bool                        `json:"-"`
+	clients              ClientProvider
+}
+
+// LaunchTemplate declare the launch template used to create instances, by ID or by name
+type LaunchTemplate struct {
+	ID      string `json:"id,omitempty"`
+	Name    string `json:"name,omitempty"`
+	Version string `json:"version,omitempty"`
+}
+
+// SpotOptions declare if instances must be launched on the spot market
+type SpotOptions struct {
+	Enabled  bool   `json:"enabled"`
+	MaxPrice string `json:"maxPrice,omitempty"`
+}
+
+// WarmPoolOptions declare the pool of stopped nodes already joined to the cluster, started first on scale up.
+// MinSize nodes are launched to fill the pool, nodes removed on scale down return to the pool until it holds MaxSize nodes
+type WarmPoolOptions struct {
+	MinSize int `json:"minSize,omitempty"`
+	MaxSize int `json:"maxSize,omitempty"`
+}
+
+// GetMaxSize return the max number of nodes kept in the pool, never less than MinSize
+func (options *WarmPoolOptions) GetMaxSize() int {
+	if options.MaxSize < options.MinSize {
+		return options.MinSize
+	}
+
+	return options.MaxSize
+}
+
+// CreateInput declare the instance to create
+type CreateInput struct {
+	NodeIndex     int
+	NodeGroup     string
+	InstanceType  string
+	Zone          string // Optional, restrict the subnet to this availability zone
+	DiskType      string
+	DiskSize      int
+	BlockDevices  []BlockDevice
+	UserData      *string
+	DesiredENI    *UserDefinedNetworkInterface
+	Spot          *SpotOptions
+	Architectures []string // Optional, architectures of the instance type, the first one select the image
+}
+
+// FallbackOptions declare ordered candidates used when the instance type or the zone lacks capacity
+type FallbackOptions struct {
+	InstanceTypes []string `json:"instanceTypes,omitempty"`
+	Zones         []string `json:"zones,omitempty"`
+}
+
+// BlockDevice declare an EBS volume attached to instances.
+// A volume without device name or with the AMI root device name describe the root volume
+type BlockDevice struct {
+	DeviceName          string `json:"deviceName,omitempty"`
+	VolumeType          string `json:"volumeType,omitempty"`
+	VolumeSize          int    `json:"volumeSize,omitempty"`
+	Encrypted           bool   `json:"encrypted,omitempty"`
+	KmsKeyID            string `json:"kmsKeyId,omitempty"`
+	Iops                int    `json:"iops,omitempty"`
+	Throughput          int    `json:"throughput,omitempty"`
+	DeleteOnTermination *bool  `json:"deleteOnTermination,omitempty"`
 }
 
 // Tag aws tag
@@ -50,21 +138,45 @@ type Tag struct {
 
 // Network declare network configuration
 type Network struct {
-	ZoneID          string             `json:"route53,omitempty"`
-	PrivateZoneName string             `json:"privateZoneName,omitempty"`
-	AccessKey       string             `json:"accessKey,omitempty"`
-	SecretKey       string             `json:"secretKey,omitempty"`
-	Token           string             `json:"token,omitempty"`
-	Profile         string             `json:"profile,omitempty"`
-	Region          string             `json:"region,omitempty"`
-	ENI             []NetworkInterface `json:"eni,omitempty"`
+	ZoneID               string             `json:"route53,omitempty"`
+	PrivateZoneName      string             `json:"privateZoneName,omitempty"`
+	AccessKey            string             `json:"accessKey,omitempty"`
+	SecretKey            string             `json:"secretKey,omitempty"`
+	Token                string             `json:"token,omitempty"`
+	Profile              string             `json:"profile,omitempty"`
+	RoleARN              string             `json:"roleArn,omitempty"`
+	ExternalID           string             `json:"externalId,omitempty"`
+	WebIdentityTokenFile string             `json:"webIdentityTokenFile,omitempty"`
+	Region               string             `json:"region,omitempty"`
+	ENI                  []NetworkInterface `json:"eni,omitempty"`
+	RFC2136              *RFC2136           `json:"rfc2136,omitempty"`
+}
+
+// RFC2136 declare a DNS server accepting dynamic updates signed with TSIG, used instead of Route53
+type RFC2136 struct {
+	Server        string `json:"server"`
+	Zone          string `json:"zone,omitempty"`
+	TSIGKeyName   string `json:"tsigKeyName,omitempty"`
+	TSIGSecret    string `json:"tsigSecret,omitempty"`
+	TSIGAlgorithm string `default:"hmac-sha256" json:"tsigAlgorithm,omitempty"`
+	Transport     string `default:"udp" json:"transport,omitempty"`
+	TTL           int    `default:"60" json:"ttl,omitempty"`
 }
 
-// NetworkInterface declare ENI interface
+// NetworkInterface declare ENI interface, IPv6 addresses or prefixes are assigned for dual stack
 type NetworkInterface struct {
-	SubnetsID       []string `json:"subnets"`
-	SecurityGroupID string   `json:"securityGroup"`
-	PublicIP        bool     `json:"publicIP"`
+	SubnetsID        []string `json:"subnets"`
+	SecurityGroupID  string   `json:"securityGroup"`
+	PublicIP         bool     `json:"publicIP"`
+	IPv6AddressCount int      `json:"ipv6AddressCount,omitempty"`
+	IPv6PrefixCount  int      `json:"ipv6PrefixCount,omitempty"`
+	// Tags selecting the subnets and the security groups, a tag without value match the tag key only
+	SubnetSelector        map[string]string `json:"subnetSelector,omitempty"`
+	SecurityGroupSelector map[string]string `json:"securityGroupSelector,omitempty"`
+	MinFreeIPAddresses    int               `json:"minFreeIPAddresses,omitempty"`
+	VpcID                 string            `json:"vpcId,omitempty"`
+	securityGroupsID      []string
+	vpcID                 string
 }
 
 // UserDefinedNetworkInterface declare a network interface interface overriding default Eni
@@ -73,13 +185,15 @@ type UserDefinedNetworkInterface struct {
 	SubnetID           string `json:"subnets"`
 	SecurityGroupID    string `json:"securityGroup"`
 	PrivateAddress     string `json:"privateAddress,omitempty"`
+	IPv6Address        string `json:"ipv6Address,omitempty"`
 	PublicIP           bool   `json:"publicIP"`
 }
 
 // Status shortened vm status
 type Status struct {
-	Address string
-	Powered bool
+	Address     string
+	AddressIPv6 string
+	Powered     bool
 }
 
 // CallbackWaitSSHReady callback to test if ssh become ready or return timeout error
@@ -142,6 +256,27 @@ func (conf *Configuration) GetFileName() string {
 	return conf.Filename
 }
 
+// IsDualStack return true if an ENI request IPv6 addresses or prefixes
+func (network *Network) IsDualStack() bool {
+	for _, eni := range network.ENI {
+		if eni.IsDualStack() {
+			return true
+		}
+	}
+
+	return false
+}
+
+// IsDualStack return true if the ENI request IPv6 addresses or prefixes
+func (eni *NetworkInterface) IsDualStack() bool {
+	return eni.IPv6AddressCount > 0 || eni.IPv6PrefixCount > 0
+}
+
+// hasCredentials return true if the network declare its own credentials
+func (network *Network) hasCredentials() bool {
+	return !isNullOrEmpty(network.AccessKey) || !isNullOrEmpty(network.Profile) || !isNullOrEmpty(network.WebIdentityTokenFile)
+}
+
 // GetRoute53AccessKey return route53 access key or default
 func (conf *Configuration) GetRoute53AccessKey() string {
 	if !isNullOrEmpty(conf.Network.AccessKey) {
@@ -187,9 +322,28 @@ func (conf *Configuration) GetRoute53Region() string {
 	return conf.Region
 }
 
+// IsSpot return true if spot market is enabled
+func (spot *SpotOptions) IsSpot() bool {
+	return spot != nil && spot.Enabled
+}
+
+// UseLaunchTemplate return true if a launch template is declared
+func (conf *Configuration) UseLaunchTemplate() bool {
+	return conf.LaunchTemplate != nil && (!isNullOrEmpty(conf.LaunchTemplate.ID) || !isNullOrEmpty(conf.LaunchTemplate.Name))
+}
+
+// GetVersion return the launch template version or $Default
+func (template *LaunchTemplate) GetVersion() string {
+	if isNullOrEmpty(template.Version) {
+		return "$Default"
+	}
+
+	return template.Version
+}
+
 // Create will create a named VM not powered
 // memory and disk are in megabytes
-func (conf *Configuration) Create(nodeIndex int, nodeGroup, name, instanceType string, diskType string, diskSize int, userData *string, desiredENI *UserDefinedNetworkInterface) (*Ec2Instance, error) {
+func (conf *Configuration) Create(name string, create *CreateInput) (*Ec2Instance, error) {
 	var err error
 	var instance *Ec2Instance
 
@@ -197,17 +351,14 @@ func (conf *Configuration) Create(nodeIndex int, nodeGroup, name, instanceType s
 		return nil, err
 	}
 
-	if err = instance.Create(nodeIndex, nodeGroup, instanceType, userData, diskType, diskSize, desiredENI); err != nil {
+	if err = instance.Create(create); err != nil {
 		return nil, err
 	}
 
 	return instance, nil
 }
 
-func (conf *Configuration) Exists(name string) bool {
-	if _, err := GetEc2Instance(conf, name); err == nil {
-		return true
-	}
-
-	return false
+// Exists return true if the named instance of the node group exists, lookup is done by the instance cache of the node group
+func (conf *Configuration) Exists(nodeGroup, name string) bool {
+	return conf.GetInstanceCache(nodeGroup).Exists(name)
 }
@@ -0,0 +1,34 @@
+package aws
+
+import "net"
+
+// DNSProvider register the hostname of nodes in the private zone
+type DNSProvider interface {
+	Register(name, address string, wait bool) error
+	Unregister(name string, wait bool) error
+}
+
+// dnsRecordType return AAAA for an IPv6 address, else A
+func dnsRecordType(address string) string {
+	if ip := net.ParseIP(address); ip != nil && ip.To4() == nil {
+		return "AAAA"
+	}
+
+	return "A"
+}
+
+// GetDNSProvider return the DNS provider declared by the network, nil if none.
+// RFC2136 dynamic update is used if declared, else Route53 if a hosted zone is declared
+func (conf *Configuration) GetDNSProvider() DNSProvider {
+	if conf.Network.RFC2136 != nil {
+		return newRFC2136Provider(conf.Network.RFC2136, conf.Network.PrivateZoneName)
+	}
+
+	if len(conf.Network.ZoneID) > 0 {
+		return &route53Provider{
+			config: conf,
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,85 @@
+package aws
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Fred78290/kubernetes-aws-autoscaler/constantes"
+	"github.com/Fred78290/kubernetes-aws-autoscaler/context"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+)
+
+// describeInstanceStatusMaxIDs max number of instance IDs by DescribeInstanceStatus call
+const describeInstanceStatusMaxIDs = 100
+
+// ScheduledEvent upcoming retirement, reboot or maintenance of an instance
+type ScheduledEvent struct {
+	InstanceID  string
+	Code        string
+	Description string
+	NotBefore   time.Time
+}
+
+// isEventDone return true if the event is completed or canceled, aws keep them a while with a prefixed description
+func isEventDone(event *ec2.InstanceStatusEvent) bool {
+	description := aws.StringValue(event.Description)
+
+	return strings.HasPrefix(description, "[Completed]") || strings.HasPrefix(description, "[Canceled]")
+}
+
+// DescribeScheduledEvents return the earliest upcoming event of each instance, instances without event are omitted
+func (conf *Configuration) DescribeScheduledEvents(instanceIDs []string) (map[string]*ScheduledEvent, error) {
+	client, err := createClient(conf)
+
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := context.NewContext(conf.Timeout)
+	defer ctx.Cancel()
+
+	events := make(map[string]*ScheduledEvent)
+
+	for start := 0; start < len(instanceIDs); start += describeInstanceStatusMaxIDs {
+		end := start + describeInstanceStatusMaxIDs
+
+		if end > len(instanceIDs) {
+			end = len(instanceIDs)
+		}
+
+		input := &ec2.DescribeInstanceStatusInput{
+			InstanceIds: aws.StringSlice(instanceIDs[start:end]),
+		}
+
+		output, err := client.DescribeInstanceStatusWithContext(ctx, input)
+
+		if err != nil {
+			return nil, fmt.Errorf(constantes.ErrUnableToDescribeInstanceStatus, err)
+		}
+
+		for _, status := range output.InstanceStatuses {
+			instanceID := aws.StringValue(status.InstanceId)
+
+			for _, event := range status.Events {
+				if isEventDone(event) {
+					continue
+				}
+
+				notBefore := aws.TimeValue(event.NotBefore)
+
+				if found, ok := events[instanceID]; !ok || notBefore.Before(found.NotBefore) {
+					events[instanceID] = &ScheduledEvent{
+						InstanceID:  instanceID,
+						Code:        aws.StringValue(event.Code),
+						Description: aws.StringValue(event.Description),
+						NotBefore:   notBefore,
+					}
+				}
+			}
+		}
+	}
+
+	return events, nil
+}
@@ -0,0 +1,48 @@
+package fake
+
+import (
+	"github.com/Fred78290/kubernetes-aws-autoscaler/aws"
+	awssdk "github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+)
+
+// ImageID AMI registered by NewConfiguration
+const ImageID = "ami-12345678"
+
+// NewConfiguration return a configuration backed by new in-memory backends, shared by the tests of each package.
+// The region of the configuration knows the image ImageID and the subnets subnet-1 and subnet-2, in zones a and b
+func NewConfiguration(region string) (*aws.Configuration, *ClientProvider) {
+	clients := NewClientProvider(region)
+	conf := &aws.Configuration{
+		Region:  region,
+		Timeout: 10,
+		ImageID: ImageID,
+		Network: aws.Network{
+			ENI: []aws.NetworkInterface{
+				{
+					SubnetsID:       []string{"subnet-1", "subnet-2"},
+					SecurityGroupID: "sg-1234",
+				},
+			},
+		},
+	}
+
+	conf.SetClientProvider(clients)
+
+	clients.EC2.Images[ImageID] = &ec2.Image{
+		ImageId:        awssdk.String(ImageID),
+		RootDeviceName: awssdk.String("/dev/xvda"),
+	}
+
+	clients.EC2.Subnets["subnet-1"] = &ec2.Subnet{
+		SubnetId:         awssdk.String("subnet-1"),
+		AvailabilityZone: awssdk.String(region + "a"),
+	}
+
+	clients.EC2.Subnets["subnet-2"] = &ec2.Subnet{
+		SubnetId:         awssdk.String("subnet-2"),
+		AvailabilityZone: awssdk.String(region + "b"),
+	}
+
+	return conf, clients
+}
@@ -0,0 +1,811 @@
+package fake
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+)
+
+const (
+	stateCodePending      = 0
+	stateCodeRunning      = 16
+	stateCodeShuttingDown = 32
+	stateCodeTerminated   = 48
+	stateCodeStopping     = 64
+	stateCodeStopped      = 80
+)
+
+var stateNames = map[int64]string{
+	stateCodePending:      ec2.InstanceStateNamePending,
+	stateCodeRunning:      ec2.InstanceStateNameRunning,
+	stateCodeShuttingDown: ec2.InstanceStateNameShuttingDown,
+	stateCodeTerminated:   ec2.InstanceStateNameTerminated,
+	stateCodeStopping:     ec2.InstanceStateNameStopping,
+	stateCodeStopped:      ec2.InstanceStateNameStopped,
+}
+
+// EC2 in-memory implementation of ec2iface.EC2API.
+// Only the methods used by the autoscaler are implemented, others panic
+type EC2 struct {
+	ec2iface.EC2API
+	sync.Mutex
+	Region          string
+	Instances       map[string]*ec2.Instance
+	LaunchTemplates map[string]*ec2.ResponseLaunchTemplateData
+	Images          map[string]*ec2.Image
+	BlockDevices    map[string][]*ec2.BlockDeviceMapping
+	Subnets         map[string]*ec2.Subnet
+	InstanceTypes   map[string]*ec2.InstanceTypeInfo
+	SecurityGroups  map[string]*ec2.SecurityGroup
+	// DescribeSecurityGroupsCalls count calls to DescribeSecurityGroups
+	DescribeSecurityGroupsCalls int
+	// ResourceTags tags of volumes and network interfaces by resource ID
+	ResourceTags map[string][]*ec2.Tag
+	// ScheduledEvents scheduled events by instance ID
+	ScheduledEvents map[string][]*ec2.InstanceStatusEvent
+	// DescribeInstanceTypesError simulate an unreachable api
+	DescribeInstanceTypesError error
+	// DescribeInstanceTypesCalls count calls to DescribeInstanceTypes
+	DescribeInstanceTypesCalls int
+	// ExhaustedReservations capacity reservation ID or group ARN without available capacity
+	ExhaustedReservations map[string]bool
+	// InsufficientCapacity instance types or instanceType/zone without capacity
+	InsufficientCapacity map[string]bool
+	// DescribeInstancesCalls count calls to DescribeInstances
+	DescribeInstancesCalls int
+	// TerminateInstancesError simulate a failed termination
+	TerminateInstancesError error
+	nextInstanceID          int
+}
+
+// NewEC2 create an empty in-memory ec2 backend
+func NewEC2(region string) *EC2 {
+	return &EC2{
+		Region:          region,
+		Instances:       make(map[string]*ec2.Instance),
+		LaunchTemplates: make(map[string]*ec2.ResponseLaunchTemplateData),
+		Images:          make(map[string]*ec2.Image),
+		BlockDevices:    make(map[string][]*ec2.BlockDeviceMapping),
+		Subnets:         make(map[string]*ec2.Subnet),
+		InstanceTypes:   make(map[string]*ec2.InstanceTypeInfo),
+		ResourceTags:    make(map[string][]*ec2.Tag),
+		SecurityGroups:  make(map[string]*ec2.SecurityGroup),
+		ScheduledEvents: make(map[string][]*ec2.InstanceStatusEvent),
+
+		InsufficientCapacity:  make(map[string]bool),
+		ExhaustedReservations: make(map[string]bool),
+	}
+}
+
+func setState(instance *ec2.Instance, code int64) {
+	instance.State = &ec2.InstanceState{
+		Code: aws.Int64(code),
+		Name: aws.String(stateNames[code]),
+	}
+}
+
+func tagValue(tags []*ec2.Tag, key string) (string, bool) {
+	for _, tag := range tags {
+		if aws.StringValue(tag.Key) == key {
+			return aws.StringValue(tag.Value), true
+		}
+	}
+
+	return "", false
+}
+
+// matchWildcard match value with a filter pattern, * match any characters and ? one character
+func matchWildcard(pattern, value string) bool {
+	expr := regexp.QuoteMeta(pattern)
+	expr = strings.ReplaceAll(expr, `\*`, ".*")
+	expr = strings.ReplaceAll(expr, `\?`, ".")
+
+	matched, _ := regexp.MatchString("^"+expr+"$", value)
+
+	return matched
+}
+
+func matchValues(value string, values []*string) bool {
+	for _, v := range values {
+		if aws.StringValue(v) == value || matchWildcard(aws.StringValue(v), value) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// matchTagFilter match tag:<key> and tag-key filters, handled is false for other filters
+func matchTagFilter(tags []*ec2.Tag, filter *ec2.Filter) (matched bool, handled bool) {
+	name := aws.StringValue(filter.Name)
+
+	if strings.HasPrefix(name, "tag:") {
+		if value, found := tagValue(tags, strings.TrimPrefix(name, "tag:")); found {
+			return matchValues(value, filter.Values), true
+		}
+
+		return false, true
+	} else if name == "tag-key" {
+		for _, v := range filter.Values {
+			if _, found := tagValue(tags, aws.StringValue(v)); found {
+				return true, true
+			}
+		}
+
+		return false, true
+	}
+
+	return false, false
+}
+
+func matchFilter(instance *ec2.Instance, filter *ec2.Filter) bool {
+	name := aws.StringValue(filter.Name)
+
+	if matched, handled := matchTagFilter(instance.Tags, filter); handled {
+		return matched
+	}
+
+	switch {
+	case name == "instance-state-name":
+		return matchValues(aws.StringValue(instance.State.Name), filter.Values)
+	case name == "instance-id":
+		return matchValues(aws.StringValue(instance.InstanceId), filter.Values)
+	case name == "availability-zone":
+		return matchValues(aws.StringValue(instance.Placement.AvailabilityZone), filter.Values)
+	case name == "subnet-id":
+		return matchValues(aws.StringValue(instance.SubnetId), filter.Values)
+	}
+
+	return true
+}
+
+func (c *EC2) findInstance(instanceID *string) (*ec2.Instance, error) {
+	if instance, found := c.Instances[aws.StringValue(instanceID)]; found {
+		return instance, nil
+	}
+
+	return nil, awserr.New("InvalidInstanceID.NotFound", fmt.Sprintf("The instance ID '%s' does not exist", aws.StringValue(instanceID)), nil)
+}
+
+func (c *EC2) availabilityZone(subnetID string) string {
+	if subnet, found := c.Subnets[subnetID]; found {
+		return aws.StringValue(subnet.AvailabilityZone)
+	}
+
+	if len(subnetID) > 0 {
+		return c.Region + string(rune('a'+int(subnetID[len(subnetID)-1])%3))
+	}
+
+	return c.Region + "a"
+}
+
+// DescribeInstanceStatusWithContext return the status and the scheduled events of running instances
+func (c *EC2) DescribeInstanceStatusWithContext(ctx aws.Context, input *ec2.DescribeInstanceStatusInput, opts ...request.Option) (*ec2.DescribeInstanceStatusOutput, error) {
+	c.Lock()
+	defer c.Unlock()
+
+	statuses := make([]*ec2.InstanceStatus, 0, len(input.InstanceIds))
+
+	for _, instanceID := range input.InstanceIds {
+		instance, found := c.Instances[aws.StringValue(instanceID)]
+
+		if !found {
+			return nil, awserr.New("InvalidInstanceID.NotFound", fmt.Sprintf("The instance ID '%s' does not exist", aws.StringValue(instanceID)), nil)
+		}
+
+		if aws.Int64Value(instance.State.Code) == 16 {
+			statuses = append(statuses, &ec2.InstanceStatus{
+				InstanceId:       instance.InstanceId,
+				AvailabilityZone: instance.Placement.AvailabilityZone,
+				InstanceState:    instance.State,
+				Events:           c.ScheduledEvents[aws.StringValue(instanceID)],
+			})
+		}
+	}
+
+	return &ec2.DescribeInstanceStatusOutput{
+		InstanceStatuses: statuses,
+	}, nil
+}
+
+// DescribeInstancesWithContext return instances matching ids and filters
+func (c *EC2) DescribeInstancesWithContext(ctx aws.Context, input *ec2.DescribeInstancesInput, opts ...request.Option) (*ec2.DescribeInstancesOutput, error) {
+	return c.DescribeInstances(input)
+}
+
+// DescribeInstances return instances matching ids and filters
+func (c *EC2) DescribeInstances(input *ec2.DescribeInstancesInput) (*ec2.DescribeInstancesOutput, error) {
+	c.Lock()
+	defer c.Unlock()
+
+	c.DescribeInstancesCalls++
+
+	instances := make([]*ec2.Instance, 0, len(c.Instances))
+
+	if len(input.InstanceIds) > 0 {
+		for _, instanceID := range input.InstanceIds {
+			if instance, err := c.findInstance(instanceID); err != nil {
+				return nil, err
+			} else {
+				instances = append(instances, instance)
+			}
+		}
+	} else {
+		for _, instance := range c.Instances {
+			instances = append(instances, instance)
+		}
+
+		sort.Slice(instances, func(i, j int) bool {
+			return aws.StringValue(instances[i].InstanceId) < aws.StringValue(instances[j].InstanceId)
+		})
+	}
+
+	reservations := make([]*ec2.Reservation, 0, len(instances))
+
+	for _, instance := range instances {
+		matched := true
+
+		for _, filter := range input.Filters {
+			if matched = matchFilter(instance, filter); !matched {
+				break
+			}
+		}
+
+		if matched {
+			reservations = append(reservations, &ec2.Reservation{
+				Instances: []*ec2.Instance{
+					instance,
+				},
+			})
+		}
+	}
+
+	// NextToken is the offset of the next page
+	var offset int
+	var nextToken *string
+
+	fmt.Sscanf(aws.StringValue(input.NextToken), "%d", &offset)
+
+	if offset > len(reservations) {
+		offset = len(reservations)
+	}
+
+	reservations = reservations[offset:]
+
+	if maxResults := int(aws.Int64Value(input.MaxResults)); maxResults > 0 && len(reservations) > maxResults {
+		reservations = reservations[:maxResults]
+		nextToken = aws.String(fmt.Sprintf("%d", offset+maxResults))
+	}
+
+	return &ec2.DescribeInstancesOutput{
+		Reservations: reservations,
+		NextToken:    nextToken,
+	}, nil
+}
+
+// DescribeNetworkInterfaces return no network interface
+func (c *EC2) DescribeNetworkInterfaces(input *ec2.DescribeNetworkInterfacesInput) (*ec2.DescribeNetworkInterfacesOutput, error) {
+	return &ec2.DescribeNetworkInterfacesOutput{}, nil
+}
+
+// DescribeLaunchTemplateVersionsWithContext return the launch template registered by id or name
+func (c *EC2) DescribeLaunchTemplateVersionsWithContext(ctx aws.Context, input *ec2.DescribeLaunchTemplateVersionsInput, opts ...request.Option) (*ec2.DescribeLaunchTemplateVersionsOutput, error) {
+	c.Lock()
+	defer c.Unlock()
+
+	name := aws.StringValue(input.LaunchTemplateId)
+
+	if len(name) == 0 {
+		name = aws.StringValue(input.LaunchTemplateName)
+	}
+
+	if data, found := c.LaunchTemplates[name]; found {
+		return &ec2.DescribeLaunchTemplateVersionsOutput{
+			LaunchTemplateVersions: []*ec2.LaunchTemplateVersion{
+				{
+					LaunchTemplateId:   input.LaunchTemplateId,
+					LaunchTemplateName: input.LaunchTemplateName,
+					LaunchTemplateData: data,
+				},
+			},
+		}, nil
+	}
+
+	return nil, awserr.New("InvalidLaunchTemplateName.NotFoundException", fmt.Sprintf("The specified launch template, %s, does not exist", name), nil)
+}
+
+// DescribeSubnetsWithContext return the subnets, unregistered subnets are spread over 3 zones
+func (c *EC2) DescribeSubnetsWithContext(ctx aws.Context, input *ec2.DescribeSubnetsInput, opts ...request.Option) (*ec2.DescribeSubnetsOutput, error) {
+	c.Lock()
+	defer c.Unlock()
+
+	subnets := make([]*ec2.Subnet, 0, len(input.SubnetIds))
+
+	// Subnets matching filters
+	if len(input.SubnetIds) == 0 {
+		for _, subnet := range c.Subnets {
+			matched := true
+
+			for _, filter := range input.Filters {
+				if ok, handled := matchTagFilter(subnet.Tags, filter); handled && !ok {
+					matched = false
+				} else if aws.StringValue(filter.Name) == "state" && !matchValues(aws.StringValue(subnet.State), filter.Values) {
+					matched = false
+				} else if aws.StringValue(filter.Name) == "vpc-id" && !matchValues(aws.StringValue(subnet.VpcId), filter.Values) {
+					matched = false
+				}
+			}
+
+			if matched {
+				subnets = append(subnets, subnet)
+			}
+		}
+
+		return &ec2.DescribeSubnetsOutput{
+			Subnets: subnets,
+		}, nil
+	}
+
+	for _, subnetID := range input.SubnetIds {
+		if subnet, found := c.Subnets[aws.StringValue(subnetID)]; found {
+			subnets = append(subnets, subnet)
+		} else {
+			subnets = append(subnets, &ec2.Subnet{
+				SubnetId:         subnetID,
+				AvailabilityZone: aws.String(c.availabilityZone(aws.StringValue(subnetID))),
+			})
+		}
+	}
+
+	return &ec2.DescribeSubnetsOutput{
+		Subnets: subnets,
+	}, nil
+}
+
+// DescribeSecurityGroupsWithContext return the registered security groups matching ids and tag filters
+func (c *EC2) DescribeSecurityGroupsWithContext(ctx aws.Context, input *ec2.DescribeSecurityGroupsInput, opts ...request.Option) (*ec2.DescribeSecurityGroupsOutput, error) {
+	c.Lock()
+	defer c.Unlock()
+
+	c.DescribeSecurityGroupsCalls++
+
+	groups := make([]*ec2.SecurityGroup, 0)
+
+	for _, group := range c.SecurityGroups {
+		matched := len(input.GroupIds) == 0 || matchValues(aws.StringValue(group.GroupId), input.GroupIds)
+
+		for _, filter := range input.Filters {
+			if ok, handled := matchTagFilter(group.Tags, filter); handled && !ok {
+				matched = false
+			} else if aws.StringValue(filter.Name) == "vpc-id" && !matchValues(aws.StringValue(group.VpcId), filter.Values) {
+				matched = false
+			}
+		}
+
+		if matched {
+			groups = append(groups, group)
+		}
+	}
+
+	return &ec2.DescribeSecurityGroupsOutput{
+		SecurityGroups: groups,
+	}, nil
+}
+
+// DescribeInstanceTypesWithContext return the registered instance types sorted by name
+func (c *EC2) DescribeInstanceTypesWithContext(ctx aws.Context, input *ec2.DescribeInstanceTypesInput, opts ...request.Option) (*ec2.DescribeInstanceTypesOutput, error) {
+	c.Lock()
+	defer c.Unlock()
+
+	c.DescribeInstanceTypesCalls++
+
+	if c.DescribeInstanceTypesError != nil {
+		return nil, c.DescribeInstanceTypesError
+	}
+
+	instanceTypes := make([]*ec2.InstanceTypeInfo, 0, len(c.InstanceTypes))
+
+	if len(input.InstanceTypes) > 0 {
+		for _, instanceType := range input.InstanceTypes {
+			if info, found := c.InstanceTypes[aws.StringValue(instanceType)]; found {
+				instanceTypes = append(instanceTypes, info)
+			}
+		}
+	} else {
+		for _, info := range c.InstanceTypes {
+			instanceTypes = append(instanceTypes, info)
+		}
+	}
+
+	sort.Slice(instanceTypes, func(i, j int) bool {
+		return aws.StringValue(instanceTypes[i].InstanceType) < aws.StringValue(instanceTypes[j].InstanceType)
+	})
+
+	// NextToken is the offset of the next page
+	var offset int
+	var nextToken *string
+
+	fmt.Sscanf(aws.StringValue(input.NextToken), "%d", &offset)
+
+	if offset > len(instanceTypes) {
+		offset = len(instanceTypes)
+	}
+
+	instanceTypes = instanceTypes[offset:]
+
+	if maxResults := int(aws.Int64Value(input.MaxResults)); maxResults > 0 && len(instanceTypes) > maxResults {
+		instanceTypes = instanceTypes[:maxResults]
+		nextToken = aws.String(fmt.Sprintf("%d", offset+maxResults))
+	}
+
+	return &ec2.DescribeInstanceTypesOutput{
+		InstanceTypes: instanceTypes,
+		NextToken:     nextToken,
+	}, nil
+}
+
+// DescribeImagesWithContext return the registered images
+func (c *EC2) DescribeImagesWithContext(ctx aws.Context, input *ec2.DescribeImagesInput, opts ...request.Option) (*ec2.DescribeImagesOutput, error) {
+	c.Lock()
+	defer c.Unlock()
+
+	images := make([]*ec2.Image, 0, len(input.ImageIds))
+
+	if len(input.ImageIds) == 0 {
+		for _, image := range c.Images {
+			if matchImage(image, input) {
+				images = append(images, image)
+			}
+		}
+
+		sort.Slice(images, func(i, j int) bool {
+			return aws.StringValue(images[i].ImageId) < aws.StringValue(images[j].ImageId)
+		})
+	}
+
+	for _, imageID := range input.ImageIds {
+		if image, found := c.Images[aws.StringValue(imageID)]; found {
+			images = append(images, image)
+		} else {
+			return nil, awserr.New("InvalidAMIID.NotFound", fmt.Sprintf("The image id '[%s]' does not exist", aws.StringValue(imageID)), nil)
+		}
+	}
+
+	return &ec2.DescribeImagesOutput{
+		Images: images,
+	}, nil
+}
+
+// matchImage return true if the image match owners and filters on name, architecture, state
+func matchImage(image *ec2.Image, input *ec2.DescribeImagesInput) bool {
+	if len(input.Owners) > 0 && !matchValues(aws.StringValue(image.OwnerId), input.Owners) {
+		return false
+	}
+
+	for _, filter := range input.Filters {
+		var value string
+
+		switch aws.StringValue(filter.Name) {
+		case "name":
+			value = aws.StringValue(image.Name)
+		case "architecture":
+			value = aws.StringValue(image.Architecture)
+		case "state":
+			value = aws.StringValue(image.State)
+		default:
+			continue
+		}
+
+		if !matchValues(value, filter.Values) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// RunInstancesWithContext create a running instance
+func (c *EC2) RunInstancesWithContext(ctx aws.Context, input *ec2.RunInstancesInput, opts ...request.Option) (*ec2.Reservation, error) {
+	c.Lock()
+	defer c.Unlock()
+
+	var subnetID string
+
+	resourceTags := make(map[string][]*ec2.Tag)
+
+	for _, tagSpec := range input.TagSpecifications {
+		resourceTags[aws.StringValue(tagSpec.ResourceType)] = append(resourceTags[aws.StringValue(tagSpec.ResourceType)], tagSpec.Tags...)
+	}
+
+	tags := append(make([]*ec2.Tag, 0), resourceTags[ec2.ResourceTypeInstance]...)
+
+	if len(input.NetworkInterfaces) > 0 {
+		subnetID = aws.StringValue(input.NetworkInterfaces[0].SubnetId)
+	} else {
+		subnetID = aws.StringValue(input.SubnetId)
+	}
+
+	instanceType := aws.StringValue(input.InstanceType)
+	zone := c.availabilityZone(subnetID)
+
+	if c.InsufficientCapacity[instanceType] || c.InsufficientCapacity[instanceType+"/"+zone] {
+		return nil, awserr.New("InsufficientInstanceCapacity", fmt.Sprintf("We currently do not have sufficient %s capacity in the Availability Zone you requested (%s)", instanceType, zone), nil)
+	}
+
+	var reservationID *string
+
+	if spec := input.CapacityReservationSpecification; spec != nil && spec.CapacityReservationTarget != nil {
+		target := spec.CapacityReservationTarget
+
+		if reservationID = target.CapacityReservationId; reservationID == nil {
+			reservationID = target.CapacityReservationResourceGroupArn
+		}
+
+		if c.ExhaustedReservations[aws.StringValue(reservationID)] {
+			return nil, awserr.New("ReservationCapacityExceeded", fmt.Sprintf("The requested reservation %s has insufficient capacity", aws.StringValue(reservationID)), nil)
+		}
+	}
+
+	c.nextInstanceID++
+
+	instanceID := fmt.Sprintf("i-%017x", c.nextInstanceID)
+
+	instance := &ec2.Instance{
+		InstanceId:          aws.String(instanceID),
+		InstanceType:        input.InstanceType,
+		ImageId:             input.ImageId,
+		KeyName:             input.KeyName,
+		LaunchTime:          aws.Time(time.Now()),
+		PrivateIpAddress:    aws.String(fmt.Sprintf("10.0.%d.%d", c.nextInstanceID/250, c.nextInstanceID%250+1)),
+		SubnetId:            aws.String(subnetID),
+		Tags:                tags,
+		BlockDeviceMappings: make([]*ec2.InstanceBlockDeviceMapping, 0, len(input.BlockDeviceMappings)),
+		Placement: &ec2.Placement{
+			AvailabilityZone: aws.String(zone),
+		},
+		CapacityReservationId: reservationID,
+	}
+
+	if placement := input.Placement; placement != nil {
+		instance.Placement.GroupName = placement.GroupName
+		instance.Placement.PartitionNumber = placement.PartitionNumber
+		instance.Placement.Tenancy = placement.Tenancy
+		instance.Placement.HostResourceGroupArn = placement.HostResourceGroupArn
+	}
+
+	if options := input.MetadataOptions; options != nil {
+		instance.MetadataOptions = &ec2.InstanceMetadataOptionsResponse{
+			HttpTokens:              options.HttpTokens,
+			HttpPutResponseHopLimit: options.HttpPutResponseHopLimit,
+			HttpEndpoint:            options.HttpEndpoint,
+			InstanceMetadataTags:    options.InstanceMetadataTags,
+		}
+	}
+
+	for index, mapping := range input.BlockDeviceMappings {
+		instance.BlockDeviceMappings = append(instance.BlockDeviceMappings, &ec2.InstanceBlockDeviceMapping{
+			DeviceName: mapping.DeviceName,
+			Ebs: &ec2.EbsInstanceBlockDevice{
+				DeleteOnTermination: mapping.Ebs.DeleteOnTermination,
+				Status:              aws.String(ec2.AttachmentStatusAttached),
+				VolumeId:            aws.String(fmt.Sprintf("vol-%08x%09x", c.nextInstanceID, index)),
+			},
+		})
+
+		if volumeTags, found := resourceTags[ec2.ResourceTypeVolume]; found {
+			c.ResourceTags[fmt.Sprintf("vol-%08x%09x", c.nextInstanceID, index)] = volumeTags
+		}
+	}
+
+	c.BlockDevices[instanceID] = input.BlockDeviceMappings
+
+	for index, inf := range input.NetworkInterfaces {
+		networkInterface := &ec2.InstanceNetworkInterface{
+			NetworkInterfaceId: aws.String(fmt.Sprintf("eni-%08x%09x", c.nextInstanceID, index)),
+			SubnetId:           inf.SubnetId,
+			Attachment: &ec2.InstanceNetworkInterfaceAttachment{
+				DeviceIndex: inf.DeviceIndex,
+			},
+		}
+
+		for _, groupID := range inf.Groups {
+			networkInterface.Groups = append(networkInterface.Groups, &ec2.GroupIdentifier{
+				GroupId: groupID,
+			})
+		}
+
+		for _, address := range inf.Ipv6Addresses {
+			networkInterface.Ipv6Addresses = append(networkInterface.Ipv6Addresses, &ec2.InstanceIpv6Address{
+				Ipv6Address: address.Ipv6Address,
+			})
+		}
+
+		for i := int64(0); i < aws.Int64Value(inf.Ipv6AddressCount); i++ {
+			networkInterface.Ipv6Addresses = append(networkInterface.Ipv6Addresses, &ec2.InstanceIpv6Address{
+				Ipv6Address: aws.String(fmt.Sprintf("2001:db8:%x:%x::%x", c.nextInstanceID, index, i+1)),
+			})
+		}
+
+		for i := int64(0); i < aws.Int64Value(inf.Ipv6PrefixCount); i++ {
+			networkInterface.Ipv6Prefixes = append(networkInterface.Ipv6Prefixes, &ec2.InstanceIpv6Prefix{
+				Ipv6Prefix: aws.String(fmt.Sprintf("2001:db8:%x:%x:%x::/80", c.nextInstanceID, index, i+1)),
+			})
+		}
+
+		if networkInterfaceTags, found := resourceTags[ec2.ResourceTypeNetworkInterface]; found {
+			c.ResourceTags[aws.StringValue(networkInterface.NetworkInterfaceId)] = networkInterfaceTags
+		}
+
+		instance.NetworkInterfaces = append(instance.NetworkInterfaces, networkInterface)
+	}
+
+	if input.InstanceMarketOptions != nil && aws.StringValue(input.InstanceMarketOptions.MarketType) == ec2.MarketTypeSpot {
+		instance.InstanceLifecycle = aws.String(ec2.InstanceLifecycleTypeSpot)
+	}
+
+	setState(instance, stateCodeRunning)
+
+	c.Instances[instanceID] = instance
+
+	return &ec2.Reservation{
+		Instances: []*ec2.Instance{
+			instance,
+		},
+	}, nil
+}
+
+func (c *EC2) changeState(instanceIds []*string, code int64) ([]*ec2.InstanceStateChange, error) {
+	c.Lock()
+	defer c.Unlock()
+
+	changes := make([]*ec2.InstanceStateChange, 0, len(instanceIds))
+
+	for _, instanceID := range instanceIds {
+		if instance, err := c.findInstance(instanceID); err != nil {
+			return nil, err
+		} else {
+			previous := instance.State
+
+			setState(instance, code)
+
+			changes = append(changes, &ec2.InstanceStateChange{
+				InstanceId:    instanceID,
+				PreviousState: previous,
+				CurrentState:  instance.State,
+			})
+		}
+	}
+
+	return changes, nil
+}
+
+// mergeTags set or replace tags by key
+func mergeTags(tags []*ec2.Tag, updates []*ec2.Tag) []*ec2.Tag {
+	for _, update := range updates {
+		replaced := false
+
+		for _, tag := range tags {
+			if aws.StringValue(tag.Key) == aws.StringValue(update.Key) {
+				tag.Value = update.Value
+				replaced = true
+			}
+		}
+
+		if !replaced {
+			tags = append(tags, &ec2.Tag{
+				Key:   update.Key,
+				Value: update.Value,
+			})
+		}
+	}
+
+	return tags
+}
+
+// CreateTagsWithContext add or replace tags of instances, volumes and network interfaces
+func (c *EC2) CreateTagsWithContext(ctx aws.Context, input *ec2.CreateTagsInput, opts ...request.Option) (*ec2.CreateTagsOutput, error) {
+	c.Lock()
+	defer c.Unlock()
+
+	for _, resource := range input.Resources {
+		if instance, found := c.Instances[aws.StringValue(resource)]; found {
+			instance.Tags = mergeTags(instance.Tags, input.Tags)
+		} else {
+			c.ResourceTags[aws.StringValue(resource)] = mergeTags(c.ResourceTags[aws.StringValue(resource)], input.Tags)
+		}
+	}
+
+	return &ec2.CreateTagsOutput{}, nil
+}
+
+// TerminateInstancesWithContext set instances terminated
+func (c *EC2) TerminateInstancesWithContext(ctx aws.Context, input *ec2.TerminateInstancesInput, opts ...request.Option) (*ec2.TerminateInstancesOutput, error) {
+	c.Lock()
+	err := c.TerminateInstancesError
+	c.Unlock()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if changes, err := c.changeState(input.InstanceIds, stateCodeTerminated); err != nil {
+		return nil, err
+	} else {
+		return &ec2.TerminateInstancesOutput{
+			TerminatingInstances: changes,
+		}, nil
+	}
+}
+
+// StartInstancesWithContext set instances running
+func (c *EC2) StartInstancesWithContext(ctx aws.Context, input *ec2.StartInstancesInput, opts ...request.Option) (*ec2.StartInstancesOutput, error) {
+	if changes, err := c.changeState(input.InstanceIds, stateCodeRunning); err != nil {
+		return nil, err
+	} else {
+		return &ec2.StartInstancesOutput{
+			StartingInstances: changes,
+		}, nil
+	}
+}
+
+// StopInstancesWithContext set instances stopped
+func (c *EC2) StopInstancesWithContext(ctx aws.Context, input *ec2.StopInstancesInput, opts ...request.Option) (*ec2.StopInstancesOutput, error) {
+	c.Lock()
+
+	for _, instanceID := range input.InstanceIds {
+		if instance, found := c.Instances[aws.StringValue(instanceID)]; found && aws.StringValue(instance.InstanceLifecycle) == ec2.InstanceLifecycleTypeSpot {
+			c.Unlock()
+
+			return nil, awserr.New("UnsupportedOperation", fmt.Sprintf("The instance '%s' is a spot instance and cannot be stopped", aws.StringValue(instanceID)), nil)
+		}
+	}
+
+	c.Unlock()
+
+	if changes, err := c.changeState(input.InstanceIds, stateCodeStopped); err != nil {
+		return nil, err
+	} else {
+		return &ec2.StopInstancesOutput{
+			StoppingInstances: changes,
+		}, nil
+	}
+}
+
+func (c *EC2) waitUntil(input *ec2.DescribeInstancesInput, code int64) error {
+	c.Lock()
+	defer c.Unlock()
+
+	for _, instanceID := range input.InstanceIds {
+		if instance, err := c.findInstance(instanceID); err != nil {
+			return err
+		} else if aws.Int64Value(instance.State.Code) != code {
+			return awserr.New(request.WaiterResourceNotReadyErrorCode, "failed waiting for successful resource state", nil)
+		}
+	}
+
+	return nil
+}
+
+// WaitUntilInstanceTerminated return immediately, state change is synchronous
+func (c *EC2) WaitUntilInstanceTerminated(input *ec2.DescribeInstancesInput) error {
+	return c.waitUntil(input, stateCodeTerminated)
+}
+
+// WaitUntilInstanceRunning return immediately, state change is synchronous
+func (c *EC2) WaitUntilInstanceRunning(input *ec2.DescribeInstancesInput) error {
+	return c.waitUntil(input, stateCodeRunning)
+}
+
+// WaitUntilInstanceStopped return immediately, state change is synchronous
+func (c *EC2) WaitUntilInstanceStopped(input *ec2.DescribeInstancesInput) error {
+	return c.waitUntil(input, stateCodeStopped)
+}
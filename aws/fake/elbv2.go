@@ -0,0 +1,140 @@
+package fake
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/elbv2"
+	"github.com/aws/aws-sdk-go/service/elbv2/elbv2iface"
+)
+
+// ELBv2 in-memory implementation of elbv2iface.ELBV2API.
+// Only the methods used by the autoscaler are implemented, others panic
+type ELBv2 struct {
+	elbv2iface.ELBV2API
+	sync.Mutex
+	// Targets registered targets by target group ARN
+	Targets map[string][]*elbv2.TargetDescription
+	// DrainingPolls number of DescribeTargetHealth calls reporting a deregistered target as draining
+	DrainingPolls int
+	draining      map[string]int
+}
+
+// NewELBv2 create an empty in-memory elbv2 backend
+func NewELBv2() *ELBv2 {
+	return &ELBv2{
+		Targets:  make(map[string][]*elbv2.TargetDescription),
+		draining: make(map[string]int),
+	}
+}
+
+// AddTargetGroup register an empty target group
+func (e *ELBv2) AddTargetGroup(arn string) {
+	e.Lock()
+	defer e.Unlock()
+
+	e.Targets[arn] = []*elbv2.TargetDescription{}
+}
+
+func (e *ELBv2) indexOfTarget(arn string, target *elbv2.TargetDescription) int {
+	for index, registered := range e.Targets[arn] {
+		if aws.StringValue(registered.Id) == aws.StringValue(target.Id) && aws.Int64Value(registered.Port) == aws.Int64Value(target.Port) {
+			return index
+		}
+	}
+
+	return -1
+}
+
+func targetGroupNotFound(arn string) error {
+	return awserr.New(elbv2.ErrCodeTargetGroupNotFoundException, fmt.Sprintf("Target group '%s' not found", arn), nil)
+}
+
+// RegisterTargetsWithContext add the targets to the target group
+func (e *ELBv2) RegisterTargetsWithContext(ctx aws.Context, input *elbv2.RegisterTargetsInput, opts ...request.Option) (*elbv2.RegisterTargetsOutput, error) {
+	e.Lock()
+	defer e.Unlock()
+
+	arn := aws.StringValue(input.TargetGroupArn)
+
+	if _, found := e.Targets[arn]; !found {
+		return nil, targetGroupNotFound(arn)
+	}
+
+	for _, target := range input.Targets {
+		if e.indexOfTarget(arn, target) < 0 {
+			e.Targets[arn] = append(e.Targets[arn], target)
+		}
+	}
+
+	return &elbv2.RegisterTargetsOutput{}, nil
+}
+
+// DeregisterTargetsWithContext remove the targets from the target group, they are draining for DrainingPolls calls
+func (e *ELBv2) DeregisterTargetsWithContext(ctx aws.Context, input *elbv2.DeregisterTargetsInput, opts ...request.Option) (*elbv2.DeregisterTargetsOutput, error) {
+	e.Lock()
+	defer e.Unlock()
+
+	arn := aws.StringValue(input.TargetGroupArn)
+
+	if _, found := e.Targets[arn]; !found {
+		return nil, targetGroupNotFound(arn)
+	}
+
+	for _, target := range input.Targets {
+		if index := e.indexOfTarget(arn, target); index >= 0 {
+			e.Targets[arn] = append(e.Targets[arn][:index], e.Targets[arn][index+1:]...)
+			e.draining[fmt.Sprintf("%s/%s", arn, aws.StringValue(target.Id))] = e.DrainingPolls
+		}
+	}
+
+	return &elbv2.DeregisterTargetsOutput{}, nil
+}
+
+// DescribeTargetHealthWithContext return healthy for registered targets, draining or unused for deregistered targets
+func (e *ELBv2) DescribeTargetHealthWithContext(ctx aws.Context, input *elbv2.DescribeTargetHealthInput, opts ...request.Option) (*elbv2.DescribeTargetHealthOutput, error) {
+	e.Lock()
+	defer e.Unlock()
+
+	arn := aws.StringValue(input.TargetGroupArn)
+
+	if _, found := e.Targets[arn]; !found {
+		return nil, targetGroupNotFound(arn)
+	}
+
+	targets := input.Targets
+
+	if len(targets) == 0 {
+		targets = e.Targets[arn]
+	}
+
+	descriptions := make([]*elbv2.TargetHealthDescription, 0, len(targets))
+
+	for _, target := range targets {
+		key := fmt.Sprintf("%s/%s", arn, aws.StringValue(target.Id))
+		state := elbv2.TargetHealthStateEnumHealthy
+
+		if e.indexOfTarget(arn, target) < 0 {
+			if e.draining[key] > 0 {
+				e.draining[key]--
+				state = elbv2.TargetHealthStateEnumDraining
+			} else {
+				state = elbv2.TargetHealthStateEnumUnused
+			}
+		}
+
+		descriptions = append(descriptions, &elbv2.TargetHealthDescription{
+			Target: target,
+			TargetHealth: &elbv2.TargetHealth{
+				State: aws.String(state),
+			},
+		})
+	}
+
+	return &elbv2.DescribeTargetHealthOutput{
+		TargetHealthDescriptions: descriptions,
+	}, nil
+}
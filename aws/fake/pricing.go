@@ -0,0 +1,97 @@
+package fake
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/pricing"
+	"github.com/aws/aws-sdk-go/service/pricing/pricingiface"
+)
+
+// Pricing in-memory implementation of pricingiface.PricingAPI.
+// Only the methods used by the autoscaler are implemented, others panic
+type Pricing struct {
+	pricingiface.PricingAPI
+	sync.Mutex
+	// Prices on-demand hourly price in USD by region and instance type
+	Prices map[string]map[string]float64
+	// GetProductsError simulate an unreachable api
+	GetProductsError error
+	// GetProductsCalls count calls to GetProducts
+	GetProductsCalls int
+}
+
+// NewPricing create an empty in-memory price list backend
+func NewPricing() *Pricing {
+	return &Pricing{
+		Prices: make(map[string]map[string]float64),
+	}
+}
+
+// SetPrice register the on-demand price of an instance type
+func (p *Pricing) SetPrice(region, instanceType string, price float64) {
+	p.Lock()
+	defer p.Unlock()
+
+	if _, found := p.Prices[region]; !found {
+		p.Prices[region] = make(map[string]float64)
+	}
+
+	p.Prices[region][instanceType] = price
+}
+
+// GetProductsWithContext return the product matching the region and instance type filters, in price list format
+func (p *Pricing) GetProductsWithContext(ctx aws.Context, input *pricing.GetProductsInput, opts ...request.Option) (*pricing.GetProductsOutput, error) {
+	p.Lock()
+	defer p.Unlock()
+
+	p.GetProductsCalls++
+
+	if p.GetProductsError != nil {
+		return nil, p.GetProductsError
+	}
+
+	var region, instanceType string
+
+	for _, filter := range input.Filters {
+		switch aws.StringValue(filter.Field) {
+		case "regionCode":
+			region = aws.StringValue(filter.Value)
+		case "instanceType":
+			instanceType = aws.StringValue(filter.Value)
+		}
+	}
+
+	output := &pricing.GetProductsOutput{}
+
+	if price, found := p.Prices[region][instanceType]; found {
+		output.PriceList = []aws.JSONValue{
+			{
+				"product": map[string]interface{}{
+					"attributes": map[string]interface{}{
+						"instanceType": instanceType,
+						"regionCode":   region,
+					},
+				},
+				"terms": map[string]interface{}{
+					"OnDemand": map[string]interface{}{
+						"SKU.JRTCKXETXF": map[string]interface{}{
+							"priceDimensions": map[string]interface{}{
+								"SKU.JRTCKXETXF.6YS6EN2CT7": map[string]interface{}{
+									"unit": "Hrs",
+									"pricePerUnit": map[string]interface{}{
+										"USD": fmt.Sprintf("%.10f", price),
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		}
+	}
+
+	return output, nil
+}
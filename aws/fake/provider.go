@@ -0,0 +1,95 @@
+package fake
+
+import (
+	"sync"
+
+	"github.com/Fred78290/kubernetes-aws-autoscaler/aws"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+	"github.com/aws/aws-sdk-go/service/elbv2/elbv2iface"
+	"github.com/aws/aws-sdk-go/service/pricing/pricingiface"
+	"github.com/aws/aws-sdk-go/service/route53/route53iface"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	"github.com/aws/aws-sdk-go/service/sqs/sqsiface"
+	"github.com/aws/aws-sdk-go/service/ssm/ssmiface"
+)
+
+// ClientProvider return one in-memory ec2 backend per region, a shared route53, price list, ssm, elbv2, sqs and s3 backend
+type ClientProvider struct {
+	sync.Mutex
+	EC2     *EC2
+	Route53 *Route53
+	Pricing *Pricing
+	SSM     *SSM
+	ELBv2   *ELBv2
+	SQS     *SQS
+	S3      *S3
+	regions map[string]*EC2
+}
+
+// NewClientProvider create a client provider with empty in-memory backends, EC2 is the backend of the default region
+func NewClientProvider(region string) *ClientProvider {
+	backend := NewEC2(region)
+
+	return &ClientProvider{
+		EC2:     backend,
+		Route53: NewRoute53(),
+		Pricing: NewPricing(),
+		SSM:     NewSSM(),
+		ELBv2:   NewELBv2(),
+		SQS:     NewSQS(),
+		S3:      NewS3(),
+		regions: map[string]*EC2{
+			region: backend,
+		},
+	}
+}
+
+// GetRegionEC2 return the in-memory ec2 backend for the region
+func (p *ClientProvider) GetRegionEC2(region string) *EC2 {
+	p.Lock()
+	defer p.Unlock()
+
+	backend, found := p.regions[region]
+
+	if !found {
+		backend = NewEC2(region)
+		p.regions[region] = backend
+	}
+
+	return backend
+}
+
+// GetEC2Client return the in-memory ec2 backend of the configuration region
+func (p *ClientProvider) GetEC2Client(conf *aws.Configuration) (ec2iface.EC2API, error) {
+	return p.GetRegionEC2(conf.Region), nil
+}
+
+// GetRoute53Client return the in-memory route53 backend
+func (p *ClientProvider) GetRoute53Client(conf *aws.Configuration) (route53iface.Route53API, error) {
+	return p.Route53, nil
+}
+
+// GetPricingClient return the in-memory price list backend
+func (p *ClientProvider) GetPricingClient(conf *aws.Configuration) (pricingiface.PricingAPI, error) {
+	return p.Pricing, nil
+}
+
+// GetSSMClient return the in-memory ssm backend
+func (p *ClientProvider) GetSSMClient(conf *aws.Configuration) (ssmiface.SSMAPI, error) {
+	return p.SSM, nil
+}
+
+// GetELBv2Client return the in-memory elbv2 backend
+func (p *ClientProvider) GetELBv2Client(conf *aws.Configuration) (elbv2iface.ELBV2API, error) {
+	return p.ELBv2, nil
+}
+
+// GetSQSClient return the in-memory sqs backend
+func (p *ClientProvider) GetSQSClient(conf *aws.Configuration) (sqsiface.SQSAPI, error) {
+	return p.SQS, nil
+}
+
+// GetS3Client return the in-memory s3 backend
+func (p *ClientProvider) GetS3Client(conf *aws.Configuration) (s3iface.S3API, error) {
+	return p.S3, nil
+}
@@ -0,0 +1,121 @@
+package fake
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/route53"
+	"github.com/aws/aws-sdk-go/service/route53/route53iface"
+)
+
+// Route53 in-memory implementation of route53iface.Route53API.
+// Only the methods used by the autoscaler are implemented, others panic
+type Route53 struct {
+	route53iface.Route53API
+	sync.Mutex
+	Zones        map[string]map[string]*route53.ResourceRecordSet
+	nextChangeID int
+}
+
+// NewRoute53 create an empty in-memory route53 backend
+func NewRoute53() *Route53 {
+	return &Route53{
+		Zones: make(map[string]map[string]*route53.ResourceRecordSet),
+	}
+}
+
+func recordKey(name, recordType string) string {
+	return fmt.Sprintf("%s/%s", strings.TrimSuffix(name, "."), recordType)
+}
+
+// ListResourceRecordSets list records of the zone starting at the given name and type
+func (r *Route53) ListResourceRecordSets(input *route53.ListResourceRecordSetsInput) (*route53.ListResourceRecordSetsOutput, error) {
+	r.Lock()
+	defer r.Unlock()
+
+	zone := r.Zones[aws.StringValue(input.HostedZoneId)]
+	keys := make([]string, 0, len(zone))
+	start := recordKey(aws.StringValue(input.StartRecordName), aws.StringValue(input.StartRecordType))
+
+	for key := range zone {
+		if key >= start {
+			keys = append(keys, key)
+		}
+	}
+
+	sort.Strings(keys)
+
+	if input.MaxItems != nil {
+		var maxItems int
+
+		fmt.Sscanf(*input.MaxItems, "%d", &maxItems)
+
+		if maxItems > 0 && len(keys) > maxItems {
+			keys = keys[:maxItems]
+		}
+	}
+
+	records := make([]*route53.ResourceRecordSet, 0, len(keys))
+
+	for _, key := range keys {
+		records = append(records, zone[key])
+	}
+
+	return &route53.ListResourceRecordSetsOutput{
+		ResourceRecordSets: records,
+	}, nil
+}
+
+// ChangeResourceRecordSets apply the change batch to the zone
+func (r *Route53) ChangeResourceRecordSets(input *route53.ChangeResourceRecordSetsInput) (*route53.ChangeResourceRecordSetsOutput, error) {
+	r.Lock()
+	defer r.Unlock()
+
+	zoneID := aws.StringValue(input.HostedZoneId)
+	zone, found := r.Zones[zoneID]
+
+	if !found {
+		zone = make(map[string]*route53.ResourceRecordSet)
+		r.Zones[zoneID] = zone
+	}
+
+	for _, change := range input.ChangeBatch.Changes {
+		record := change.ResourceRecordSet
+		key := recordKey(aws.StringValue(record.Name), aws.StringValue(record.Type))
+
+		switch aws.StringValue(change.Action) {
+		case route53.ChangeActionCreate:
+			if _, found := zone[key]; found {
+				return nil, awserr.New(route53.ErrCodeInvalidChangeBatch, fmt.Sprintf("record %s already exists", key), nil)
+			}
+
+			zone[key] = record
+		case route53.ChangeActionUpsert:
+			zone[key] = record
+		case route53.ChangeActionDelete:
+			if _, found := zone[key]; !found {
+				return nil, awserr.New(route53.ErrCodeInvalidChangeBatch, fmt.Sprintf("record %s not found", key), nil)
+			}
+
+			delete(zone, key)
+		}
+	}
+
+	r.nextChangeID++
+
+	return &route53.ChangeResourceRecordSetsOutput{
+		ChangeInfo: &route53.ChangeInfo{
+			Id:     aws.String(fmt.Sprintf("/change/C%d", r.nextChangeID)),
+			Status: aws.String(route53.ChangeStatusInsync),
+		},
+	}, nil
+}
+
+// WaitUntilResourceRecordSetsChanged return immediately, changes are synchronous
+func (r *Route53) WaitUntilResourceRecordSetsChanged(input *route53.GetChangeInput) error {
+	return nil
+}
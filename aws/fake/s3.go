@@ -0,0 +1,58 @@
+package fake
+
+import (
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+)
+
+// S3 in-memory implementation of s3iface.S3API, any bucket exists.
+// Only the methods used by the autoscaler are implemented, others panic
+type S3 struct {
+	s3iface.S3API
+	sync.Mutex
+	// Objects content by bucket/key
+	Objects map[string][]byte
+}
+
+// NewS3 create an empty in-memory s3 backend
+func NewS3() *S3 {
+	return &S3{
+		Objects: make(map[string][]byte),
+	}
+}
+
+func objectPath(bucket, key *string) string {
+	return fmt.Sprintf("%s/%s", aws.StringValue(bucket), aws.StringValue(key))
+}
+
+// PutObjectWithContext store the object body
+func (s *S3) PutObjectWithContext(ctx aws.Context, input *s3.PutObjectInput, opts ...request.Option) (*s3.PutObjectOutput, error) {
+	body, err := io.ReadAll(input.Body)
+
+	if err != nil {
+		return nil, err
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	s.Objects[objectPath(input.Bucket, input.Key)] = body
+
+	return &s3.PutObjectOutput{}, nil
+}
+
+// DeleteObjectWithContext remove the object, like s3 a missing object is not an error
+func (s *S3) DeleteObjectWithContext(ctx aws.Context, input *s3.DeleteObjectInput, opts ...request.Option) (*s3.DeleteObjectOutput, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	delete(s.Objects, objectPath(input.Bucket, input.Key))
+
+	return &s3.DeleteObjectOutput{}, nil
+}
@@ -0,0 +1,116 @@
+package fake
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/aws/aws-sdk-go/service/sqs/sqsiface"
+)
+
+// SQS in-memory implementation of sqsiface.SQSAPI, received messages are invisible until deleted.
+// Only the methods used by the autoscaler are implemented, others panic
+type SQS struct {
+	sqsiface.SQSAPI
+	sync.Mutex
+	// Queues messages not yet received by queue url
+	Queues map[string][]*sqs.Message
+	// InFlight messages received and not deleted by receipt handle
+	InFlight      map[string]*sqs.Message
+	nextMessageID int
+}
+
+// NewSQS create an empty in-memory sqs backend
+func NewSQS() *SQS {
+	return &SQS{
+		Queues:   make(map[string][]*sqs.Message),
+		InFlight: make(map[string]*sqs.Message),
+	}
+}
+
+// AddQueue register an empty queue
+func (s *SQS) AddQueue(queueURL string) {
+	s.Lock()
+	defer s.Unlock()
+
+	s.Queues[queueURL] = []*sqs.Message{}
+}
+
+// PushMessage add a message to the queue
+func (s *SQS) PushMessage(queueURL, body string) {
+	s.Lock()
+	defer s.Unlock()
+
+	s.nextMessageID++
+
+	s.Queues[queueURL] = append(s.Queues[queueURL], &sqs.Message{
+		MessageId: aws.String(fmt.Sprintf("msg-%08x", s.nextMessageID)),
+		Body:      aws.String(body),
+	})
+}
+
+func queueNotFound(queueURL string) error {
+	return awserr.New(sqs.ErrCodeQueueDoesNotExist, fmt.Sprintf("The specified queue %s does not exist", queueURL), nil)
+}
+
+// ReceiveMessageWithContext return at most MaxNumberOfMessages messages without waiting
+func (s *SQS) ReceiveMessageWithContext(ctx aws.Context, input *sqs.ReceiveMessageInput, opts ...request.Option) (*sqs.ReceiveMessageOutput, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	queueURL := aws.StringValue(input.QueueUrl)
+	messages, found := s.Queues[queueURL]
+
+	if !found {
+		return nil, queueNotFound(queueURL)
+	}
+
+	count := int(aws.Int64Value(input.MaxNumberOfMessages))
+
+	if count <= 0 {
+		count = 1
+	}
+
+	if count > len(messages) {
+		count = len(messages)
+	}
+
+	received := messages[:count]
+
+	s.Queues[queueURL] = messages[count:]
+
+	for _, message := range received {
+		message.ReceiptHandle = aws.String("receipt-" + aws.StringValue(message.MessageId))
+
+		s.InFlight[aws.StringValue(message.ReceiptHandle)] = message
+	}
+
+	return &sqs.ReceiveMessageOutput{
+		Messages: received,
+	}, nil
+}
+
+// DeleteMessageWithContext remove the received message
+func (s *SQS) DeleteMessageWithContext(ctx aws.Context, input *sqs.DeleteMessageInput, opts ...request.Option) (*sqs.DeleteMessageOutput, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	queueURL := aws.StringValue(input.QueueUrl)
+
+	if _, found := s.Queues[queueURL]; !found {
+		return nil, queueNotFound(queueURL)
+	}
+
+	receiptHandle := aws.StringValue(input.ReceiptHandle)
+
+	if _, found := s.InFlight[receiptHandle]; !found {
+		return nil, awserr.New(sqs.ErrCodeReceiptHandleIsInvalid, fmt.Sprintf("The receipt handle %s is not valid", receiptHandle), nil)
+	}
+
+	delete(s.InFlight, receiptHandle)
+
+	return &sqs.DeleteMessageOutput{}, nil
+}
@@ -0,0 +1,217 @@
+package fake
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/aws/aws-sdk-go/service/ssm/ssmiface"
+)
+
+// SSM in-memory implementation of ssmiface.SSMAPI, the agent of any instance is online unless declared offline.
+// Commands complete immediately with the result of CommandHandler, or succeed without output.
+// Only the methods used by the autoscaler are implemented, others panic
+type SSM struct {
+	ssmiface.SSMAPI
+	sync.Mutex
+	Parameters map[string]string
+	// ParameterTiers tier of parameters put by name
+	ParameterTiers map[string]string
+	// GetParameterCalls count calls to GetParameter
+	GetParameterCalls int
+	// Offline instances whose agent is not online
+	Offline map[string]bool
+	// CommandHandler return the output of the commands sent to the instance, an error fail the command
+	CommandHandler func(instanceID string, commands []string) (string, error)
+	// Commands sent by instance
+	Commands map[string][][]string
+	// InProgressPolls number of GetCommandInvocation calls returning InProgress before the command result
+	InProgressPolls int
+	// GetCommandInvocationCalls count calls to GetCommandInvocation
+	GetCommandInvocationCalls int
+	invocations               map[string]*ssm.GetCommandInvocationOutput
+	pendingPolls              map[string]int
+	nextCommandID             int
+}
+
+// NewSSM create an empty in-memory ssm backend
+func NewSSM() *SSM {
+	return &SSM{
+		Parameters:     make(map[string]string),
+		ParameterTiers: make(map[string]string),
+		Offline:        make(map[string]bool),
+		Commands:       make(map[string][][]string),
+		invocations:    make(map[string]*ssm.GetCommandInvocationOutput),
+		pendingPolls:   make(map[string]int),
+	}
+}
+
+func invocationKey(commandID, instanceID *string) string {
+	return fmt.Sprintf("%s/%s", aws.StringValue(commandID), aws.StringValue(instanceID))
+}
+
+// GetParameterWithContext return the registered parameter
+func (s *SSM) GetParameterWithContext(ctx aws.Context, input *ssm.GetParameterInput, opts ...request.Option) (*ssm.GetParameterOutput, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	s.GetParameterCalls++
+
+	name := aws.StringValue(input.Name)
+
+	if value, found := s.Parameters[name]; found {
+		return &ssm.GetParameterOutput{
+			Parameter: &ssm.Parameter{
+				Name:  input.Name,
+				Type:  aws.String(ssm.ParameterTypeString),
+				Value: aws.String(value),
+			},
+		}, nil
+	}
+
+	return nil, awserr.New(ssm.ErrCodeParameterNotFound, fmt.Sprintf("Parameter %s not found", name), nil)
+}
+
+// PutParameterWithContext store the parameter value
+func (s *SSM) PutParameterWithContext(ctx aws.Context, input *ssm.PutParameterInput, opts ...request.Option) (*ssm.PutParameterOutput, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	name := aws.StringValue(input.Name)
+
+	if _, found := s.Parameters[name]; found && !aws.BoolValue(input.Overwrite) {
+		return nil, awserr.New(ssm.ErrCodeParameterAlreadyExists, fmt.Sprintf("Parameter %s already exists", name), nil)
+	}
+
+	s.Parameters[name] = aws.StringValue(input.Value)
+	s.ParameterTiers[name] = aws.StringValue(input.Tier)
+
+	return &ssm.PutParameterOutput{
+		Tier:    input.Tier,
+		Version: aws.Int64(1),
+	}, nil
+}
+
+// DeleteParameterWithContext remove the parameter
+func (s *SSM) DeleteParameterWithContext(ctx aws.Context, input *ssm.DeleteParameterInput, opts ...request.Option) (*ssm.DeleteParameterOutput, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	name := aws.StringValue(input.Name)
+
+	if _, found := s.Parameters[name]; !found {
+		return nil, awserr.New(ssm.ErrCodeParameterNotFound, fmt.Sprintf("Parameter %s not found", name), nil)
+	}
+
+	delete(s.Parameters, name)
+	delete(s.ParameterTiers, name)
+
+	return &ssm.DeleteParameterOutput{}, nil
+}
+
+// DescribeInstanceInformationWithContext return the agent status of the instances filtered by InstanceIds
+func (s *SSM) DescribeInstanceInformationWithContext(ctx aws.Context, input *ssm.DescribeInstanceInformationInput, opts ...request.Option) (*ssm.DescribeInstanceInformationOutput, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	infos := make([]*ssm.InstanceInformation, 0)
+
+	for _, filter := range input.Filters {
+		if aws.StringValue(filter.Key) != "InstanceIds" {
+			continue
+		}
+
+		for _, instanceID := range filter.Values {
+			status := ssm.PingStatusOnline
+
+			if s.Offline[aws.StringValue(instanceID)] {
+				status = ssm.PingStatusConnectionLost
+			}
+
+			infos = append(infos, &ssm.InstanceInformation{
+				InstanceId: instanceID,
+				PingStatus: aws.String(status),
+			})
+		}
+	}
+
+	return &ssm.DescribeInstanceInformationOutput{
+		InstanceInformationList: infos,
+	}, nil
+}
+
+// SendCommandWithContext run the commands of AWS-RunShellScript document on each online instance
+func (s *SSM) SendCommandWithContext(ctx aws.Context, input *ssm.SendCommandInput, opts ...request.Option) (*ssm.SendCommandOutput, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	commands := aws.StringValueSlice(input.Parameters["commands"])
+
+	for _, instanceID := range input.InstanceIds {
+		if s.Offline[aws.StringValue(instanceID)] {
+			return nil, awserr.New(ssm.ErrCodeInvalidInstanceId, fmt.Sprintf("Instance %s is not in a valid state", aws.StringValue(instanceID)), nil)
+		}
+	}
+
+	s.nextCommandID++
+
+	commandID := aws.String(fmt.Sprintf("cmd-%08x", s.nextCommandID))
+
+	for _, instanceID := range input.InstanceIds {
+		invocation := &ssm.GetCommandInvocationOutput{
+			CommandId:  commandID,
+			InstanceId: instanceID,
+			Status:     aws.String(ssm.CommandInvocationStatusSuccess),
+		}
+
+		if s.CommandHandler != nil {
+			if out, err := s.CommandHandler(aws.StringValue(instanceID), commands); err != nil {
+				invocation.Status = aws.String(ssm.CommandInvocationStatusFailed)
+				invocation.StandardErrorContent = aws.String(err.Error())
+			} else {
+				invocation.StandardOutputContent = aws.String(out)
+			}
+		}
+
+		s.Commands[aws.StringValue(instanceID)] = append(s.Commands[aws.StringValue(instanceID)], commands)
+		s.invocations[invocationKey(commandID, instanceID)] = invocation
+		s.pendingPolls[invocationKey(commandID, instanceID)] = s.InProgressPolls
+	}
+
+	return &ssm.SendCommandOutput{
+		Command: &ssm.Command{
+			CommandId:    commandID,
+			DocumentName: input.DocumentName,
+			InstanceIds:  input.InstanceIds,
+		},
+	}, nil
+}
+
+// GetCommandInvocationWithContext return the result of the command on the instance
+func (s *SSM) GetCommandInvocationWithContext(ctx aws.Context, input *ssm.GetCommandInvocationInput, opts ...request.Option) (*ssm.GetCommandInvocationOutput, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	s.GetCommandInvocationCalls++
+
+	key := invocationKey(input.CommandId, input.InstanceId)
+
+	if invocation, found := s.invocations[key]; found {
+		if s.pendingPolls[key] > 0 {
+			s.pendingPolls[key]--
+
+			return &ssm.GetCommandInvocationOutput{
+				CommandId:  invocation.CommandId,
+				InstanceId: invocation.InstanceId,
+				Status:     aws.String(ssm.CommandInvocationStatusInProgress),
+			}, nil
+		}
+
+		return invocation, nil
+	}
+
+	return nil, awserr.New(ssm.ErrCodeInvocationDoesNotExist, fmt.Sprintf("Invocation %s does not exist", invocationKey(input.CommandId, input.InstanceId)), nil)
+}
@@ -0,0 +1,255 @@
+package aws
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Fred78290/kubernetes-aws-autoscaler/constantes"
+	"github.com/Fred78290/kubernetes-aws-autoscaler/context"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	glog "github.com/sirupsen/logrus"
+)
+
+const (
+	// defaultImageCacheTTL is the time in seconds a resolved AMI is used before resolving it again
+	defaultImageCacheTTL = 3600
+
+	// defaultArchitecture is used when the architecture of an instance type is unknown
+	defaultArchitecture = ec2.ArchitectureValuesX8664
+)
+
+// ImageSelector declare how to find the AMI for an architecture, by ID, by SSM public parameter
+// or by DescribeImages filters, the newest image matching the filters is selected
+type ImageSelector struct {
+	ImageID      string              `json:"id,omitempty"`
+	SSMParameter string              `json:"ssmParameter,omitempty"`
+	Owners       []string            `json:"owners,omitempty"`
+	Name         string              `json:"name,omitempty"`
+	Filters      map[string][]string `json:"filters,omitempty"`
+}
+
+// resolvedImage AMI resolved for a selector
+type resolvedImage struct {
+	imageID    string
+	resolvedAt time.Time
+}
+
+// resolvedImageKey share resolved AMI between configurations with same credentials, region and selector
+type resolvedImageKey struct {
+	options      sessionOptions
+	architecture string
+	selector     string
+}
+
+// resolvedImages cache resolved AMI by resolvedImageKey
+var resolvedImages sync.Map
+
+// architectures cache the first supported architecture by region and instance type
+var architectures sync.Map
+
+// GetImageCacheTTL return the duration a resolved AMI is used
+func (conf *Configuration) GetImageCacheTTL() time.Duration {
+	if conf.ImageCacheTTL <= 0 {
+		return defaultImageCacheTTL * time.Second
+	}
+
+	return conf.ImageCacheTTL * time.Second
+}
+
+// key return a stable description of the selector
+func (selector *ImageSelector) key() string {
+	if !isNullOrEmpty(selector.SSMParameter) {
+		return "ssm:" + selector.SSMParameter
+	}
+
+	owners := append([]string{}, selector.Owners...)
+	sort.Strings(owners)
+
+	filters := make([]string, 0, len(selector.Filters))
+
+	for name, values := range selector.Filters {
+		values = append([]string{}, values...)
+		sort.Strings(values)
+
+		filters = append(filters, fmt.Sprintf("%s=%s", name, strings.Join(values, ",")))
+	}
+
+	sort.Strings(filters)
+
+	return fmt.Sprintf("owners:%s;name:%s;filters:%s", strings.Join(owners, ","), selector.Name, strings.Join(filters, ";"))
+}
+
+// getArchitecture return the first architecture supported by the instance type.
+// Known architectures, from machine types discovery, avoid the api call
+func (instance *Ec2Instance) getArchitecture(ctx *context.Context, instanceType string, known []string) (string, error) {
+	if len(known) > 0 {
+		return known[0], nil
+	}
+
+	key := fmt.Sprintf("%s/%s", instance.config.Region, instanceType)
+
+	if architecture, found := architectures.Load(key); found {
+		return architecture.(string), nil
+	}
+
+	input := &ec2.DescribeInstanceTypesInput{
+		InstanceTypes: []*string{
+			aws.String(instanceType),
+		},
+	}
+
+	output, err := instance.client.DescribeInstanceTypesWithContext(ctx, input)
+
+	if err != nil {
+		return "", fmt.Errorf(constantes.ErrUnableToDescribeInstanceType, instanceType, err)
+	}
+
+	architecture := defaultArchitecture
+
+	if len(output.InstanceTypes) > 0 && output.InstanceTypes[0].ProcessorInfo != nil {
+		if supported := output.InstanceTypes[0].ProcessorInfo.SupportedArchitectures; len(supported) > 0 {
+			architecture = aws.StringValue(supported[0])
+		}
+	}
+
+	architectures.Store(key, architecture)
+
+	return architecture, nil
+}
+
+// resolveSSMParameter return the AMI stored in the SSM parameter
+func (instance *Ec2Instance) resolveSSMParameter(ctx *context.Context, name string) (string, error) {
+	client, err := createSSMClient(instance.config)
+
+	if err != nil {
+		return "", err
+	}
+
+	output, err := client.GetParameterWithContext(ctx, &ssm.GetParameterInput{
+		Name: aws.String(name),
+	})
+
+	if err != nil {
+		return "", err
+	}
+
+	if output.Parameter == nil || isNullOrEmpty(aws.StringValue(output.Parameter.Value)) {
+		return "", fmt.Errorf(constantes.ErrSSMParameterEmpty, name)
+	}
+
+	return aws.StringValue(output.Parameter.Value), nil
+}
+
+// resolveImageFilters return the newest available AMI matching the filters
+func (instance *Ec2Instance) resolveImageFilters(ctx *context.Context, selector *ImageSelector, architecture string) (string, error) {
+	input := &ec2.DescribeImagesInput{
+		Owners: aws.StringSlice(selector.Owners),
+		Filters: []*ec2.Filter{
+			{
+				Name:   aws.String("architecture"),
+				Values: []*string{aws.String(architecture)},
+			},
+			{
+				Name:   aws.String("state"),
+				Values: []*string{aws.String(ec2.ImageStateAvailable)},
+			},
+		},
+	}
+
+	if !isNullOrEmpty(selector.Name) {
+		input.Filters = append(input.Filters, &ec2.Filter{
+			Name:   aws.String("name"),
+			Values: []*string{aws.String(selector.Name)},
+		})
+	}
+
+	for name, values := range selector.Filters {
+		input.Filters = append(input.Filters, &ec2.Filter{
+			Name:   aws.String(name),
+			Values: aws.StringSlice(values),
+		})
+	}
+
+	output, err := instance.client.DescribeImagesWithContext(ctx, input)
+
+	if err != nil {
+		return "", err
+	}
+
+	if len(output.Images) == 0 {
+		return "", fmt.Errorf(constantes.ErrNoImageMatchFilters, architecture)
+	}
+
+	// CreationDate is ISO 8601, lexical order is chronological
+	sort.Slice(output.Images, func(i, j int) bool {
+		return aws.StringValue(output.Images[i].CreationDate) > aws.StringValue(output.Images[j].CreationDate)
+	})
+
+	return aws.StringValue(output.Images[0].ImageId), nil
+}
+
+// resolveImage return the AMI for the instance type, from the image selector of its architecture.
+// Without image selector, the AMI of the configuration is used
+func (instance *Ec2Instance) resolveImage(ctx *context.Context, instanceType string, architectures []string) (string, error) {
+	if len(instance.config.Images) == 0 {
+		return instance.config.ImageID, nil
+	}
+
+	architecture, err := instance.getArchitecture(ctx, instanceType, architectures)
+
+	if err != nil {
+		return "", err
+	}
+
+	selector, found := instance.config.Images[architecture]
+
+	if !found {
+		if isNullOrEmpty(instance.config.ImageID) {
+			return "", fmt.Errorf(constantes.ErrNoImageForArchitecture, architecture, instanceType)
+		}
+
+		return instance.config.ImageID, nil
+	}
+
+	if !isNullOrEmpty(selector.ImageID) {
+		return selector.ImageID, nil
+	}
+
+	key := resolvedImageKey{
+		options:      ec2SessionOptions(instance.config),
+		architecture: architecture,
+		selector:     selector.key(),
+	}
+
+	if cached, found := resolvedImages.Load(key); found {
+		if image := cached.(*resolvedImage); time.Since(image.resolvedAt) < instance.config.GetImageCacheTTL() {
+			return image.imageID, nil
+		}
+	}
+
+	var imageID string
+
+	if !isNullOrEmpty(selector.SSMParameter) {
+		imageID, err = instance.resolveSSMParameter(ctx, selector.SSMParameter)
+	} else {
+		imageID, err = instance.resolveImageFilters(ctx, selector, architecture)
+	}
+
+	if err != nil {
+		return "", fmt.Errorf(constantes.ErrUnableToResolveImage, architecture, err)
+	}
+
+	glog.Infof("Resolved AMI %s for architecture %s in region %s", imageID, architecture, instance.config.Region)
+
+	resolvedImages.Store(key, &resolvedImage{
+		imageID:    imageID,
+		resolvedAt: time.Now(),
+	})
+
+	return imageID, nil
+}
@@ -1,14 +1,16 @@
 package aws
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"runtime"
-	"strconv"
+	"sync"
 	"time"
 
 	glog "github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/util/uuid"
 	"k8s.io/apimachinery/pkg/util/wait"
 
 	"github.com/Fred78290/kubernetes-aws-autoscaler/constantes"
@@ -16,28 +18,93 @@ import (
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/ec2"
-	"github.com/aws/aws-sdk-go/service/route53"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+)
+
+// rootDeviceNames cache root device name by region and AMI
+var rootDeviceNames sync.Map
+
+// subnetZones cache availability zone by region and subnet
+var subnetZones sync.Map
+
+const (
+	roleSessionName  = "kubernetes-aws-autoscaler"
+	roleExpiryWindow = 5 * time.Minute
 )
 
 const (
-	route53_UpsertCmd = "UPSERT"
-	route53_DeleteCmd = "DELETE"
+	// InstanceLifecycleSpot instance launched on spot market
+	InstanceLifecycleSpot = "spot"
+
+	// InstanceLifecycleOnDemand instance launched on demand
+	InstanceLifecycleOnDemand = "on-demand"
 )
 
+// insufficientCapacityErrors aws error codes meaning the instance type is not available
+var insufficientCapacityErrors = []string{
+	"InsufficientInstanceCapacity",
+	"Unsupported",
+}
+
 // Ec2Instance Running instance
 type Ec2Instance struct {
-	client       *ec2.EC2
+	client       ec2iface.EC2API
 	config       *Configuration
 	InstanceName string
 	InstanceID   *string
 	Region       *string
 	Zone         *string
 	AddressIP    *string
+	AddressIPv6  *string
+	ImageID      *string
+	LaunchTime   *time.Time
+	Spot         bool
+	cache        *InstanceCache
+	network      []NetworkInterface
 }
 
-var phEC2Client *ec2.EC2
+// instanceIPv6Address return the first IPv6 address of the primary interface
+func instanceIPv6Address(instance *ec2.Instance) *string {
+	if instance.Ipv6Address != nil {
+		return instance.Ipv6Address
+	}
+
+	for _, inf := range instance.NetworkInterfaces {
+		if inf.Attachment != nil && aws.Int64Value(inf.Attachment.DeviceIndex) == 0 && len(inf.Ipv6Addresses) > 0 {
+			return inf.Ipv6Addresses[0].Ipv6Address
+		}
+	}
+
+	return nil
+}
+
+// newEc2InstanceFrom return the running instance from the described ec2 instance
+func newEc2InstanceFrom(client ec2iface.EC2API, config *Configuration, instanceName string, instance *ec2.Instance) *Ec2Instance {
+	var address *string
+
+	if instance.PublicIpAddress != nil {
+		address = instance.PublicIpAddress
+	} else {
+		address = instance.PrivateIpAddress
+	}
+
+	return &Ec2Instance{
+		client:       client,
+		config:       config,
+		InstanceName: instanceName,
+		InstanceID:   instance.InstanceId,
+		Region:       &config.Region,
+		Zone:         instance.Placement.AvailabilityZone,
+		AddressIP:    address,
+		AddressIPv6:  instanceIPv6Address(instance),
+		ImageID:      instance.ImageId,
+		LaunchTime:   instance.LaunchTime,
+		Spot:         aws.StringValue(instance.InstanceLifecycle) == ec2.InstanceLifecycleTypeSpot,
+	}
+}
 
 // GetEc2Instance return an existing instance from name
 func GetEc2Instance(config *Configuration, instanceName string) (*Ec2Instance, error) {
@@ -70,25 +137,11 @@ func GetEc2Instance(config *Configuration, instanceName string) (*Ec2Instance, e
 
 		for _, reservation := range result.Reservations {
 			for _, instance := range reservation.Instances {
-				// Assume EC2 shutting-down is terminated after
-				if *instance.State.Code != 48 && *instance.State.Code != 32 {
-					var address *string
-
-					if instance.PublicIpAddress != nil {
-						address = instance.PublicIpAddress
-					} else {
-						address = instance.PrivateIpAddress
-					}
-
-					return &Ec2Instance{
-						client:       client,
-						config:       config,
-						InstanceName: instanceName,
-						InstanceID:   instance.InstanceId,
-						Region:       &config.Region,
-						Zone:         instance.Placement.AvailabilityZone,
-						AddressIP:    address,
-					}, nil
+				if isInstanceAlive(instance) {
+					ec2Instance := newEc2InstanceFrom(client, config, instanceName, instance)
+					ec2Instance.cache = config.lookupInstanceCache(tagValue(instance, "NodeGroup"))
+
+					return ec2Instance, nil
 				}
 			}
 		}
@@ -97,6 +150,21 @@ func GetEc2Instance(config *Configuration, instanceName string) (*Ec2Instance, e
 	}
 }
 
+// IsCapacityError return true if the error means the instance type or the zone lacks capacity
+func IsCapacityError(err error) bool {
+	var aerr awserr.Error
+
+	if errors.As(err, &aerr) {
+		for _, code := range insufficientCapacityErrors {
+			if aerr.Code() == code {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
 func userHomeDir() string {
 	if runtime.GOOS == "windows" { // Windows
 		return os.Getenv("USERPROFILE")
@@ -141,47 +209,64 @@ func isAwsProfileValid(filename, profile string) bool {
 	return credentialsFileExists(filename)
 }
 
-func newSessionWithOptions(accessKey, secretKey, token, filename, profile, region string) (*session.Session, error) {
-	var cred *credentials.Credentials
-
-	if isAwsProfileValid(filename, profile) {
-		cred = credentials.NewSharedCredentials(filename, profile)
-	} else if !isNullOrEmpty(accessKey) && !isNullOrEmpty(secretKey) {
-		cred = credentials.NewStaticCredentials(accessKey, secretKey, token)
-	} else {
-		cred = nil
+func newBaseCredentials(options sessionOptions) *credentials.Credentials {
+	if isAwsProfileValid(options.filename, options.profile) {
+		return credentials.NewSharedCredentials(options.filename, options.profile)
+	} else if !isNullOrEmpty(options.accessKey) && !isNullOrEmpty(options.secretKey) {
+		return credentials.NewStaticCredentials(options.accessKey, options.secretKey, options.token)
 	}
 
+	// Use default credential chain
+	return nil
+}
+
+// newSessionWithOptions create a session with shared, static or default credentials.
+// When a web identity token file is declared, the first role is assumed with the token,
+// then each declared role is assumed with sts on top of the previous credentials.
+// Assumed role credentials are refreshed before they expire.
+func newSessionWithOptions(options sessionOptions) (*session.Session, error) {
+	var err error
+	var sess *session.Session
+
 	config := aws.Config{
-		Credentials: cred,
-		Region:      aws.String(region),
+		Credentials: newBaseCredentials(options),
+		Region:      aws.String(options.region),
 	}
 
-	return session.NewSession(&config)
-}
-
-func newSession(conf *Configuration) (*session.Session, error) {
-	return newSessionWithOptions(conf.AccessKey, conf.SecretKey, conf.Token, conf.Filename, conf.Profile, conf.Region)
-}
+	if sess, err = session.NewSession(&config); err != nil {
+		return nil, err
+	}
 
-func createClient(conf *Configuration) (*ec2.EC2, error) {
-	if phEC2Client == nil {
-		var err error
-		var sess *session.Session
+	useWebIdentity := !isNullOrEmpty(options.webIdentityTokenFile)
 
-		if sess, err = newSession(conf); err != nil {
-			return nil, err
+	for _, role := range options.roles {
+		if isNullOrEmpty(role.roleARN) {
+			continue
 		}
 
-		// Create EC2 service client
-		if glog.GetLevel() >= glog.DebugLevel {
-			phEC2Client = ec2.New(sess, aws.NewConfig().WithLogger(conf).WithLogLevel(aws.LogDebugWithHTTPBody).WithLogLevel(aws.LogDebugWithSigning))
+		if useWebIdentity {
+			useWebIdentity = false
+
+			config.Credentials = stscreds.NewWebIdentityCredentials(sess, role.roleARN, roleSessionName, options.webIdentityTokenFile)
 		} else {
-			phEC2Client = ec2.New(sess)
+			externalID := role.externalID
+
+			config.Credentials = stscreds.NewCredentials(sess, role.roleARN, func(provider *stscreds.AssumeRoleProvider) {
+				provider.RoleSessionName = roleSessionName
+				provider.ExpiryWindow = roleExpiryWindow
+
+				if !isNullOrEmpty(externalID) {
+					provider.ExternalID = aws.String(externalID)
+				}
+			})
+		}
+
+		if sess, err = session.NewSession(&config); err != nil {
+			return nil, err
 		}
 	}
 
-	return phEC2Client, nil
+	return sess, nil
 }
 
 func (instance *Ec2Instance) getInstanceID() string {
@@ -192,6 +277,34 @@ func (instance *Ec2Instance) getInstanceID() string {
 	}
 }
 
+// Lifecycle return spot or on-demand
+func (instance *Ec2Instance) Lifecycle() string {
+	if instance.Spot {
+		return InstanceLifecycleSpot
+	}
+
+	return InstanceLifecycleOnDemand
+}
+
+// AMI return the AMI used to launch the instance
+func (instance *Ec2Instance) AMI() string {
+	return aws.StringValue(instance.ImageID)
+}
+
+// SetInstanceCache use the cache of the node group to get the status of the instance
+func (instance *Ec2Instance) SetInstanceCache(cache *InstanceCache) {
+	instance.cache = cache
+}
+
+// describeEc2Instance return the instance from the cache if fresh, else describe it
+func (instance *Ec2Instance) describeEc2Instance() (*ec2.Instance, error) {
+	if ec2Instance := instance.cache.findByID(instance.InstanceID); ec2Instance != nil {
+		return ec2Instance, nil
+	}
+
+	return instance.getEc2Instance()
+}
+
 func (instance *Ec2Instance) getEc2Instance() (*ec2.Instance, error) {
 	var err error
 	var result *ec2.DescribeInstancesOutput
@@ -255,6 +368,8 @@ func (instance *Ec2Instance) WaitForIP(callback CallbackWaitSSHReady) (*string,
 				instance.AddressIP = ec2Instance.PrivateIpAddress
 			}
 
+			instance.AddressIPv6 = instanceIPv6Address(ec2Instance)
+
 			glog.Debugf("WaitForIP: instance %s id (%s), using IP:%s", instance.InstanceName, instance.getInstanceID(), *instance.AddressIP)
 
 			if err = callback.WaitSSHReady(instance.InstanceName, *instance.AddressIP); err != nil {
@@ -307,13 +422,111 @@ func (instance *Ec2Instance) WaitForPowered() error {
 	})
 }
 
-func (instance *Ec2Instance) buildNetworkInterfaces(nodeIndex int, desiredENI *UserDefinedNetworkInterface) ([]*ec2.InstanceNetworkInterfaceSpecification, error) {
+// getSubnetZones return the availability zone of each subnet
+func (instance *Ec2Instance) getSubnetZones(ctx *context.Context, subnetIDs []string) (map[string]string, error) {
+	zones := make(map[string]string, len(subnetIDs))
+	unknown := make([]*string, 0, len(subnetIDs))
+
+	for _, subnetID := range subnetIDs {
+		if zone, found := subnetZones.Load(fmt.Sprintf("%s/%s", instance.config.Region, subnetID)); found {
+			zones[subnetID] = zone.(string)
+		} else {
+			unknown = append(unknown, aws.String(subnetID))
+		}
+	}
+
+	if len(unknown) > 0 {
+		input := &ec2.DescribeSubnetsInput{
+			SubnetIds: unknown,
+		}
+
+		if output, err := instance.client.DescribeSubnetsWithContext(ctx, input); err != nil {
+			return nil, fmt.Errorf(constantes.ErrUnableToDescribeSubnets, err)
+		} else {
+			for _, subnet := range output.Subnets {
+				subnetID := aws.StringValue(subnet.SubnetId)
+				zone := aws.StringValue(subnet.AvailabilityZone)
+
+				subnetZones.Store(fmt.Sprintf("%s/%s", instance.config.Region, subnetID), zone)
+				zones[subnetID] = zone
+			}
+		}
+	}
+
+	return zones, nil
+}
+
+// markLaunchZoneFailed put in cool-down the zone of the primary interface
+func (instance *Ec2Instance) markLaunchZoneFailed(ctx *context.Context, zone string, interfaces []*ec2.InstanceNetworkInterfaceSpecification) {
+	if len(zone) == 0 && len(interfaces) > 0 && interfaces[0].SubnetId != nil {
+		subnetID := aws.StringValue(interfaces[0].SubnetId)
+
+		if zones, err := instance.getSubnetZones(ctx, []string{subnetID}); err == nil {
+			zone = zones[subnetID]
+		}
+	}
+
+	if len(zone) > 0 {
+		instance.config.MarkZoneFailed(zone)
+	}
+}
+
+// nextSubnetID return the subnet for the node, restricted to the desired zone if any.
+// Discovered subnets are checked again for free IP addresses
+func (instance *Ec2Instance) nextSubnetID(ctx *context.Context, eni *NetworkInterface, create *CreateInput) (*string, error) {
+	candidates := make([]string, 0, len(eni.SubnetsID))
+
+	if len(create.Zone) == 0 {
+		if len(eni.SubnetSelector) == 0 {
+			return aws.String(eni.GetNextSubnetsID(create.NodeIndex)), nil
+		}
+
+		// Start with the subnet of the node index
+		for index := range eni.SubnetsID {
+			candidates = append(candidates, eni.GetNextSubnetsID(create.NodeIndex+index))
+		}
+	} else if zones, err := instance.getSubnetZones(ctx, eni.SubnetsID); err != nil {
+		return nil, err
+	} else {
+		for _, subnetID := range eni.SubnetsID {
+			if zones[subnetID] == create.Zone {
+				candidates = append(candidates, subnetID)
+			}
+		}
+	}
+
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf(constantes.ErrNoSubnetInZone, create.Zone)
+	}
+
+	if len(eni.SubnetSelector) == 0 {
+		return aws.String(candidates[0]), nil
+	}
+
+	return instance.subnetWithFreeIPAddresses(ctx, eni, candidates)
+}
+
+// setIPv6 request IPv6 addresses or prefixes for the interface
+func (eni *NetworkInterface) setIPv6(inf *ec2.InstanceNetworkInterfaceSpecification) {
+	if eni.IPv6AddressCount > 0 {
+		inf.Ipv6AddressCount = aws.Int64(int64(eni.IPv6AddressCount))
+	}
+
+	if eni.IPv6PrefixCount > 0 {
+		inf.Ipv6PrefixCount = aws.Int64(int64(eni.IPv6PrefixCount))
+	}
+}
+
+func (instance *Ec2Instance) buildNetworkInterfaces(ctx *context.Context, create *CreateInput) ([]*ec2.InstanceNetworkInterfaceSpecification, error) {
 	var err error
 
+	desiredENI := create.DesiredENI
+	network := instance.networkInterfaces()
+
 	if desiredENI != nil {
 		var privateIPAddress *string
 		var subnetID *string
-		var securityGroup *string
+		var securityGroups []*string
 		var deleteOnTermination bool
 		var networkInterfaceId *string
 
@@ -349,46 +562,68 @@ func (instance *Ec2Instance) buildNetworkInterfaces(nodeIndex int, desiredENI *U
 
 			if len(desiredENI.SubnetID) > 0 {
 				subnetID = aws.String(desiredENI.SubnetID)
-			} else {
-				subnetID = aws.String(instance.config.Network.ENI[0].GetNextSubnetsID(nodeIndex))
+			} else if subnetID, err = instance.nextSubnetID(ctx, &network[0], create); err != nil {
+				return nil, err
 			}
 
 			if len(desiredENI.SecurityGroupID) > 0 {
-				securityGroup = aws.String(desiredENI.SecurityGroupID)
+				securityGroups = []*string{
+					aws.String(desiredENI.SecurityGroupID),
+				}
 			} else {
-				securityGroup = aws.String(instance.config.Network.ENI[0].SecurityGroupID)
+				securityGroups = network[0].getSecurityGroups()
+			}
+		}
+
+		inf := &ec2.InstanceNetworkInterfaceSpecification{
+			AssociatePublicIpAddress: aws.Bool(desiredENI.PublicIP),
+			DeleteOnTermination:      aws.Bool(deleteOnTermination),
+			Description:              aws.String(instance.InstanceName),
+			DeviceIndex:              aws.Int64(0),
+			SubnetId:                 subnetID,
+			NetworkInterfaceId:       networkInterfaceId,
+			PrivateIpAddress:         privateIPAddress,
+			Groups:                   securityGroups,
+		}
+
+		// An existing ENI keep its own IPv6 addresses
+		if networkInterfaceId == nil {
+			if len(desiredENI.IPv6Address) > 0 {
+				inf.Ipv6Addresses = []*ec2.InstanceIpv6Address{
+					{
+						Ipv6Address: aws.String(desiredENI.IPv6Address),
+					},
+				}
+			} else if len(network) > 0 {
+				network[0].setIPv6(inf)
 			}
 		}
 
 		return []*ec2.InstanceNetworkInterfaceSpecification{
-			{
-				AssociatePublicIpAddress: aws.Bool(desiredENI.PublicIP),
-				DeleteOnTermination:      aws.Bool(deleteOnTermination),
-				Description:              aws.String(instance.InstanceName),
-				DeviceIndex:              aws.Int64(0),
-				SubnetId:                 subnetID,
-				NetworkInterfaceId:       networkInterfaceId,
-				PrivateIpAddress:         privateIPAddress,
-				Groups: []*string{
-					securityGroup,
-				},
-			},
+			inf,
 		}, nil
 
-	} else if len(instance.config.Network.ENI) > 0 {
-		interfaces := make([]*ec2.InstanceNetworkInterfaceSpecification, len(instance.config.Network.ENI))
+	} else if len(network) > 0 {
+		interfaces := make([]*ec2.InstanceNetworkInterfaceSpecification, len(network))
+
+		for index, eni := range network {
+			var subnetID *string
+
+			if subnetID, err = instance.nextSubnetID(ctx, &eni, create); err != nil {
+				return nil, err
+			}
 
-		for index, eni := range instance.config.Network.ENI {
 			inf := &ec2.InstanceNetworkInterfaceSpecification{
 				AssociatePublicIpAddress: aws.Bool(eni.PublicIP),
 				DeleteOnTermination:      aws.Bool(true),
 				Description:              aws.String(instance.InstanceName),
 				DeviceIndex:              aws.Int64(int64(index)),
-				SubnetId:                 aws.String(eni.GetNextSubnetsID(nodeIndex)),
-				Groups: []*string{
-					aws.String(eni.SecurityGroupID),
-				},
+				SubnetId:                 subnetID,
+				Groups:                   eni.getSecurityGroups(),
 			}
+
+			eni.setIPv6(inf)
+
 			interfaces[index] = inf
 		}
 
@@ -398,8 +633,104 @@ func (instance *Ec2Instance) buildNetworkInterfaces(nodeIndex int, desiredENI *U
 	}
 }
 
-func (instance *Ec2Instance) buildBlockDeviceMappings(diskType string, diskSize int) ([]*ec2.BlockDeviceMapping, error) {
-	if diskSize > 0 || len(diskType) > 0 {
+// getRootDeviceName return the root device name declared by the AMI
+func (instance *Ec2Instance) getRootDeviceName(ctx *context.Context, imageID string) (string, error) {
+	key := fmt.Sprintf("%s/%s", instance.config.Region, imageID)
+
+	if name, found := rootDeviceNames.Load(key); found {
+		return name.(string), nil
+	}
+
+	input := &ec2.DescribeImagesInput{
+		ImageIds: []*string{
+			aws.String(imageID),
+		},
+	}
+
+	if output, err := instance.client.DescribeImagesWithContext(ctx, input); err != nil {
+		return "", fmt.Errorf(constantes.ErrUnableToDescribeImage, imageID, err)
+	} else if len(output.Images) == 0 || output.Images[0].RootDeviceName == nil {
+		return "", fmt.Errorf(constantes.ErrUnableToDescribeImage, imageID, "root device not found")
+	} else {
+		name := *output.Images[0].RootDeviceName
+
+		rootDeviceNames.Store(key, name)
+
+		return name, nil
+	}
+}
+
+func newEbsBlockDevice(volumeType string, volumeSize int, device *BlockDevice) *ec2.EbsBlockDevice {
+	ebs := &ec2.EbsBlockDevice{
+		DeleteOnTermination: aws.Bool(true),
+		VolumeType:          aws.String(volumeType),
+		VolumeSize:          aws.Int64(int64(volumeSize)),
+	}
+
+	if device != nil {
+		if device.DeleteOnTermination != nil {
+			ebs.DeleteOnTermination = device.DeleteOnTermination
+		}
+
+		// KMS key implies encryption
+		if device.Encrypted || !isNullOrEmpty(device.KmsKeyID) {
+			ebs.Encrypted = aws.Bool(true)
+		}
+
+		if !isNullOrEmpty(device.KmsKeyID) {
+			ebs.KmsKeyId = aws.String(device.KmsKeyID)
+		}
+
+		if device.Iops > 0 {
+			ebs.Iops = aws.Int64(int64(device.Iops))
+		}
+
+		if device.Throughput > 0 {
+			ebs.Throughput = aws.Int64(int64(device.Throughput))
+		}
+	}
+
+	return ebs
+}
+
+func (instance *Ec2Instance) buildBlockDeviceMappings(rootDeviceName, diskType string, diskSize int, blockDevices []BlockDevice) ([]*ec2.BlockDeviceMapping, error) {
+	var root *BlockDevice
+
+	mappings := make([]*ec2.BlockDeviceMapping, 0, len(blockDevices)+1)
+
+	for index := range blockDevices {
+		device := &blockDevices[index]
+
+		if isNullOrEmpty(device.DeviceName) || device.DeviceName == rootDeviceName {
+			root = device
+		} else if device.VolumeSize <= 0 {
+			return nil, fmt.Errorf(constantes.ErrBlockDeviceSizeUndefined, device.DeviceName)
+		} else {
+			volumeType := device.VolumeType
+
+			if len(volumeType) == 0 {
+				volumeType = "gp2"
+			}
+
+			mappings = append(mappings, &ec2.BlockDeviceMapping{
+				DeviceName: aws.String(device.DeviceName),
+				Ebs:        newEbsBlockDevice(volumeType, device.VolumeSize, device),
+			})
+		}
+	}
+
+	// Explicit root device settings take precedence over the machine defaults
+	if root != nil {
+		if root.VolumeSize > 0 {
+			diskSize = root.VolumeSize
+		}
+
+		if len(root.VolumeType) > 0 {
+			diskType = root.VolumeType
+		}
+	}
+
+	if diskSize > 0 || len(diskType) > 0 || root != nil {
 		if diskSize == 0 {
 			diskSize = 20
 		}
@@ -409,74 +740,236 @@ func (instance *Ec2Instance) buildBlockDeviceMappings(diskType string, diskSize
 		}
 
 		ebs := &ec2.BlockDeviceMapping{
-			DeviceName: aws.String("/dev/sda1"),
-			Ebs: &ec2.EbsBlockDevice{
-				DeleteOnTermination: aws.Bool(true),
-				VolumeType:          aws.String(diskType),
-				VolumeSize:          aws.Int64(int64(diskSize)),
-			},
+			DeviceName: aws.String(rootDeviceName),
+			Ebs:        newEbsBlockDevice(diskType, diskSize, root),
 		}
 
-		return []*ec2.BlockDeviceMapping{
-			ebs,
-		}, nil
+		mappings = append([]*ec2.BlockDeviceMapping{ebs}, mappings...)
 	}
 
-	return nil, nil
+	if len(mappings) == 0 {
+		return nil, nil
+	}
+
+	return mappings, nil
 }
 
-func (instance *Ec2Instance) buildTagSpecifications(nodeIndex int, nodeGroup string) ([]*ec2.TagSpecification, error) {
-	instanceTags := make([]*ec2.Tag, 0, len(instance.config.Tags)+3)
+func (instance *Ec2Instance) buildTagSpecifications(create *CreateInput) ([]*ec2.TagSpecification, error) {
+	instanceTags, err := instance.buildTags(create)
 
-	instanceTags = append(instanceTags, &ec2.Tag{
-		Key:   aws.String("Name"),
-		Value: aws.String(instance.InstanceName),
-	})
-
-	instanceTags = append(instanceTags, &ec2.Tag{
-		Key:   aws.String("NodeGroup"),
-		Value: aws.String(nodeGroup),
-	})
+	if err != nil {
+		return nil, err
+	}
 
-	instanceTags = append(instanceTags, &ec2.Tag{
-		Key:   aws.String("NodeIndex"),
-		Value: aws.String(strconv.Itoa(nodeIndex)),
-	})
+	tagSpecifications := []*ec2.TagSpecification{
+		{
+			ResourceType: aws.String(ec2.ResourceTypeInstance),
+			Tags:         instanceTags,
+		},
+	}
 
-	instanceTags = append(instanceTags, &ec2.Tag{
-		Key:   aws.String(fmt.Sprintf("kubernetes.io/cluster/%s", nodeGroup)),
-		Value: aws.String("owned"),
-	})
+	// Same tags on volumes and network interfaces created with the instance
+	if instance.config.PropagateTags {
+		tagSpecifications = append(tagSpecifications, &ec2.TagSpecification{
+			ResourceType: aws.String(ec2.ResourceTypeVolume),
+			Tags:         copyTags(instanceTags),
+		})
 
-	// Add tags
-	if instance.config.Tags != nil && len(instance.config.Tags) > 0 {
-		for _, tag := range instance.config.Tags {
-			instanceTags = append(instanceTags, &ec2.Tag{
-				Key:   aws.String(tag.Key),
-				Value: aws.String(tag.Value),
+		// An existing network interface is attached, not created
+		if create.DesiredENI == nil || isNullOrEmpty(create.DesiredENI.NetworkInterfaceID) {
+			tagSpecifications = append(tagSpecifications, &ec2.TagSpecification{
+				ResourceType: aws.String(ec2.ResourceTypeNetworkInterface),
+				Tags:         copyTags(instanceTags),
 			})
 		}
 	}
 
-	return []*ec2.TagSpecification{
-		{
-			ResourceType: aws.String(ec2.ResourceTypeInstance),
-			Tags:         instanceTags,
+	return tagSpecifications, nil
+}
+
+func (instance *Ec2Instance) buildInstanceMarketOptions(spot *SpotOptions) *ec2.InstanceMarketOptionsRequest {
+	if !spot.IsSpot() {
+		return nil
+	}
+
+	spotOptions := &ec2.SpotMarketOptions{
+		SpotInstanceType:             aws.String(ec2.SpotInstanceTypeOneTime),
+		InstanceInterruptionBehavior: aws.String(ec2.InstanceInterruptionBehaviorTerminate),
+	}
+
+	// Empty max price means capped to on-demand price
+	if !isNullOrEmpty(spot.MaxPrice) {
+		spotOptions.MaxPrice = aws.String(spot.MaxPrice)
+	}
+
+	return &ec2.InstanceMarketOptionsRequest{
+		MarketType:  aws.String(ec2.MarketTypeSpot),
+		SpotOptions: spotOptions,
+	}
+}
+
+func (instance *Ec2Instance) buildLaunchTemplateSpecification() *ec2.LaunchTemplateSpecification {
+	template := instance.config.LaunchTemplate
+	spec := &ec2.LaunchTemplateSpecification{
+		Version: aws.String(template.GetVersion()),
+	}
+
+	if !isNullOrEmpty(template.ID) {
+		spec.LaunchTemplateId = aws.String(template.ID)
+	} else {
+		spec.LaunchTemplateName = aws.String(template.Name)
+	}
+
+	return spec
+}
+
+func (instance *Ec2Instance) getLaunchTemplateData(ctx *context.Context) (*ec2.ResponseLaunchTemplateData, error) {
+	var err error
+	var output *ec2.DescribeLaunchTemplateVersionsOutput
+
+	spec := instance.buildLaunchTemplateSpecification()
+	input := &ec2.DescribeLaunchTemplateVersionsInput{
+		LaunchTemplateId:   spec.LaunchTemplateId,
+		LaunchTemplateName: spec.LaunchTemplateName,
+		Versions: []*string{
+			spec.Version,
 		},
-	}, nil
+	}
+
+	templateName := aws.StringValue(spec.LaunchTemplateId)
+
+	if len(templateName) == 0 {
+		templateName = aws.StringValue(spec.LaunchTemplateName)
+	}
+
+	if output, err = instance.client.DescribeLaunchTemplateVersionsWithContext(ctx, input); err != nil {
+		return nil, fmt.Errorf(constantes.ErrUnableToDescribeLaunchTemplate, templateName, *spec.Version, err)
+	}
+
+	if len(output.LaunchTemplateVersions) == 0 || output.LaunchTemplateVersions[0].LaunchTemplateData == nil {
+		return nil, fmt.Errorf(constantes.ErrLaunchTemplateNotFound, templateName, *spec.Version)
+	}
+
+	return output.LaunchTemplateVersions[0].LaunchTemplateData, nil
+}
+
+// buildLaunchTemplateNetworkInterfaces override subnet & security group of network interfaces declared in the launch template.
+// If no ENI is declared in the configuration, the network interfaces from the launch template are used as is
+func (instance *Ec2Instance) buildLaunchTemplateNetworkInterfaces(ctx *context.Context, create *CreateInput, templateData *ec2.ResponseLaunchTemplateData) ([]*ec2.InstanceNetworkInterfaceSpecification, error) {
+	if create.DesiredENI != nil {
+		return instance.buildNetworkInterfaces(ctx, create)
+	}
+
+	network := instance.networkInterfaces()
+
+	if len(network) == 0 {
+		return nil, nil
+	}
+
+	templateInterfaces := make(map[int64]*ec2.LaunchTemplateInstanceNetworkInterfaceSpecification)
+
+	for _, inf := range templateData.NetworkInterfaces {
+		templateInterfaces[aws.Int64Value(inf.DeviceIndex)] = inf
+	}
+
+	interfaces := make([]*ec2.InstanceNetworkInterfaceSpecification, len(network))
+
+	for index, eni := range network {
+		subnetID, err := instance.nextSubnetID(ctx, &eni, create)
+
+		if err != nil {
+			return nil, err
+		}
+
+		inf := &ec2.InstanceNetworkInterfaceSpecification{
+			AssociatePublicIpAddress: aws.Bool(eni.PublicIP),
+			DeleteOnTermination:      aws.Bool(true),
+			Description:              aws.String(instance.InstanceName),
+			DeviceIndex:              aws.Int64(int64(index)),
+			SubnetId:                 subnetID,
+		}
+
+		if templateInf, found := templateInterfaces[int64(index)]; found {
+			inf.Groups = templateInf.Groups
+			inf.InterfaceType = templateInf.InterfaceType
+			inf.Ipv6AddressCount = templateInf.Ipv6AddressCount
+
+			if templateInf.DeleteOnTermination != nil {
+				inf.DeleteOnTermination = templateInf.DeleteOnTermination
+			}
+		}
+
+		if len(eni.SecurityGroupID) > 0 || len(eni.securityGroupsID) > 0 {
+			inf.Groups = eni.getSecurityGroups()
+		}
+
+		if eni.IsDualStack() {
+			inf.Ipv6AddressCount = nil
+			eni.setIPv6(inf)
+		}
 
+		interfaces[index] = inf
+	}
+
+	return interfaces, nil
+}
+
+// mergeLaunchTemplateTags keep the tags declared in the launch template, tags from the node override them
+func (instance *Ec2Instance) mergeLaunchTemplateTags(tagSpecifications []*ec2.TagSpecification, templateData *ec2.ResponseLaunchTemplateData) []*ec2.TagSpecification {
+	result := make([]*ec2.TagSpecification, 0, len(tagSpecifications)+len(templateData.TagSpecifications))
+	resourceTypes := make(map[string]*ec2.TagSpecification)
+
+	for _, tagSpec := range tagSpecifications {
+		resourceTypes[aws.StringValue(tagSpec.ResourceType)] = tagSpec
+		result = append(result, tagSpec)
+	}
+
+	for _, templateSpec := range templateData.TagSpecifications {
+		tags := make([]*ec2.Tag, 0, len(templateSpec.Tags))
+
+		for _, tag := range templateSpec.Tags {
+			tags = append(tags, &ec2.Tag{
+				Key:   tag.Key,
+				Value: tag.Value,
+			})
+		}
+
+		if tagSpec, found := resourceTypes[aws.StringValue(templateSpec.ResourceType)]; found {
+			keys := make(map[string]bool)
+
+			for _, tag := range tagSpec.Tags {
+				keys[aws.StringValue(tag.Key)] = true
+			}
+
+			for _, tag := range tags {
+				if !keys[aws.StringValue(tag.Key)] {
+					tagSpec.Tags = append(tagSpec.Tags, tag)
+				}
+			}
+		} else {
+			result = append(result, &ec2.TagSpecification{
+				ResourceType: templateSpec.ResourceType,
+				Tags:         tags,
+			})
+		}
+	}
+
+	return result
 }
 
 // Create will create a named VM not powered
 // memory and disk are in megabytes
-func (instance *Ec2Instance) Create(nodeIndex int, nodeGroup, instanceType string, userData *string, diskType string, diskSize int, desiredENI *UserDefinedNetworkInterface) error {
+func (instance *Ec2Instance) Create(create *CreateInput) error {
 	var err error
 	var result *ec2.Reservation
+	var rootDeviceName string
+
+	glog.Debugf("Create: instance name %s in node group %s", instance.InstanceName, create.NodeGroup)
 
-	glog.Debugf("Create: instance name %s in node group %s", instance.InstanceName, nodeGroup)
+	instance.cache = instance.config.GetInstanceCache(create.NodeGroup)
 
 	// Check if instance is not already created
-	if _, err = GetEc2Instance(instance.config, instance.InstanceName); err == nil {
+	if instance.cache.Exists(instance.InstanceName) {
 		glog.Debugf("Create: instance name %s already exists", instance.InstanceName)
 
 		return fmt.Errorf(constantes.ErrCantCreateVMAlreadyExist, instance.InstanceName)
@@ -485,41 +978,118 @@ func (instance *Ec2Instance) Create(nodeIndex int, nodeGroup, instanceType strin
 	ctx := instance.NewContext()
 	defer ctx.Cancel()
 
+	// Resolve subnets and security groups declared by tags
+	if instance.network, err = instance.discoverNetworkInterfaces(ctx); err != nil {
+		return err
+	}
+
+	// Balance nodes across availability zones
+	if zone, err := instance.selectZone(ctx, create); err != nil {
+		return err
+	} else if zone != create.Zone {
+		balanced := *create
+		balanced.Zone = zone
+		create = &balanced
+	}
+
 	input := &ec2.RunInstancesInput{
-		InstanceType:                      aws.String(instanceType),
-		ImageId:                           aws.String(instance.config.ImageID),
-		KeyName:                           aws.String(instance.config.KeyName),
+		InstanceType:                      aws.String(create.InstanceType),
 		InstanceInitiatedShutdownBehavior: aws.String(ec2.ShutdownBehaviorStop),
 		MaxCount:                          aws.Int64(1),
 		MinCount:                          aws.Int64(1),
-		UserData:                          userData,
-		IamInstanceProfile: &ec2.IamInstanceProfileSpecification{
-			Arn: &instance.config.IamRole,
-		},
+		UserData:                          create.UserData,
+		ClientToken:                       aws.String(string(uuid.NewUUID())),
 	}
 
 	// Add tags
-	if input.TagSpecifications, err = instance.buildTagSpecifications(nodeIndex, nodeGroup); err != nil {
+	if input.TagSpecifications, err = instance.buildTagSpecifications(create); err != nil {
 		return err
 	}
 
-	// Add ENI
-	if input.NetworkInterfaces, err = instance.buildNetworkInterfaces(nodeIndex, desiredENI); err != nil {
-		return err
+	if instance.config.UseLaunchTemplate() {
+		var templateData *ec2.ResponseLaunchTemplateData
+
+		// AMI, block devices, IAM profile come from the launch template, only per node settings are overridden
+		if templateData, err = instance.getLaunchTemplateData(ctx); err != nil {
+			return err
+		}
+
+		input.LaunchTemplate = instance.buildLaunchTemplateSpecification()
+		input.TagSpecifications = instance.mergeLaunchTemplateTags(input.TagSpecifications, templateData)
+
+		if templateData.InstanceInitiatedShutdownBehavior != nil {
+			input.InstanceInitiatedShutdownBehavior = nil
+		}
+
+		// Add ENI
+		if input.NetworkInterfaces, err = instance.buildLaunchTemplateNetworkInterfaces(ctx, create, templateData); err != nil {
+			return err
+		}
+
+		// Override launch template block devices only if volumes are declared
+		if len(create.BlockDevices) > 0 {
+			if rootDeviceName, err = instance.getRootDeviceName(ctx, aws.StringValue(templateData.ImageId)); err != nil {
+				return err
+			}
+
+			if input.BlockDeviceMappings, err = instance.buildBlockDeviceMappings(rootDeviceName, create.DiskType, create.DiskSize, create.BlockDevices); err != nil {
+				return err
+			}
+		}
+	} else {
+		var imageID string
+
+		// AMI resolved for the architecture of the instance type
+		if imageID, err = instance.resolveImage(ctx, create.InstanceType, create.Architectures); err != nil {
+			return err
+		}
+
+		input.ImageId = aws.String(imageID)
+		input.KeyName = aws.String(instance.config.KeyName)
+		input.IamInstanceProfile = &ec2.IamInstanceProfileSpecification{
+			Arn: &instance.config.IamRole,
+		}
+
+		// Add ENI
+		if input.NetworkInterfaces, err = instance.buildNetworkInterfaces(ctx, create); err != nil {
+			return err
+		}
+
+		// Add Block device
+		if rootDeviceName, err = instance.getRootDeviceName(ctx, imageID); err != nil {
+			return err
+		}
+
+		if input.BlockDeviceMappings, err = instance.buildBlockDeviceMappings(rootDeviceName, create.DiskType, create.DiskSize, create.BlockDevices); err != nil {
+			return err
+		}
 	}
 
-	// Add Block device
-	if input.BlockDeviceMappings, err = instance.buildBlockDeviceMappings(diskType, diskSize); err != nil {
-		return err
+	input.Placement = instance.buildPlacement()
+	input.MetadataOptions = instance.buildMetadataOptions()
+
+	// One time spot instance can't be stopped, capacity reservations don't apply to spot instances
+	if input.InstanceMarketOptions = instance.buildInstanceMarketOptions(create.Spot); input.InstanceMarketOptions != nil {
+		input.InstanceInitiatedShutdownBehavior = aws.String(ec2.ShutdownBehaviorTerminate)
+	} else {
+		input.CapacityReservationSpecification = instance.buildCapacityReservationSpecification()
 	}
 
-	if result, err = instance.client.RunInstancesWithContext(ctx, input); err != nil {
+	if result, err = instance.runInstances(ctx, input); err != nil {
+		if IsCapacityError(err) {
+			instance.markLaunchZoneFailed(ctx, create.Zone, input.NetworkInterfaces)
+		}
+
 		return err
 	}
 
 	instance.Region = aws.String(instance.config.Region)
 	instance.Zone = result.Instances[0].Placement.AvailabilityZone
 	instance.InstanceID = result.Instances[0].InstanceId
+	instance.AddressIPv6 = instanceIPv6Address(result.Instances[0])
+	instance.ImageID = result.Instances[0].ImageId
+	instance.Spot = aws.StringValue(result.Instances[0].InstanceLifecycle) == ec2.InstanceLifecycleTypeSpot
+	instance.cache.Add(instance.InstanceName, result.Instances[0])
 
 	return nil
 }
@@ -530,6 +1100,8 @@ func (instance *Ec2Instance) delete(wait bool) error {
 
 	glog.Debugf("Delete: instance %s id (%s)", instance.InstanceName, instance.getInstanceID())
 
+	instance.cache.Invalidate(instance.InstanceID)
+
 	input := &ec2.TerminateInstancesInput{
 		InstanceIds: []*string{
 			instance.InstanceID,
@@ -540,6 +1112,8 @@ func (instance *Ec2Instance) delete(wait bool) error {
 		return err
 	}
 
+	instance.cache.Remove(instance.InstanceName, instance.InstanceID)
+
 	if wait {
 		return instance.client.WaitUntilInstanceTerminated(&ec2.DescribeInstancesInput{
 			InstanceIds: []*string{
@@ -577,6 +1151,8 @@ func (instance *Ec2Instance) PowerOn() error {
 
 	glog.Debugf("PowerOn: instance %s id (%s)", instance.InstanceName, instance.getInstanceID())
 
+	instance.cache.Invalidate(instance.InstanceID)
+
 	if _, err = instance.client.StartInstancesWithContext(ctx, input); err == nil {
 		// Wait start is effective
 		input := &ec2.DescribeInstancesInput{
@@ -617,6 +1193,8 @@ func (instance *Ec2Instance) powerOff(force bool) error {
 
 	glog.Debugf("powerOff: instance %s id (%s)", instance.InstanceName, instance.getInstanceID())
 
+	instance.cache.Invalidate(instance.InstanceID)
+
 	if _, err = instance.client.StopInstancesWithContext(ctx, input); err == nil {
 		input := &ec2.DescribeInstancesInput{
 			InstanceIds: []*string{
@@ -647,7 +1225,7 @@ func (instance *Ec2Instance) Status() (*Status, error) {
 
 	glog.Debugf("Status: instance %s id (%s)", instance.InstanceName, instance.getInstanceID())
 
-	if ec2Instance, err := instance.getEc2Instance(); err != nil {
+	if ec2Instance, err := instance.describeEc2Instance(); err != nil {
 		return nil, err
 	} else {
 
@@ -667,115 +1245,12 @@ func (instance *Ec2Instance) Status() (*Status, error) {
 			}
 
 			return &Status{
-				Address: *address,
-				Powered: *code == 16 || *code == 0,
+				Address:     *address,
+				AddressIPv6: aws.StringValue(instanceIPv6Address(ec2Instance)),
+				Powered:     *code == 16 || *code == 0,
 			}, nil
 		} else {
 			return &Status{}, nil
 		}
 	}
 }
-
-func (instance *Ec2Instance) getRegisteredRecordSetAddress(conf *Configuration, name string) (*string, error) {
-	if session, e := newSessionWithOptions(conf.GetRoute53AccessKey(), conf.GetRoute53SecretKey(), conf.GetRoute53AccessToken(), conf.GetFileName(), conf.GetRoute53Profile(), conf.GetRoute53Region()); e == nil {
-		svc := route53.New(session)
-
-		input := &route53.ListResourceRecordSetsInput{
-			HostedZoneId:    aws.String(conf.Network.ZoneID),
-			MaxItems:        aws.String("1"),
-			StartRecordName: aws.String(name),
-			StartRecordType: aws.String("A"),
-		}
-
-		if output, err := svc.ListResourceRecordSets(input); err == nil {
-			if len(output.ResourceRecordSets) > 0 && len(output.ResourceRecordSets[0].ResourceRecords) > 0 {
-				return output.ResourceRecordSets[0].ResourceRecords[0].Value, nil
-			} else {
-				return nil, fmt.Errorf("route53 entry:%s not found", name)
-			}
-		} else {
-			return nil, err
-		}
-	} else {
-		return nil, e
-	}
-}
-
-func (instance *Ec2Instance) changeResourceRecordSetsInput(conf *Configuration, cmd, name, address string, wait bool) error {
-	var svc *route53.Route53
-
-	if session, e := newSessionWithOptions(conf.GetRoute53AccessKey(), conf.GetRoute53SecretKey(), conf.GetRoute53AccessToken(), conf.GetFileName(), conf.GetRoute53Profile(), conf.GetRoute53Region()); e != nil {
-		return e
-	} else {
-		svc = route53.New(session)
-
-		input := &route53.ChangeResourceRecordSetsInput{
-			HostedZoneId: aws.String(conf.Network.ZoneID),
-			ChangeBatch: &route53.ChangeBatch{
-				Comment: aws.String("Kubernetes worker node"),
-				Changes: []*route53.Change{
-					{
-						Action: aws.String(cmd),
-						ResourceRecordSet: &route53.ResourceRecordSet{
-							Name: aws.String(name),
-							TTL:  aws.Int64(60),
-							Type: aws.String("A"),
-							ResourceRecords: []*route53.ResourceRecord{
-								{
-									Value: aws.String(address),
-								},
-							},
-						},
-					},
-				},
-			},
-		}
-
-		result, err := svc.ChangeResourceRecordSets(input)
-
-		if err != nil {
-			if aerr, ok := err.(awserr.Error); ok {
-				switch aerr.Code() {
-				case route53.ErrCodeNoSuchHostedZone:
-					return fmt.Errorf("%s, %v", route53.ErrCodeNoSuchHostedZone, aerr.Error())
-				case route53.ErrCodeNoSuchHealthCheck:
-					return fmt.Errorf("%s, %v", route53.ErrCodeNoSuchHealthCheck, aerr.Error())
-				case route53.ErrCodeInvalidChangeBatch:
-					return fmt.Errorf("%s, %v", route53.ErrCodeInvalidChangeBatch, aerr.Error())
-				case route53.ErrCodeInvalidInput:
-					return fmt.Errorf("%s, %v", route53.ErrCodeInvalidInput, aerr.Error())
-				case route53.ErrCodePriorRequestNotComplete:
-					return fmt.Errorf("%s, %v", route53.ErrCodePriorRequestNotComplete, aerr.Error())
-				default:
-					return aerr
-				}
-			} else {
-				return err
-			}
-		}
-
-		if wait {
-			input := &route53.GetChangeInput{
-				Id: result.ChangeInfo.Id,
-			}
-
-			return svc.WaitUntilResourceRecordSetsChanged(input)
-		}
-
-		return nil
-	}
-}
-
-// RegisterDNS register EC2 instance in Route53
-func (instance *Ec2Instance) RegisterDNS(conf *Configuration, name, address string, wait bool) error {
-	return instance.changeResourceRecordSetsInput(conf, route53_UpsertCmd, name, address, wait)
-}
-
-// UnRegisterDNS unregister EC2 instance in Route53
-func (instance *Ec2Instance) UnRegisterDNS(conf *Configuration, name string, wait bool) error {
-	if address, err := instance.getRegisteredRecordSetAddress(conf, name); err == nil {
-		return instance.changeResourceRecordSetsInput(conf, route53_DeleteCmd, name, *address, wait)
-	}
-
-	return nil
-}
@@ -0,0 +1,160 @@
+package aws
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/Fred78290/kubernetes-aws-autoscaler/constantes"
+	"github.com/Fred78290/kubernetes-aws-autoscaler/context"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	glog "github.com/sirupsen/logrus"
+)
+
+const (
+	// SpotInterruptionWarning detail type of EventBridge event sent two minutes before a spot instance is interrupted
+	SpotInterruptionWarning = "EC2 Spot Instance Interruption Warning"
+
+	// RebalanceRecommendation detail type of EventBridge event sent when a spot instance is at elevated risk of interruption
+	RebalanceRecommendation = "EC2 Instance Rebalance Recommendation"
+
+	// defaultInterruptionWaitTime is the long polling time in seconds of the interruption queue
+	defaultInterruptionWaitTime = 20
+
+	// interruptionMaxMessages is the max number of messages received at once, the sqs limit
+	interruptionMaxMessages = 10
+)
+
+// InterruptionQueueOptions declare the SQS queue fed by EventBridge with spot interruption and rebalance events.
+// Endpoint override the SQS endpoint, to use a local SQS compatible service
+type InterruptionQueueOptions struct {
+	URL               string `json:"url"`
+	Endpoint          string `json:"endpoint,omitempty"`
+	WaitTime          int    `json:"waitTime,omitempty"`
+	LaunchReplacement bool   `json:"launchReplacement,omitempty"`
+}
+
+// InterruptionEvent spot interruption warning or rebalance recommendation of an instance
+type InterruptionEvent struct {
+	Kind          string
+	InstanceID    string
+	Time          time.Time
+	receiptHandle *string
+}
+
+// eventBridgeEvent EC2 event forwarded by EventBridge
+type eventBridgeEvent struct {
+	DetailType string    `json:"detail-type"`
+	Source     string    `json:"source"`
+	Time       time.Time `json:"time"`
+	Detail     struct {
+		InstanceID     string `json:"instance-id"`
+		InstanceAction string `json:"instance-action,omitempty"`
+	} `json:"detail"`
+}
+
+// GetWaitTime return the long polling time of the queue
+func (options *InterruptionQueueOptions) GetWaitTime() time.Duration {
+	if options.WaitTime <= 0 {
+		return defaultInterruptionWaitTime * time.Second
+	}
+
+	return time.Duration(options.WaitTime) * time.Second
+}
+
+// parseInterruptionEvent return the event of the message, nil for other events
+func parseInterruptionEvent(message *sqs.Message) (*InterruptionEvent, error) {
+	var event eventBridgeEvent
+
+	if err := json.Unmarshal([]byte(aws.StringValue(message.Body)), &event); err != nil {
+		return nil, err
+	}
+
+	if event.DetailType != SpotInterruptionWarning && event.DetailType != RebalanceRecommendation {
+		return nil, nil
+	}
+
+	return &InterruptionEvent{
+		Kind:          event.DetailType,
+		InstanceID:    event.Detail.InstanceID,
+		Time:          event.Time,
+		receiptHandle: message.ReceiptHandle,
+	}, nil
+}
+
+// ReceiveInterruptionEvents wait for events from the interruption queue.
+// Messages not related to interruption are deleted from the queue
+func (conf *Configuration) ReceiveInterruptionEvents() ([]*InterruptionEvent, error) {
+	options := conf.InterruptionQueue
+
+	client, err := createSQSClient(conf)
+
+	if err != nil {
+		return nil, err
+	}
+
+	waitTime := options.GetWaitTime()
+	timeout := conf.Timeout
+
+	// The request last the long polling time
+	if timeout > 0 {
+		timeout += waitTime / time.Second
+	}
+
+	ctx := context.NewContext(timeout)
+	defer ctx.Cancel()
+
+	input := &sqs.ReceiveMessageInput{
+		QueueUrl:            aws.String(options.URL),
+		MaxNumberOfMessages: aws.Int64(interruptionMaxMessages),
+		WaitTimeSeconds:     aws.Int64(int64(waitTime / time.Second)),
+	}
+
+	output, err := client.ReceiveMessageWithContext(ctx, input)
+
+	if err != nil {
+		return nil, fmt.Errorf(constantes.ErrUnableToReceiveInterruptionEvents, options.URL, err)
+	}
+
+	events := make([]*InterruptionEvent, 0, len(output.Messages))
+
+	for _, message := range output.Messages {
+		event, err := parseInterruptionEvent(message)
+
+		if err != nil {
+			glog.Warnf(constantes.WarnUnparsableInterruptionEvent, aws.StringValue(message.MessageId), err)
+		}
+
+		if event != nil {
+			events = append(events, event)
+		} else if err = conf.deleteInterruptionMessage(message.ReceiptHandle); err != nil {
+			glog.Errorf(constantes.ErrUnableToDeleteInterruptionEvent, aws.StringValue(message.MessageId), err)
+		}
+	}
+
+	return events, nil
+}
+
+// DeleteInterruptionEvent remove the handled event from the queue
+func (conf *Configuration) DeleteInterruptionEvent(event *InterruptionEvent) error {
+	return conf.deleteInterruptionMessage(event.receiptHandle)
+}
+
+func (conf *Configuration) deleteInterruptionMessage(receiptHandle *string) error {
+	client, err := createSQSClient(conf)
+
+	if err != nil {
+		return err
+	}
+
+	ctx := context.NewContext(conf.Timeout)
+	defer ctx.Cancel()
+
+	_, err = client.DeleteMessageWithContext(ctx, &sqs.DeleteMessageInput{
+		QueueUrl:      aws.String(conf.InterruptionQueue.URL),
+		ReceiptHandle: receiptHandle,
+	})
+
+	return err
+}
@@ -0,0 +1,243 @@
+package aws
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/Fred78290/kubernetes-aws-autoscaler/constantes"
+	"github.com/Fred78290/kubernetes-aws-autoscaler/context"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	glog "github.com/sirupsen/logrus"
+)
+
+// defaultMachineTypesCacheTTL is the time in seconds the cached machine types are used without calling the api
+const defaultMachineTypesCacheTTL = 86400
+
+// MachineTypesDiscovery declare how machine types are discovered with DescribeInstanceTypes.
+// Families are prefix of instance type like m5 or t3a, allow and deny are glob patterns like m5.*
+type MachineTypesDiscovery struct {
+	Enabled   bool          `json:"enabled"`
+	Families  []string      `json:"families,omitempty"`
+	Allow     []string      `json:"allow,omitempty"`
+	Deny      []string      `json:"deny,omitempty"`
+	CacheFile string        `json:"cacheFile,omitempty"`
+	CacheTTL  time.Duration `json:"cacheTTL,omitempty"`
+}
+
+// MachineType describe a discovered instance type, memory in megabytes, instance storage in gigabytes
+type MachineType struct {
+	Memory          int      `json:"memsize"`
+	Vcpu            int      `json:"vcpus"`
+	Architectures   []string `json:"architectures,omitempty"`
+	GPU             int      `json:"gpus,omitempty"`
+	MaxENI          int      `json:"maxENI,omitempty"`
+	MaxIPv4PerENI   int      `json:"maxIPv4PerENI,omitempty"`
+	InstanceStorage int      `json:"instanceStorage,omitempty"`
+}
+
+// machineTypesCache is the content of the cache file
+type machineTypesCache struct {
+	Region       string                  `json:"region"`
+	UpdatedAt    time.Time               `json:"updatedAt"`
+	MachineTypes map[string]*MachineType `json:"machineTypes"`
+}
+
+// GetCacheTTL return the duration the cache file is used without calling the api
+func (discovery *MachineTypesDiscovery) GetCacheTTL() time.Duration {
+	if discovery.CacheTTL <= 0 {
+		return defaultMachineTypesCacheTTL * time.Second
+	}
+
+	return discovery.CacheTTL * time.Second
+}
+
+func matchPatterns(name string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(pattern, name); matched {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Accept return true if the instance type pass the families, allow and deny filters
+func (discovery *MachineTypesDiscovery) Accept(instanceType string) bool {
+	if len(discovery.Families) > 0 {
+		family := strings.Split(instanceType, ".")[0]
+		found := false
+
+		for _, f := range discovery.Families {
+			if f == family {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return false
+		}
+	}
+
+	if len(discovery.Allow) > 0 && !matchPatterns(instanceType, discovery.Allow) {
+		return false
+	}
+
+	return !matchPatterns(instanceType, discovery.Deny)
+}
+
+func (discovery *MachineTypesDiscovery) filter(machineTypes map[string]*MachineType) map[string]*MachineType {
+	result := make(map[string]*MachineType)
+
+	for name, machineType := range machineTypes {
+		if discovery.Accept(name) {
+			result[name] = machineType
+		}
+	}
+
+	return result
+}
+
+func (discovery *MachineTypesDiscovery) loadCache(region string) (*machineTypesCache, error) {
+	var cache machineTypesCache
+
+	content, err := os.ReadFile(discovery.CacheFile)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err = json.Unmarshal(content, &cache); err != nil {
+		return nil, err
+	}
+
+	if cache.Region != region {
+		return nil, fmt.Errorf(constantes.ErrMachineTypesCacheRegionMismatch, discovery.CacheFile, cache.Region, region)
+	}
+
+	return &cache, nil
+}
+
+func (discovery *MachineTypesDiscovery) saveCache(region string, machineTypes map[string]*MachineType) error {
+	content, err := json.MarshalIndent(&machineTypesCache{
+		Region:       region,
+		UpdatedAt:    time.Now(),
+		MachineTypes: machineTypes,
+	}, "", "  ")
+
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(discovery.CacheFile, content, 0644)
+}
+
+func newMachineType(info *ec2.InstanceTypeInfo) *MachineType {
+	machineType := &MachineType{}
+
+	if info.VCpuInfo != nil {
+		machineType.Vcpu = int(aws.Int64Value(info.VCpuInfo.DefaultVCpus))
+	}
+
+	if info.MemoryInfo != nil {
+		machineType.Memory = int(aws.Int64Value(info.MemoryInfo.SizeInMiB))
+	}
+
+	if info.ProcessorInfo != nil {
+		machineType.Architectures = aws.StringValueSlice(info.ProcessorInfo.SupportedArchitectures)
+	}
+
+	if info.GpuInfo != nil {
+		for _, gpu := range info.GpuInfo.Gpus {
+			machineType.GPU += int(aws.Int64Value(gpu.Count))
+		}
+	}
+
+	if info.NetworkInfo != nil {
+		machineType.MaxENI = int(aws.Int64Value(info.NetworkInfo.MaximumNetworkInterfaces))
+		machineType.MaxIPv4PerENI = int(aws.Int64Value(info.NetworkInfo.Ipv4AddressesPerInterface))
+	}
+
+	if info.InstanceStorageInfo != nil {
+		machineType.InstanceStorage = int(aws.Int64Value(info.InstanceStorageInfo.TotalSizeInGB))
+	}
+
+	return machineType
+}
+
+// describeMachineTypes return all instance types of the region
+func (conf *Configuration) describeMachineTypes() (map[string]*MachineType, error) {
+	client, err := createClient(conf)
+
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := context.NewContext(conf.Timeout)
+	defer ctx.Cancel()
+
+	machineTypes := make(map[string]*MachineType)
+	input := &ec2.DescribeInstanceTypesInput{
+		MaxResults: aws.Int64(100),
+	}
+
+	for {
+		output, err := client.DescribeInstanceTypesWithContext(ctx, input)
+
+		if err != nil {
+			return nil, err
+		}
+
+		for _, info := range output.InstanceTypes {
+			machineTypes[aws.StringValue(info.InstanceType)] = newMachineType(info)
+		}
+
+		if isNullOrEmpty(aws.StringValue(output.NextToken)) {
+			break
+		}
+
+		input.NextToken = output.NextToken
+	}
+
+	return machineTypes, nil
+}
+
+// DiscoverMachineTypes return the instance types of the region accepted by the discovery filters.
+// A fresh cache file avoid the api call, a stale cache file is used when the api is unreachable
+func (conf *Configuration) DiscoverMachineTypes(discovery *MachineTypesDiscovery) (map[string]*MachineType, error) {
+	var cache *machineTypesCache
+	var err error
+
+	if len(discovery.CacheFile) > 0 {
+		if cache, err = discovery.loadCache(conf.Region); err != nil {
+			glog.Debugf("Machine types cache %s not used, reason: %v", discovery.CacheFile, err)
+		} else if time.Since(cache.UpdatedAt) < discovery.GetCacheTTL() {
+			return discovery.filter(cache.MachineTypes), nil
+		}
+	}
+
+	machineTypes, err := conf.describeMachineTypes()
+
+	if err != nil {
+		if cache != nil {
+			glog.Warnf(constantes.WarnMachineTypesStaleCache, discovery.CacheFile, err)
+
+			return discovery.filter(cache.MachineTypes), nil
+		}
+
+		return nil, fmt.Errorf(constantes.ErrUnableToDiscoverMachineTypes, conf.Region, err)
+	}
+
+	if len(discovery.CacheFile) > 0 {
+		if err = discovery.saveCache(conf.Region, machineTypes); err != nil {
+			glog.Warnf(constantes.WarnUnableToSaveMachineTypesCache, discovery.CacheFile, err)
+		}
+	}
+
+	return discovery.filter(machineTypes), nil
+}
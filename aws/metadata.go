@@ -0,0 +1,44 @@
+package aws
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+)
+
+// MetadataOptions declare the instance metadata service options.
+// HTTPTokens required enforce IMDSv2, a hop limit of 2 let containers without host network reach the service
+type MetadataOptions struct {
+	HTTPTokens              string `json:"httpTokens,omitempty"`
+	HTTPPutResponseHopLimit int    `json:"httpPutResponseHopLimit,omitempty"`
+	HTTPEndpoint            string `json:"httpEndpoint,omitempty"`
+	InstanceMetadataTags    string `json:"instanceMetadataTags,omitempty"`
+}
+
+// buildMetadataOptions return the instance metadata options, nil if not declared
+func (instance *Ec2Instance) buildMetadataOptions() *ec2.InstanceMetadataOptionsRequest {
+	options := instance.config.MetadataOptions
+
+	if options == nil {
+		return nil
+	}
+
+	metadataOptions := &ec2.InstanceMetadataOptionsRequest{}
+
+	if !isNullOrEmpty(options.HTTPTokens) {
+		metadataOptions.HttpTokens = aws.String(options.HTTPTokens)
+	}
+
+	if options.HTTPPutResponseHopLimit > 0 {
+		metadataOptions.HttpPutResponseHopLimit = aws.Int64(int64(options.HTTPPutResponseHopLimit))
+	}
+
+	if !isNullOrEmpty(options.HTTPEndpoint) {
+		metadataOptions.HttpEndpoint = aws.String(options.HTTPEndpoint)
+	}
+
+	if !isNullOrEmpty(options.InstanceMetadataTags) {
+		metadataOptions.InstanceMetadataTags = aws.String(options.InstanceMetadataTags)
+	}
+
+	return metadataOptions
+}
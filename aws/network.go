@@ -0,0 +1,358 @@
+package aws
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Fred78290/kubernetes-aws-autoscaler/constantes"
+	"github.com/Fred78290/kubernetes-aws-autoscaler/context"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	glog "github.com/sirupsen/logrus"
+)
+
+const (
+	// defaultNetworkDiscoveryTTL is the time in seconds discovered subnets and security groups are used before discovering them again
+	defaultNetworkDiscoveryTTL = 300
+
+	// defaultMinFreeIPAddresses is the number of free IP addresses required to choose a subnet
+	defaultMinFreeIPAddresses = 1
+)
+
+// discoveredResources resources IDs matching a tag selector
+type discoveredResources struct {
+	ids          []string
+	discoveredAt time.Time
+}
+
+// discoveryKey identify resources discovered with the same credentials and region, in the same VPC and matching the same selector
+type discoveryKey struct {
+	options  sessionOptions
+	kind     string
+	vpcID    string
+	selector string
+}
+
+// discoveredNetworks cache subnets and security groups by discoveryKey
+var discoveredNetworks sync.Map
+
+// resourceVpcs cache VPC by region and subnet or security group ID
+var resourceVpcs sync.Map
+
+// GetNetworkDiscoveryTTL return the duration discovered subnets and security groups are used
+func (conf *Configuration) GetNetworkDiscoveryTTL() time.Duration {
+	if conf.NetworkDiscoveryTTL <= 0 {
+		return defaultNetworkDiscoveryTTL * time.Second
+	}
+
+	return conf.NetworkDiscoveryTTL * time.Second
+}
+
+// GetMinFreeIPAddresses return the number of free IP addresses required to choose a subnet
+func (eni *NetworkInterface) GetMinFreeIPAddresses() int {
+	if eni.MinFreeIPAddresses <= 0 {
+		return defaultMinFreeIPAddresses
+	}
+
+	return eni.MinFreeIPAddresses
+}
+
+// HasSelector return true if subnets or security groups are discovered by tags
+func (eni *NetworkInterface) HasSelector() bool {
+	return len(eni.SubnetSelector) > 0 || len(eni.SecurityGroupSelector) > 0
+}
+
+// getSecurityGroups return the discovered security groups or the declared one
+func (eni *NetworkInterface) getSecurityGroups() []*string {
+	if len(eni.securityGroupsID) > 0 {
+		return aws.StringSlice(eni.securityGroupsID)
+	}
+
+	return []*string{
+		aws.String(eni.SecurityGroupID),
+	}
+}
+
+// tagFilters return the filters matching the tag selector, a tag without value match the tag key only
+func tagFilters(selector map[string]string) []*ec2.Filter {
+	keys := make([]string, 0, len(selector))
+
+	for key := range selector {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	filters := make([]*ec2.Filter, 0, len(keys))
+
+	for _, key := range keys {
+		if value := selector[key]; isNullOrEmpty(value) {
+			filters = append(filters, &ec2.Filter{
+				Name:   aws.String("tag-key"),
+				Values: []*string{aws.String(key)},
+			})
+		} else {
+			filters = append(filters, &ec2.Filter{
+				Name:   aws.String("tag:" + key),
+				Values: []*string{aws.String(value)},
+			})
+		}
+	}
+
+	return filters
+}
+
+// selectorKey return the cache key of a tag selector
+func (instance *Ec2Instance) selectorKey(kind string, eni *NetworkInterface, selector map[string]string, extra ...string) discoveryKey {
+	parts := make([]string, 0, len(selector))
+
+	for key, value := range selector {
+		parts = append(parts, key+"="+value)
+	}
+
+	sort.Strings(parts)
+
+	return discoveryKey{
+		options:  ec2SessionOptions(instance.config),
+		kind:     kind,
+		vpcID:    eni.vpcID,
+		selector: fmt.Sprintf("%s/%s", strings.Join(parts, ","), strings.Join(extra, ",")),
+	}
+}
+
+// subnetsKey return the cache key of the subnets discovered for the interface
+func (instance *Ec2Instance) subnetsKey(eni *NetworkInterface) discoveryKey {
+	return instance.selectorKey("subnet", eni, eni.SubnetSelector, fmt.Sprint(eni.GetMinFreeIPAddresses()))
+}
+
+// vpcFilter restrict the discovery to the VPC of the interface
+func vpcFilter(eni *NetworkInterface) *ec2.Filter {
+	return &ec2.Filter{
+		Name:   aws.String("vpc-id"),
+		Values: []*string{aws.String(eni.vpcID)},
+	}
+}
+
+// getVpcID return the VPC scoping the discovery, the declared one else the VPC of the declared subnet or security group
+func (instance *Ec2Instance) getVpcID(ctx *context.Context, eni *NetworkInterface) (string, error) {
+	var resourceID string
+	var vpcID *string
+
+	if len(eni.VpcID) > 0 {
+		return eni.VpcID, nil
+	} else if len(eni.SubnetsID) > 0 {
+		resourceID = eni.SubnetsID[0]
+	} else if len(eni.SecurityGroupID) > 0 {
+		resourceID = eni.SecurityGroupID
+	} else {
+		return "", fmt.Errorf(constantes.ErrUnknownNetworkVpc, eni.SubnetSelector, eni.SecurityGroupSelector)
+	}
+
+	key := fmt.Sprintf("%s/%s", instance.config.Region, resourceID)
+
+	if cached, found := resourceVpcs.Load(key); found {
+		return cached.(string), nil
+	}
+
+	if len(eni.SubnetsID) > 0 {
+		if output, err := instance.client.DescribeSubnetsWithContext(ctx, &ec2.DescribeSubnetsInput{
+			SubnetIds: []*string{aws.String(resourceID)},
+		}); err != nil {
+			return "", fmt.Errorf(constantes.ErrUnableToDescribeSubnets, err)
+		} else if len(output.Subnets) > 0 {
+			vpcID = output.Subnets[0].VpcId
+		}
+	} else if output, err := instance.client.DescribeSecurityGroupsWithContext(ctx, &ec2.DescribeSecurityGroupsInput{
+		GroupIds: []*string{aws.String(resourceID)},
+	}); err != nil {
+		return "", fmt.Errorf(constantes.ErrUnableToDescribeSecurityGroups, err)
+	} else if len(output.SecurityGroups) > 0 {
+		vpcID = output.SecurityGroups[0].VpcId
+	}
+
+	if isNullOrEmpty(aws.StringValue(vpcID)) {
+		return "", fmt.Errorf(constantes.ErrUnknownNetworkVpc, eni.SubnetSelector, eni.SecurityGroupSelector)
+	}
+
+	resourceVpcs.Store(key, *vpcID)
+
+	return *vpcID, nil
+}
+
+// loadDiscovered return the cached resources IDs if not expired
+func (instance *Ec2Instance) loadDiscovered(key discoveryKey) ([]string, bool) {
+	if cached, found := discoveredNetworks.Load(key); found {
+		if resources := cached.(*discoveredResources); time.Since(resources.discoveredAt) < instance.config.GetNetworkDiscoveryTTL() {
+			return resources.ids, true
+		}
+	}
+
+	return nil, false
+}
+
+// discoverSubnets return the available subnets matching the selector with enough free IP addresses, sorted by ID
+func (instance *Ec2Instance) discoverSubnets(ctx *context.Context, eni *NetworkInterface) ([]string, error) {
+	minFreeIPAddresses := eni.GetMinFreeIPAddresses()
+	key := instance.subnetsKey(eni)
+
+	if subnetsID, found := instance.loadDiscovered(key); found {
+		return subnetsID, nil
+	}
+
+	input := &ec2.DescribeSubnetsInput{
+		Filters: append(tagFilters(eni.SubnetSelector), vpcFilter(eni), &ec2.Filter{
+			Name:   aws.String("state"),
+			Values: []*string{aws.String(ec2.SubnetStateAvailable)},
+		}),
+	}
+
+	output, err := instance.client.DescribeSubnetsWithContext(ctx, input)
+
+	if err != nil {
+		return nil, fmt.Errorf(constantes.ErrUnableToDiscoverSubnets, eni.SubnetSelector, err)
+	}
+
+	subnetsID := make([]string, 0, len(output.Subnets))
+
+	for _, subnet := range output.Subnets {
+		subnetID := aws.StringValue(subnet.SubnetId)
+
+		if freeIPAddresses := aws.Int64Value(subnet.AvailableIpAddressCount); freeIPAddresses < int64(minFreeIPAddresses) {
+			glog.Warnf(constantes.WarnSubnetWithoutFreeIPAddresses, subnetID, freeIPAddresses)
+		} else {
+			subnetsID = append(subnetsID, subnetID)
+
+			subnetZones.Store(fmt.Sprintf("%s/%s", instance.config.Region, subnetID), aws.StringValue(subnet.AvailabilityZone))
+		}
+	}
+
+	if len(subnetsID) == 0 {
+		return nil, fmt.Errorf(constantes.ErrNoSubnetMatchSelector, minFreeIPAddresses, eni.SubnetSelector)
+	}
+
+	sort.Strings(subnetsID)
+
+	glog.Debugf("discoverSubnets: selector %v, subnets: %v", eni.SubnetSelector, subnetsID)
+
+	discoveredNetworks.Store(key, &discoveredResources{
+		ids:          subnetsID,
+		discoveredAt: time.Now(),
+	})
+
+	return subnetsID, nil
+}
+
+// subnetWithFreeIPAddresses return the first candidate subnet still owning enough free IP addresses,
+// discovered subnets are cached and can be exhausted since their discovery
+func (instance *Ec2Instance) subnetWithFreeIPAddresses(ctx *context.Context, eni *NetworkInterface, candidates []string) (*string, error) {
+	minFreeIPAddresses := eni.GetMinFreeIPAddresses()
+
+	output, err := instance.client.DescribeSubnetsWithContext(ctx, &ec2.DescribeSubnetsInput{
+		SubnetIds: aws.StringSlice(candidates),
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf(constantes.ErrUnableToDescribeSubnets, err)
+	}
+
+	freeIPAddresses := make(map[string]int64, len(output.Subnets))
+
+	for _, subnet := range output.Subnets {
+		freeIPAddresses[aws.StringValue(subnet.SubnetId)] = aws.Int64Value(subnet.AvailableIpAddressCount)
+	}
+
+	for _, subnetID := range candidates {
+		if freeIPAddresses[subnetID] >= int64(minFreeIPAddresses) {
+			return aws.String(subnetID), nil
+		}
+
+		glog.Warnf(constantes.WarnSubnetWithoutFreeIPAddresses, subnetID, freeIPAddresses[subnetID])
+	}
+
+	// Discover again on next launch
+	discoveredNetworks.Delete(instance.subnetsKey(eni))
+
+	return nil, fmt.Errorf(constantes.ErrNoSubnetMatchSelector, minFreeIPAddresses, eni.SubnetSelector)
+}
+
+// discoverSecurityGroups return the security groups matching the selector, sorted by ID
+func (instance *Ec2Instance) discoverSecurityGroups(ctx *context.Context, eni *NetworkInterface) ([]string, error) {
+	key := instance.selectorKey("sg", eni, eni.SecurityGroupSelector)
+
+	if groupsID, found := instance.loadDiscovered(key); found {
+		return groupsID, nil
+	}
+
+	input := &ec2.DescribeSecurityGroupsInput{
+		Filters: append(tagFilters(eni.SecurityGroupSelector), vpcFilter(eni)),
+	}
+
+	output, err := instance.client.DescribeSecurityGroupsWithContext(ctx, input)
+
+	if err != nil {
+		return nil, fmt.Errorf(constantes.ErrUnableToDiscoverSecurityGroups, eni.SecurityGroupSelector, err)
+	}
+
+	groupsID := make([]string, 0, len(output.SecurityGroups))
+
+	for _, group := range output.SecurityGroups {
+		groupsID = append(groupsID, aws.StringValue(group.GroupId))
+	}
+
+	if len(groupsID) == 0 {
+		return nil, fmt.Errorf(constantes.ErrNoSecurityGroupMatchSelector, eni.SecurityGroupSelector)
+	}
+
+	sort.Strings(groupsID)
+
+	discoveredNetworks.Store(key, &discoveredResources{
+		ids:          groupsID,
+		discoveredAt: time.Now(),
+	})
+
+	return groupsID, nil
+}
+
+// discoverNetworkInterfaces return the declared interfaces with subnets and security groups discovered by tags
+func (instance *Ec2Instance) discoverNetworkInterfaces(ctx *context.Context) ([]NetworkInterface, error) {
+	var err error
+
+	interfaces := make([]NetworkInterface, len(instance.config.Network.ENI))
+
+	for index, eni := range instance.config.Network.ENI {
+		// Selectors only match resources of the interface VPC
+		if eni.HasSelector() {
+			if eni.vpcID, err = instance.getVpcID(ctx, &eni); err != nil {
+				return nil, err
+			}
+		}
+
+		if len(eni.SubnetSelector) > 0 {
+			if eni.SubnetsID, err = instance.discoverSubnets(ctx, &eni); err != nil {
+				return nil, err
+			}
+		}
+
+		if len(eni.SecurityGroupSelector) > 0 {
+			if eni.securityGroupsID, err = instance.discoverSecurityGroups(ctx, &eni); err != nil {
+				return nil, err
+			}
+		}
+
+		interfaces[index] = eni
+	}
+
+	return interfaces, nil
+}
+
+// networkInterfaces return the interfaces resolved for the instance, the declared interfaces if not resolved
+func (instance *Ec2Instance) networkInterfaces() []NetworkInterface {
+	if instance.network != nil {
+		return instance.network
+	}
+
+	return instance.config.Network.ENI
+}
@@ -0,0 +1,139 @@
+package aws
+
+import (
+	"errors"
+
+	"github.com/Fred78290/kubernetes-aws-autoscaler/constantes"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	glog "github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/util/uuid"
+)
+
+// reservationExhaustedErrors aws error codes meaning the targeted capacity reservation is full
+var reservationExhaustedErrors = []string{
+	"ReservationCapacityExceeded",
+	"InsufficientCapacityReservationCapacity",
+}
+
+// PlacementOptions declare the placement group, the tenancy and the host resource group of instances
+type PlacementOptions struct {
+	GroupName            string `json:"groupName,omitempty"`
+	PartitionNumber      int    `json:"partitionNumber,omitempty"`
+	Tenancy              string `json:"tenancy,omitempty"`
+	HostResourceGroupArn string `json:"hostResourceGroupArn,omitempty"`
+}
+
+// CapacityReservationOptions declare the On-Demand Capacity Reservation targeted by instances.
+// Preference is open or none, a reservation ID or a reservation group ARN target a specific reservation.
+// When the targeted reservation is exhausted, the launch fallback to open preference unless disabled
+type CapacityReservationOptions struct {
+	Preference       string `json:"preference,omitempty"`
+	ID               string `json:"id,omitempty"`
+	ResourceGroupArn string `json:"resourceGroupArn,omitempty"`
+	DisableFallback  bool   `json:"disableFallback,omitempty"`
+}
+
+// IsTargeted return true if a specific reservation or reservation group is targeted
+func (options *CapacityReservationOptions) IsTargeted() bool {
+	return options != nil && (!isNullOrEmpty(options.ID) || !isNullOrEmpty(options.ResourceGroupArn))
+}
+
+// IsReservationExhaustedError return true if the error means the targeted capacity reservation is full
+func IsReservationExhaustedError(err error) bool {
+	var aerr awserr.Error
+
+	if errors.As(err, &aerr) {
+		for _, code := range reservationExhaustedErrors {
+			if aerr.Code() == code {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// buildPlacement return the placement of the instance, nil if not declared
+func (instance *Ec2Instance) buildPlacement() *ec2.Placement {
+	options := instance.config.Placement
+
+	if options == nil {
+		return nil
+	}
+
+	placement := &ec2.Placement{}
+
+	if !isNullOrEmpty(options.GroupName) {
+		placement.GroupName = aws.String(options.GroupName)
+
+		if options.PartitionNumber > 0 {
+			placement.PartitionNumber = aws.Int64(int64(options.PartitionNumber))
+		}
+	}
+
+	if !isNullOrEmpty(options.Tenancy) {
+		placement.Tenancy = aws.String(options.Tenancy)
+	}
+
+	if !isNullOrEmpty(options.HostResourceGroupArn) {
+		placement.HostResourceGroupArn = aws.String(options.HostResourceGroupArn)
+	}
+
+	return placement
+}
+
+// buildCapacityReservationSpecification return the capacity reservation targeted by the instance, nil if not declared
+func (instance *Ec2Instance) buildCapacityReservationSpecification() *ec2.CapacityReservationSpecification {
+	options := instance.config.CapacityReservation
+
+	if options == nil {
+		return nil
+	}
+
+	if options.IsTargeted() {
+		target := &ec2.CapacityReservationTarget{}
+
+		if !isNullOrEmpty(options.ID) {
+			target.CapacityReservationId = aws.String(options.ID)
+		} else {
+			target.CapacityReservationResourceGroupArn = aws.String(options.ResourceGroupArn)
+		}
+
+		return &ec2.CapacityReservationSpecification{
+			CapacityReservationTarget: target,
+		}
+	}
+
+	if isNullOrEmpty(options.Preference) {
+		return nil
+	}
+
+	return &ec2.CapacityReservationSpecification{
+		CapacityReservationPreference: aws.String(options.Preference),
+	}
+}
+
+// runInstances launch the instance, if the targeted capacity reservation is exhausted the launch is retried
+// with open preference, the instance run in any open reservation or on-demand
+func (instance *Ec2Instance) runInstances(ctx aws.Context, input *ec2.RunInstancesInput) (*ec2.Reservation, error) {
+	result, err := instance.client.RunInstancesWithContext(ctx, input)
+
+	if err != nil && input.CapacityReservationSpecification != nil && input.CapacityReservationSpecification.CapacityReservationTarget != nil {
+		if IsReservationExhaustedError(err) && !instance.config.CapacityReservation.DisableFallback {
+			glog.Warnf(constantes.WarnCapacityReservationExhausted, instance.InstanceName, err)
+
+			// Same client token with other parameters is rejected
+			fallback := *input
+			fallback.ClientToken = aws.String(string(uuid.NewUUID()))
+			fallback.CapacityReservationSpecification = &ec2.CapacityReservationSpecification{
+				CapacityReservationPreference: aws.String(ec2.CapacityReservationPreferenceOpen),
+			}
+
+			result, err = instance.client.RunInstancesWithContext(ctx, &fallback)
+		}
+	}
+
+	return result, err
+}
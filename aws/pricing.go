@@ -0,0 +1,320 @@
+package aws
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Fred78290/kubernetes-aws-autoscaler/constantes"
+	"github.com/Fred78290/kubernetes-aws-autoscaler/context"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/pricing"
+	glog "github.com/sirupsen/logrus"
+)
+
+const (
+	// defaultPriceRefreshInterval is the time in seconds an instance type price is used before asking the price list api
+	defaultPriceRefreshInterval = 86400
+
+	// pricingRegion is the region serving the price list api
+	pricingRegion = "us-east-1"
+)
+
+// PricingOptions declare the price catalog file and if prices are refreshed from the price list api.
+// Refresh interval is in seconds
+type PricingOptions struct {
+	CatalogFile     string        `json:"catalogFile,omitempty"`
+	RefreshFromAPI  bool          `json:"refreshFromApi,omitempty"`
+	RefreshInterval time.Duration `json:"refreshInterval,omitempty"`
+}
+
+// InstancePrice hourly prices in USD of an instance type
+type InstancePrice struct {
+	OnDemand float64 `json:"onDemand"`
+	Spot     float64 `json:"spot,omitempty"`
+}
+
+// PriceCatalog hourly prices by region and instance type
+type PriceCatalog map[string]map[string]*InstancePrice
+
+// PricingProvider return hourly prices of instance types from the catalog,
+// on-demand prices are refreshed from the price list api when enabled
+type PricingProvider struct {
+	sync.RWMutex
+	saveLock    sync.Mutex
+	config      *Configuration
+	options     *PricingOptions
+	catalog     PriceCatalog
+	refreshedAt map[string]time.Time
+	refreshing  map[string]bool
+}
+
+// GetRefreshInterval return the duration a price is used before asking the price list api
+func (options *PricingOptions) GetRefreshInterval() time.Duration {
+	if options.RefreshInterval <= 0 {
+		return defaultPriceRefreshInterval * time.Second
+	}
+
+	return options.RefreshInterval * time.Second
+}
+
+// LoadPriceCatalog read the price catalog file
+func LoadPriceCatalog(fileName string) (PriceCatalog, error) {
+	var catalog PriceCatalog
+
+	content, err := os.ReadFile(fileName)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err = json.Unmarshal(content, &catalog); err != nil {
+		return nil, err
+	}
+
+	return catalog, nil
+}
+
+// Save write the price catalog file
+func (catalog PriceCatalog) Save(fileName string) error {
+	content, err := json.MarshalIndent(catalog, "", "  ")
+
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(fileName, content, 0644)
+}
+
+// NewPricingProvider create a pricing provider for the region of the configuration, the catalog file is optional
+func NewPricingProvider(config *Configuration, options *PricingOptions) (*PricingProvider, error) {
+	catalog := make(PriceCatalog)
+
+	if len(options.CatalogFile) > 0 {
+		if loaded, err := LoadPriceCatalog(options.CatalogFile); err == nil {
+			catalog = loaded
+		} else if !os.IsNotExist(err) || !options.RefreshFromAPI {
+			return nil, fmt.Errorf(constantes.ErrUnableToLoadPriceCatalog, options.CatalogFile, err)
+		}
+	}
+
+	return &PricingProvider{
+		config:      config,
+		options:     options,
+		catalog:     catalog,
+		refreshedAt: make(map[string]time.Time),
+		refreshing:  make(map[string]bool),
+	}, nil
+}
+
+func (p *PricingProvider) getInstancePrice(instanceType string) *InstancePrice {
+	if prices, found := p.catalog[p.config.Region]; found {
+		return prices[instanceType]
+	}
+
+	return nil
+}
+
+func (p *PricingProvider) setOnDemandPrice(instanceType string, price float64) {
+	prices, found := p.catalog[p.config.Region]
+
+	if !found {
+		prices = make(map[string]*InstancePrice)
+		p.catalog[p.config.Region] = prices
+	}
+
+	if instancePrice, found := prices[instanceType]; found {
+		instancePrice.OnDemand = price
+	} else {
+		prices[instanceType] = &InstancePrice{
+			OnDemand: price,
+		}
+	}
+}
+
+// parseOnDemandPrice extract the hourly USD price from a price list product
+func parseOnDemandPrice(product aws.JSONValue) (float64, bool) {
+	terms, _ := product["terms"].(map[string]interface{})
+	onDemand, _ := terms["OnDemand"].(map[string]interface{})
+
+	for _, term := range onDemand {
+		term, _ := term.(map[string]interface{})
+		dimensions, _ := term["priceDimensions"].(map[string]interface{})
+
+		for _, dimension := range dimensions {
+			dimension, _ := dimension.(map[string]interface{})
+			pricePerUnit, _ := dimension["pricePerUnit"].(map[string]interface{})
+
+			if usd, ok := pricePerUnit["USD"].(string); ok {
+				if price, err := strconv.ParseFloat(usd, 64); err == nil && price > 0 {
+					return price, true
+				}
+			}
+		}
+	}
+
+	return 0, false
+}
+
+func (p *PricingProvider) describeOnDemandPrice(instanceType string) (float64, error) {
+	client, err := createPricingClient(p.config)
+
+	if err != nil {
+		return 0, err
+	}
+
+	ctx := context.NewContext(p.config.Timeout)
+	defer ctx.Cancel()
+
+	filter := func(field, value string) *pricing.Filter {
+		return &pricing.Filter{
+			Type:  aws.String(pricing.FilterTypeTermMatch),
+			Field: aws.String(field),
+			Value: aws.String(value),
+		}
+	}
+
+	output, err := client.GetProductsWithContext(ctx, &pricing.GetProductsInput{
+		ServiceCode: aws.String("AmazonEC2"),
+		Filters: []*pricing.Filter{
+			filter("regionCode", p.config.Region),
+			filter("instanceType", instanceType),
+			filter("operatingSystem", "Linux"),
+			filter("tenancy", "Shared"),
+			filter("preInstalledSw", "NA"),
+			filter("capacitystatus", "Used"),
+		},
+	})
+
+	if err != nil {
+		return 0, err
+	}
+
+	for _, product := range output.PriceList {
+		if price, found := parseOnDemandPrice(product); found {
+			return price, nil
+		}
+	}
+
+	return 0, fmt.Errorf(constantes.ErrPriceNotFound, instanceType, p.config.Region)
+}
+
+// Refresh ask the price list api for the on-demand price of instance types and save the catalog file.
+// The prices are locked only while updated, not during the api calls
+func (p *PricingProvider) Refresh(instanceTypes ...string) error {
+	for _, instanceType := range instanceTypes {
+		price, err := p.describeOnDemandPrice(instanceType)
+
+		if err != nil {
+			return fmt.Errorf(constantes.ErrUnableToRefreshPrice, instanceType, err)
+		}
+
+		p.Lock()
+		p.setOnDemandPrice(instanceType, price)
+		p.refreshedAt[instanceType] = time.Now()
+		p.Unlock()
+	}
+
+	p.save()
+
+	return nil
+}
+
+// save write the catalog file, the prices are only locked while marshalled
+func (p *PricingProvider) save() {
+	if len(p.options.CatalogFile) == 0 {
+		return
+	}
+
+	p.saveLock.Lock()
+	defer p.saveLock.Unlock()
+
+	p.RLock()
+	content, err := json.MarshalIndent(p.catalog, "", "  ")
+	p.RUnlock()
+
+	if err == nil {
+		err = os.WriteFile(p.options.CatalogFile, content, 0644)
+	}
+
+	if err != nil {
+		glog.Warnf(constantes.WarnUnableToSavePriceCatalog, p.options.CatalogFile, err)
+	}
+}
+
+// expired return true if the price of the instance type must be asked to the price list api
+func (p *PricingProvider) expired(instanceType string) bool {
+	refreshedAt, found := p.refreshedAt[instanceType]
+
+	return !found || time.Since(refreshedAt) >= p.options.GetRefreshInterval()
+}
+
+// refreshInBackground ask the price list api for the expired price of the instance type without blocking the caller,
+// a single refresh by instance type runs at a time
+func (p *PricingProvider) refreshInBackground(instanceType string) {
+	p.Lock()
+	defer p.Unlock()
+
+	if p.refreshing[instanceType] || !p.expired(instanceType) {
+		return
+	}
+
+	p.refreshing[instanceType] = true
+
+	go func() {
+		err := p.Refresh(instanceType)
+
+		p.Lock()
+		defer p.Unlock()
+
+		if err != nil {
+			glog.Warnf(constantes.WarnPriceRefreshFailed, instanceType, err)
+
+			// Don't retry before the next interval
+			p.refreshedAt[instanceType] = time.Now()
+		}
+
+		delete(p.refreshing, instanceType)
+	}()
+}
+
+// HourlyPrice return the hourly price of the instance type from memory. Expired prices are refreshed in background,
+// the catalog price is used until refreshed or if the api is unreachable
+func (p *PricingProvider) HourlyPrice(instanceType string, spot bool) (float64, bool) {
+	if p.options.RefreshFromAPI {
+		p.RLock()
+		expired := p.expired(instanceType)
+		p.RUnlock()
+
+		if expired {
+			p.refreshInBackground(instanceType)
+		}
+	}
+
+	p.RLock()
+	defer p.RUnlock()
+
+	if price := p.getInstancePrice(instanceType); price != nil {
+		if spot && price.Spot > 0 {
+			return price.Spot, true
+		}
+
+		if price.OnDemand > 0 {
+			return price.OnDemand, true
+		}
+	}
+
+	return 0, false
+}
+
+// Price return the price of the instance type running between start and end time
+func (p *PricingProvider) Price(instanceType string, spot bool, startTime, endTime time.Time) (float64, bool) {
+	if hourly, found := p.HourlyPrice(instanceType, spot); found {
+		return hourly * endTime.Sub(startTime).Hours(), true
+	}
+
+	return 0, false
+}
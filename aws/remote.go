@@ -0,0 +1,293 @@
+package aws
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Fred78290/kubernetes-aws-autoscaler/constantes"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	glog "github.com/sirupsen/logrus"
+)
+
+const (
+	// RemoteTransportSSH run the bootstrap commands over ssh, the default
+	RemoteTransportSSH = "ssh"
+
+	// RemoteTransportSSM run the bootstrap commands with SSM Run Command
+	RemoteTransportSSM = "ssm"
+
+	// ssmRunShellScript is the SSM document running shell commands as root
+	ssmRunShellScript = "AWS-RunShellScript"
+
+	// defaultCommandTimeout is the time in seconds to wait for the completion of a command
+	defaultCommandTimeout = 600
+
+	// defaultTransferPrefix prefix the S3 keys or the SSM parameters names of staged archives
+	defaultTransferPrefix = "kubernetes-aws-autoscaler"
+
+	// standardParameterMaxSize max size of a standard SSM parameter value, larger values use the advanced tier
+	standardParameterMaxSize = 4096
+
+	// advancedParameterMaxSize max size of an advanced SSM parameter value
+	advancedParameterMaxSize = 8192
+
+	// defaultCommandPollInterval is the time in milliseconds between two checks of a command completion
+	defaultCommandPollInterval = 2000
+)
+
+// RemoteExecOptions declare how the bootstrap commands are executed on nodes, over ssh or with SSM Run Command.
+// With SSM, files are transferred through the bucket if declared, else through SSM parameters.
+// Timeout is in seconds, poll interval in milliseconds
+type RemoteExecOptions struct {
+	Transport    string        `json:"transport,omitempty"`
+	Bucket       string        `json:"bucket,omitempty"`
+	Prefix       string        `json:"prefix,omitempty"`
+	Timeout      int           `json:"timeout,omitempty"`
+	PollInterval time.Duration `json:"pollInterval,omitempty"`
+}
+
+// UseSSMTransport return true if the bootstrap commands are executed with SSM Run Command
+func (conf *Configuration) UseSSMTransport() bool {
+	return conf.RemoteExec != nil && conf.RemoteExec.Transport == RemoteTransportSSM
+}
+
+// GetCommandTimeout return the maximum time to wait for the completion of a command
+func (conf *Configuration) GetCommandTimeout() time.Duration {
+	if conf.RemoteExec == nil || conf.RemoteExec.Timeout <= 0 {
+		return defaultCommandTimeout * time.Second
+	}
+
+	return time.Duration(conf.RemoteExec.Timeout) * time.Second
+}
+
+// GetCommandPollInterval return the interval between two checks of a command completion
+func (conf *Configuration) GetCommandPollInterval() time.Duration {
+	if conf.RemoteExec == nil || conf.RemoteExec.PollInterval <= 0 {
+		return defaultCommandPollInterval * time.Millisecond
+	}
+
+	return conf.RemoteExec.PollInterval * time.Millisecond
+}
+
+func (conf *Configuration) getTransferPrefix() string {
+	if conf.RemoteExec == nil || len(conf.RemoteExec.Prefix) == 0 {
+		return defaultTransferPrefix
+	}
+
+	return strings.Trim(conf.RemoteExec.Prefix, "/")
+}
+
+func (conf *Configuration) getTransferBucket() string {
+	if conf.RemoteExec == nil {
+		return ""
+	}
+
+	return conf.RemoteExec.Bucket
+}
+
+// IsManaged return true if the SSM agent of the instance is online
+func (instance *Ec2Instance) IsManaged() (bool, error) {
+	client, err := createSSMClient(instance.config)
+
+	if err != nil {
+		return false, err
+	}
+
+	ctx := instance.NewContext()
+	defer ctx.Cancel()
+
+	input := &ssm.DescribeInstanceInformationInput{
+		Filters: []*ssm.InstanceInformationStringFilter{
+			{
+				Key:    aws.String("InstanceIds"),
+				Values: []*string{instance.InstanceID},
+			},
+		},
+	}
+
+	output, err := client.DescribeInstanceInformationWithContext(ctx, input)
+
+	if err != nil {
+		return false, fmt.Errorf(constantes.ErrUnableToDescribeManagedInstance, instance.InstanceName, err)
+	}
+
+	for _, info := range output.InstanceInformationList {
+		if aws.StringValue(info.PingStatus) == ssm.PingStatusOnline {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// RunCommand run the shell commands as root on the instance with SSM Run Command and return the output.
+// Like sudo over ssh, the commands stop at the first failure
+func (instance *Ec2Instance) RunCommand(command ...string) (string, error) {
+	client, err := createSSMClient(instance.config)
+
+	if err != nil {
+		return "", err
+	}
+
+	timeout := instance.config.GetCommandTimeout()
+
+	ctx := instance.NewContext()
+	defer ctx.Cancel()
+
+	input := &ssm.SendCommandInput{
+		DocumentName: aws.String(ssmRunShellScript),
+		InstanceIds:  []*string{instance.InstanceID},
+		Parameters: map[string][]*string{
+			"commands":         aws.StringSlice(append([]string{"set -e"}, command...)),
+			"executionTimeout": aws.StringSlice([]string{strconv.Itoa(int(timeout / time.Second))}),
+		},
+	}
+
+	glog.Debugf("RunCommand: instance %s, commands:%s", instance.InstanceName, strings.Join(command, ","))
+
+	output, err := client.SendCommandWithContext(ctx, input)
+
+	if err != nil {
+		return "", fmt.Errorf(constantes.ErrUnableToSendCommand, instance.InstanceName, err)
+	}
+
+	var invocation *ssm.GetCommandInvocationOutput
+
+	if err = instance.pollImmediate(instance.config.GetCommandPollInterval(), timeout, func() (bool, error) {
+		ctx := instance.NewContext()
+		defer ctx.Cancel()
+
+		if invocation, err = client.GetCommandInvocationWithContext(ctx, &ssm.GetCommandInvocationInput{
+			CommandId:  output.Command.CommandId,
+			InstanceId: instance.InstanceID,
+		}); err != nil {
+			// The invocation is not visible immediately after the command is sent
+			if aerr, ok := err.(awserr.Error); ok && aerr.Code() == ssm.ErrCodeInvocationDoesNotExist {
+				return false, nil
+			}
+
+			return false, err
+		}
+
+		switch aws.StringValue(invocation.Status) {
+		case ssm.CommandInvocationStatusPending, ssm.CommandInvocationStatusInProgress, ssm.CommandInvocationStatusDelayed:
+			return false, nil
+		}
+
+		return true, nil
+	}); err != nil {
+		return "", fmt.Errorf(constantes.ErrUnableToSendCommand, instance.InstanceName, err)
+	}
+
+	out := strings.TrimSpace(aws.StringValue(invocation.StandardOutputContent))
+
+	if status := aws.StringValue(invocation.Status); status != ssm.CommandInvocationStatusSuccess {
+		return out, fmt.Errorf(constantes.ErrRemoteCommandFailed, instance.InstanceName, status, strings.TrimSpace(out+"\n"+aws.StringValue(invocation.StandardErrorContent)))
+	}
+
+	return out, nil
+}
+
+func (instance *Ec2Instance) transferKey(name string) string {
+	return fmt.Sprintf("%s/%s/%s", instance.config.getTransferPrefix(), instance.getInstanceID(), name)
+}
+
+// StageArchive store the archive for the instance in the bucket or in a SSM parameter,
+// return the shell command downloading it on the instance into path. The instance profile must allow the download
+func (instance *Ec2Instance) StageArchive(name string, archive []byte, path string) (string, error) {
+	ctx := instance.NewContext()
+	defer ctx.Cancel()
+
+	key := instance.transferKey(name)
+
+	if bucket := instance.config.getTransferBucket(); len(bucket) > 0 {
+		client, err := createS3Client(instance.config)
+
+		if err != nil {
+			return "", err
+		}
+
+		if _, err = client.PutObjectWithContext(ctx, &s3.PutObjectInput{
+			Bucket:               aws.String(bucket),
+			Key:                  aws.String(key),
+			Body:                 bytes.NewReader(archive),
+			ServerSideEncryption: aws.String(s3.ServerSideEncryptionAes256),
+		}); err != nil {
+			return "", fmt.Errorf(constantes.ErrUnableToStageArchive, name, instance.InstanceName, err)
+		}
+
+		return fmt.Sprintf("aws s3 cp --region %s s3://%s/%s %s", instance.config.Region, bucket, key, path), nil
+	}
+
+	value := base64.StdEncoding.EncodeToString(archive)
+
+	if len(value) > advancedParameterMaxSize {
+		return "", fmt.Errorf(constantes.ErrArchiveTooLarge, name, len(archive))
+	}
+
+	client, err := createSSMClient(instance.config)
+
+	if err != nil {
+		return "", err
+	}
+
+	tier := ssm.ParameterTierStandard
+
+	if len(value) > standardParameterMaxSize {
+		tier = ssm.ParameterTierAdvanced
+	}
+
+	if _, err = client.PutParameterWithContext(ctx, &ssm.PutParameterInput{
+		Name:      aws.String("/" + key),
+		Type:      aws.String(ssm.ParameterTypeSecureString),
+		Tier:      aws.String(tier),
+		Value:     aws.String(value),
+		Overwrite: aws.Bool(true),
+	}); err != nil {
+		return "", fmt.Errorf(constantes.ErrUnableToStageArchive, name, instance.InstanceName, err)
+	}
+
+	return fmt.Sprintf("aws ssm get-parameter --region %s --name /%s --with-decryption --query Parameter.Value --output text | base64 -d > %s", instance.config.Region, key, path), nil
+}
+
+// UnstageArchive remove the archive staged for the instance
+func (instance *Ec2Instance) UnstageArchive(name string) error {
+	ctx := instance.NewContext()
+	defer ctx.Cancel()
+
+	key := instance.transferKey(name)
+
+	if bucket := instance.config.getTransferBucket(); len(bucket) > 0 {
+		client, err := createS3Client(instance.config)
+
+		if err != nil {
+			return err
+		}
+
+		_, err = client.DeleteObjectWithContext(ctx, &s3.DeleteObjectInput{
+			Bucket: aws.String(bucket),
+			Key:    aws.String(key),
+		})
+
+		return err
+	}
+
+	client, err := createSSMClient(instance.config)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = client.DeleteParameterWithContext(ctx, &ssm.DeleteParameterInput{
+		Name: aws.String("/" + key),
+	})
+
+	return err
+}
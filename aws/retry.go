@@ -0,0 +1,107 @@
+package aws
+
+import (
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/client"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/ec2"
+)
+
+const (
+	defaultMaxRetries = 5
+	defaultMinDelay   = 100
+	defaultMaxDelay   = 20000
+)
+
+// idempotentOperations operations safe to replay after a server or network error
+var idempotentOperations = map[string]bool{
+	"StartInstances":           true,
+	"StopInstances":            true,
+	"TerminateInstances":       true,
+	"ChangeResourceRecordSets": true,
+}
+
+// RetryOptions declare the retry policy and the circuit breaker of aws calls, delays are in milliseconds, cool-down in seconds
+type RetryOptions struct {
+	MaxRetries       int           `json:"maxRetries,omitempty"`
+	MinDelay         time.Duration `json:"minDelay,omitempty"`
+	MaxDelay         time.Duration `json:"maxDelay,omitempty"`
+	BreakerThreshold int           `json:"breakerThreshold,omitempty"`
+	BreakerCoolDown  time.Duration `json:"breakerCoolDown,omitempty"`
+}
+
+// GetMaxRetries return the max number of retries
+func (options *RetryOptions) GetMaxRetries() int {
+	if options == nil || options.MaxRetries <= 0 {
+		return defaultMaxRetries
+	}
+
+	return options.MaxRetries
+}
+
+// GetMinDelay return the first backoff delay
+func (options *RetryOptions) GetMinDelay() time.Duration {
+	if options == nil || options.MinDelay <= 0 {
+		return defaultMinDelay * time.Millisecond
+	}
+
+	return options.MinDelay * time.Millisecond
+}
+
+// GetMaxDelay return the max backoff delay
+func (options *RetryOptions) GetMaxDelay() time.Duration {
+	if options == nil || options.MaxDelay <= 0 {
+		return defaultMaxDelay * time.Millisecond
+	}
+
+	return options.MaxDelay * time.Millisecond
+}
+
+// retryer retry throttled calls and idempotent calls on server or network errors,
+// with exponential backoff and jitter from the sdk default retryer
+type retryer struct {
+	client.DefaultRetryer
+}
+
+func newRetryer(options *RetryOptions) request.Retryer {
+	return retryer{
+		DefaultRetryer: client.DefaultRetryer{
+			NumMaxRetries:    options.GetMaxRetries(),
+			MinRetryDelay:    options.GetMinDelay(),
+			MinThrottleDelay: options.GetMinDelay(),
+			MaxRetryDelay:    options.GetMaxDelay(),
+			MaxThrottleDelay: options.GetMaxDelay(),
+		},
+	}
+}
+
+// isIdempotentRequest return true if the request can be replayed without side effect
+func isIdempotentRequest(req *request.Request) bool {
+	name := req.Operation.Name
+
+	if strings.HasPrefix(name, "Describe") || strings.HasPrefix(name, "List") || strings.HasPrefix(name, "Get") {
+		return true
+	}
+
+	// RunInstances with a client token is idempotent
+	if input, ok := req.Params.(*ec2.RunInstancesInput); ok {
+		return input.ClientToken != nil
+	}
+
+	return idempotentOperations[name]
+}
+
+// ShouldRetry always retry throttled requests, they were not executed
+func (r retryer) ShouldRetry(req *request.Request) bool {
+	if req.IsErrorThrottle() {
+		return true
+	}
+
+	if !isIdempotentRequest(req) {
+		return false
+	}
+
+	return r.DefaultRetryer.ShouldRetry(req)
+}
@@ -0,0 +1,245 @@
+package aws
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	ec2ThrottlingResponse        = `<Response><Errors><Error><Code>RequestLimitExceeded</Code><Message>Request limit exceeded.</Message></Error></Errors><RequestID>1</RequestID></Response>`
+	ec2InternalErrorResponse     = `<Response><Errors><Error><Code>InternalError</Code><Message>An internal error has occurred.</Message></Error></Errors><RequestID>1</RequestID></Response>`
+	ec2DescribeInstancesResponse = `<DescribeInstancesResponse xmlns="http://ec2.amazonaws.com/doc/2016-11-15/"><requestId>1</requestId><reservationSet/></DescribeInstancesResponse>`
+	ec2CreateTagsResponse        = `<CreateTagsResponse xmlns="http://ec2.amazonaws.com/doc/2016-11-15/"><requestId>1</requestId><return>true</return></CreateTagsResponse>`
+)
+
+// ec2TestServer answer with the failure until the number of failures is reached
+type ec2TestServer struct {
+	*httptest.Server
+	calls    int32
+	failures int32
+	status   int
+	failure  string
+}
+
+func newEC2TestServer(failures int, status int, failure string) *ec2TestServer {
+	server := &ec2TestServer{
+		failures: int32(failures),
+		status:   status,
+		failure:  failure,
+	}
+
+	server.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		call := atomic.AddInt32(&server.calls, 1)
+
+		_ = r.ParseForm()
+
+		if server.failures < 0 || call <= server.failures {
+			w.WriteHeader(server.status)
+			fmt.Fprint(w, server.failure)
+		} else if r.Form.Get("Action") == "CreateTags" {
+			fmt.Fprint(w, ec2CreateTagsResponse)
+		} else {
+			fmt.Fprint(w, ec2DescribeInstancesResponse)
+		}
+	}))
+
+	return server
+}
+
+func newTestEC2Client(t *testing.T, server *ec2TestServer, conf *Configuration) *ec2.EC2 {
+	sess, err := session.NewSession(&aws.Config{
+		Region:      aws.String(conf.Region),
+		Endpoint:    aws.String(server.URL),
+		Credentials: credentials.NewStaticCredentials("access", "secret", ""),
+	})
+
+	if err != nil {
+		t.Fatalf("unable to create session: %v", err)
+	}
+
+	return newEC2Client(sess, conf)
+}
+
+func newRetryConfiguration(region string) *Configuration {
+	return &Configuration{
+		Region: region,
+		Retry: &RetryOptions{
+			MaxRetries:       3,
+			MinDelay:         1,
+			MaxDelay:         5,
+			BreakerThreshold: 2,
+		},
+	}
+}
+
+func Test_retryThrottledRequest(t *testing.T) {
+	server := newEC2TestServer(2, http.StatusServiceUnavailable, ec2ThrottlingResponse)
+	defer server.Close()
+
+	conf := newRetryConfiguration("test-retry-throttle")
+	client := newTestEC2Client(t, server, conf)
+
+	_, err := client.DescribeInstances(&ec2.DescribeInstancesInput{})
+
+	assert.NoError(t, err)
+	assert.Equal(t, int32(3), server.calls)
+	assert.Equal(t, CircuitBreakerClosed, conf.GetCircuitBreaker().State())
+}
+
+func Test_noRetryNonIdempotentRequest(t *testing.T) {
+	server := newEC2TestServer(1, http.StatusInternalServerError, ec2InternalErrorResponse)
+	defer server.Close()
+
+	conf := newRetryConfiguration("test-retry-idempotent")
+	client := newTestEC2Client(t, server, conf)
+
+	_, err := client.CreateTags(&ec2.CreateTagsInput{
+		Resources: []*string{aws.String("i-1234")},
+		Tags:      []*ec2.Tag{{Key: aws.String("key"), Value: aws.String("value")}},
+	})
+
+	assert.Error(t, err)
+	assert.Equal(t, int32(1), server.calls, "non idempotent call must not be replayed")
+
+	// Describe is idempotent
+	atomic.StoreInt32(&server.calls, 0)
+
+	_, err = client.DescribeInstances(&ec2.DescribeInstancesInput{})
+
+	assert.NoError(t, err)
+	assert.Equal(t, int32(2), server.calls)
+}
+
+func Test_circuitBreaker(t *testing.T) {
+	server := newEC2TestServer(-1, http.StatusServiceUnavailable, ec2ThrottlingResponse)
+	defer server.Close()
+
+	conf := newRetryConfiguration("test-retry-breaker")
+	client := newTestEC2Client(t, server, conf)
+	breaker := conf.GetCircuitBreaker()
+
+	for i := 0; i < 2; i++ {
+		_, err := client.DescribeInstances(&ec2.DescribeInstancesInput{})
+
+		assert.Error(t, err)
+	}
+
+	assert.Equal(t, CircuitBreakerOpen, breaker.State())
+	assert.True(t, breaker.IsOpen())
+	assert.Error(t, breaker.Check())
+
+	// Fail fast without calling the api
+	calls := atomic.LoadInt32(&server.calls)
+
+	_, err := client.DescribeInstances(&ec2.DescribeInstancesInput{})
+
+	if aerr, ok := err.(awserr.Error); assert.True(t, ok) {
+		assert.Equal(t, ErrCodeCircuitBreakerOpen, aerr.Code())
+	}
+
+	assert.Equal(t, calls, atomic.LoadInt32(&server.calls))
+
+	// After cool-down, a successful probe close the breaker
+	breaker.Lock()
+	breaker.openedAt = time.Now().Add(-breaker.coolDown)
+	breaker.Unlock()
+
+	atomic.StoreInt32(&server.failures, 0)
+
+	_, err = client.DescribeInstances(&ec2.DescribeInstancesInput{})
+
+	assert.NoError(t, err)
+	assert.Equal(t, CircuitBreakerClosed, breaker.State())
+	assert.NoError(t, breaker.Check())
+}
+
+func Test_circuitBreakerSingleProbe(t *testing.T) {
+	conf := newRetryConfiguration("test-retry-probe")
+	breaker := conf.GetCircuitBreaker()
+
+	breaker.Failure()
+	breaker.Failure()
+
+	assert.Equal(t, CircuitBreakerOpen, breaker.State())
+
+	breaker.Lock()
+	breaker.openedAt = time.Now().Add(-breaker.coolDown)
+	breaker.Unlock()
+
+	// Only one probe while half-open
+	assert.NoError(t, breaker.Allow())
+	assert.Equal(t, CircuitBreakerHalfOpen, breaker.State())
+	assert.Error(t, breaker.Allow())
+	assert.Error(t, breaker.Check())
+
+	// A failed probe reopen the breaker
+	breaker.Failure()
+
+	assert.Equal(t, CircuitBreakerOpen, breaker.State())
+	assert.Error(t, breaker.Allow())
+
+	breaker.Lock()
+	breaker.openedAt = time.Now().Add(-breaker.coolDown)
+	breaker.Unlock()
+
+	assert.NoError(t, breaker.Allow())
+	assert.Error(t, breaker.Allow())
+
+	breaker.Success()
+
+	assert.Equal(t, CircuitBreakerClosed, breaker.State())
+	assert.NoError(t, breaker.Allow())
+	assert.NoError(t, breaker.Allow())
+}
+
+func Test_circuitBreakerSharedByClients(t *testing.T) {
+	server := newEC2TestServer(-1, http.StatusServiceUnavailable, ec2ThrottlingResponse)
+	defer server.Close()
+
+	conf := newRetryConfiguration("test-retry-clients")
+	conf.AccessKey = "access"
+	conf.SecretKey = "secret"
+	conf.InterruptionQueue = &InterruptionQueueOptions{
+		URL:      server.URL + "/queue",
+		Endpoint: server.URL,
+	}
+
+	client, err := NewSessionClientProvider().GetSQSClient(conf)
+
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	for i := 0; i < 2; i++ {
+		_, err = client.ReceiveMessage(&sqs.ReceiveMessageInput{
+			QueueUrl: aws.String(conf.InterruptionQueue.URL),
+		})
+
+		assert.Error(t, err)
+	}
+
+	assert.Equal(t, CircuitBreakerOpen, conf.GetCircuitBreaker().State())
+
+	// Ec2 calls of the region fail fast
+	calls := atomic.LoadInt32(&server.calls)
+
+	_, err = newTestEC2Client(t, server, conf).DescribeInstances(&ec2.DescribeInstancesInput{})
+
+	if aerr, ok := err.(awserr.Error); assert.True(t, ok) {
+		assert.Equal(t, ErrCodeCircuitBreakerOpen, aerr.Code())
+	}
+
+	assert.Equal(t, calls, atomic.LoadInt32(&server.calls))
+}
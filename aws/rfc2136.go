@@ -0,0 +1,133 @@
+package aws
+
+import (
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/Fred78290/kubernetes-aws-autoscaler/constantes"
+	"github.com/miekg/dns"
+	glog "github.com/sirupsen/logrus"
+)
+
+const (
+	rfc2136DefaultTTL       = 60
+	rfc2136DefaultAlgorithm = dns.HmacSHA256
+	rfc2136DefaultPort      = "53"
+	rfc2136TSIGFudge        = 300
+	rfc2136Timeout          = 10 * time.Second
+)
+
+// rfc2136Provider register nodes with RFC2136 dynamic updates, like BIND
+type rfc2136Provider struct {
+	server    string
+	zone      string
+	keyName   string
+	secret    string
+	algorithm string
+	transport string
+	ttl       uint32
+}
+
+func newRFC2136Provider(options *RFC2136, privateZoneName string) *rfc2136Provider {
+	provider := &rfc2136Provider{
+		server:    options.Server,
+		zone:      dns.Fqdn(options.Zone),
+		algorithm: dns.Fqdn(options.TSIGAlgorithm),
+		transport: options.Transport,
+		ttl:       uint32(options.TTL),
+	}
+
+	if len(options.Zone) == 0 {
+		provider.zone = dns.Fqdn(privateZoneName)
+	}
+
+	if _, _, err := net.SplitHostPort(provider.server); err != nil {
+		provider.server = net.JoinHostPort(provider.server, rfc2136DefaultPort)
+	}
+
+	if len(options.TSIGKeyName) > 0 {
+		provider.keyName = dns.Fqdn(options.TSIGKeyName)
+		provider.secret = options.TSIGSecret
+	}
+
+	if len(options.TSIGAlgorithm) == 0 {
+		provider.algorithm = rfc2136DefaultAlgorithm
+	}
+
+	if provider.ttl == 0 {
+		provider.ttl = rfc2136DefaultTTL
+	}
+
+	return provider
+}
+
+// exchange send the update message signed with TSIG if a key is declared
+func (provider *rfc2136Provider) exchange(msg *dns.Msg) error {
+	client := &dns.Client{
+		Net:     provider.transport,
+		Timeout: rfc2136Timeout,
+	}
+
+	if len(provider.keyName) > 0 {
+		client.TsigSecret = map[string]string{
+			provider.keyName: provider.secret,
+		}
+
+		msg.SetTsig(provider.keyName, provider.algorithm, rfc2136TSIGFudge, time.Now().Unix())
+	}
+
+	if reply, _, err := client.Exchange(msg, provider.server); err != nil {
+		return fmt.Errorf(constantes.ErrRFC2136UpdateFailed, provider.server, err)
+	} else if reply != nil && reply.Rcode != dns.RcodeSuccess {
+		return fmt.Errorf(constantes.ErrRFC2136UpdateFailed, provider.server, dns.RcodeToString[reply.Rcode])
+	}
+
+	return nil
+}
+
+// Register replace the A or AAAA record of the node, updates are synchronous so wait is ignored
+func (provider *rfc2136Provider) Register(name, address string, wait bool) error {
+	rr, err := dns.NewRR(fmt.Sprintf("%s %d IN %s %s", dns.Fqdn(name), provider.ttl, dnsRecordType(address), address))
+
+	if err != nil {
+		return err
+	}
+
+	glog.Debugf("RFC2136: register %s with address %s in zone %s", name, address, provider.zone)
+
+	msg := new(dns.Msg)
+	msg.SetUpdate(provider.zone)
+	msg.RemoveRRset([]dns.RR{rr})
+	msg.Insert([]dns.RR{rr})
+
+	return provider.exchange(msg)
+}
+
+// Unregister delete the A and AAAA records of the node
+func (provider *rfc2136Provider) Unregister(name string, wait bool) error {
+	rrset := []dns.RR{
+		&dns.A{
+			Hdr: dns.RR_Header{
+				Name:   dns.Fqdn(name),
+				Rrtype: dns.TypeA,
+				Class:  dns.ClassINET,
+			},
+		},
+		&dns.AAAA{
+			Hdr: dns.RR_Header{
+				Name:   dns.Fqdn(name),
+				Rrtype: dns.TypeAAAA,
+				Class:  dns.ClassINET,
+			},
+		},
+	}
+
+	glog.Debugf("RFC2136: unregister %s in zone %s", name, provider.zone)
+
+	msg := new(dns.Msg)
+	msg.SetUpdate(provider.zone)
+	msg.RemoveRRset(rrset)
+
+	return provider.exchange(msg)
+}
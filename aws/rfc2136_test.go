@@ -0,0 +1,156 @@
+package aws_test
+
+import (
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Fred78290/kubernetes-aws-autoscaler/aws"
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	testTSIGKeyName = "autoscaler."
+	testTSIGSecret  = "c2VjcmV0LWtleS1mb3ItdGVzdGluZw=="
+)
+
+// localDNSServer is a minimal DNS server applying RFC2136 updates signed with TSIG
+type localDNSServer struct {
+	sync.Mutex
+	server  *dns.Server
+	records map[string]string
+	address string
+}
+
+func (s *localDNSServer) ServeDNS(w dns.ResponseWriter, r *dns.Msg) {
+	reply := new(dns.Msg)
+	reply.SetReply(r)
+
+	if r.IsTsig() == nil || w.TsigStatus() != nil {
+		reply.Rcode = dns.RcodeNotAuth
+	} else {
+		s.Lock()
+
+		for _, rr := range r.Ns {
+			header := rr.Header()
+
+			key := header.Name + "/" + dns.TypeToString[header.Rrtype]
+
+			if header.Class == dns.ClassANY {
+				delete(s.records, key)
+			} else if a, ok := rr.(*dns.A); ok {
+				s.records[key] = a.A.String()
+			} else if aaaa, ok := rr.(*dns.AAAA); ok {
+				s.records[key] = aaaa.AAAA.String()
+			}
+		}
+
+		s.Unlock()
+
+		reply.SetTsig(testTSIGKeyName, dns.HmacSHA256, 300, time.Now().Unix())
+	}
+
+	_ = w.WriteMsg(reply)
+}
+
+func (s *localDNSServer) get(name string) (string, bool) {
+	s.Lock()
+	defer s.Unlock()
+
+	address, found := s.records[name]
+
+	return address, found
+}
+
+func startLocalDNSServer(t *testing.T) *localDNSServer {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+
+	if err != nil {
+		t.Skipf("unable to listen udp: %v", err)
+	}
+
+	started := make(chan struct{})
+	local := &localDNSServer{
+		records: make(map[string]string),
+		address: conn.LocalAddr().String(),
+	}
+
+	local.server = &dns.Server{
+		PacketConn: conn,
+		Handler:    local,
+		TsigSecret: map[string]string{
+			testTSIGKeyName: testTSIGSecret,
+		},
+		// Default accept func reject UPDATE opcode
+		MsgAcceptFunc: func(dh dns.Header) dns.MsgAcceptAction {
+			return dns.MsgAccept
+		},
+		NotifyStartedFunc: func() {
+			close(started)
+		},
+	}
+
+	go func() {
+		_ = local.server.ActivateAndServe()
+	}()
+
+	<-started
+
+	return local
+}
+
+func Test_rfc2136Provider(t *testing.T) {
+	local := startLocalDNSServer(t)
+	defer local.server.Shutdown()
+
+	config := aws.Configuration{
+		Network: aws.Network{
+			PrivateZoneName: "acme.priv",
+			RFC2136: &aws.RFC2136{
+				Server:        local.address,
+				TSIGKeyName:   testTSIGKeyName,
+				TSIGSecret:    testTSIGSecret,
+				TSIGAlgorithm: dns.HmacSHA256,
+			},
+		},
+	}
+
+	provider := config.GetDNSProvider()
+
+	if assert.NotNil(t, provider) {
+		if assert.NoError(t, provider.Register("node-01.acme.priv", "10.0.0.1", true)) {
+			address, found := local.get("node-01.acme.priv./A")
+
+			assert.True(t, found)
+			assert.Equal(t, "10.0.0.1", address)
+		}
+
+		if assert.NoError(t, provider.Register("node-01.acme.priv", "10.0.0.2", true)) {
+			address, _ := local.get("node-01.acme.priv./A")
+
+			assert.Equal(t, "10.0.0.2", address)
+		}
+
+		if assert.NoError(t, provider.Register("node-01.acme.priv", "2001:db8::1", true)) {
+			address, found := local.get("node-01.acme.priv./AAAA")
+
+			assert.True(t, found)
+			assert.Equal(t, "2001:db8::1", address)
+		}
+
+		if assert.NoError(t, provider.Unregister("node-01.acme.priv", true)) {
+			_, found := local.get("node-01.acme.priv./A")
+			assert.False(t, found)
+
+			_, found = local.get("node-01.acme.priv./AAAA")
+			assert.False(t, found)
+		}
+	}
+
+	// Wrong key must be rejected
+	config.Network.RFC2136.TSIGSecret = "d3Jvbmctc2VjcmV0"
+
+	assert.Error(t, config.GetDNSProvider().Register("node-02.acme.priv", "10.0.0.3", true))
+}
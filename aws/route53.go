@@ -0,0 +1,125 @@
+package aws
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/route53"
+)
+
+const (
+	route53_UpsertCmd = "UPSERT"
+	route53_DeleteCmd = "DELETE"
+)
+
+// route53Provider register nodes in a Route53 hosted zone
+type route53Provider struct {
+	config *Configuration
+}
+
+func (provider *route53Provider) getRegisteredRecordSetAddress(name, recordType string) (*string, error) {
+	if svc, e := createRoute53Client(provider.config); e == nil {
+		input := &route53.ListResourceRecordSetsInput{
+			HostedZoneId:    aws.String(provider.config.Network.ZoneID),
+			MaxItems:        aws.String("1"),
+			StartRecordName: aws.String(name),
+			StartRecordType: aws.String(recordType),
+		}
+
+		if output, err := svc.ListResourceRecordSets(input); err == nil {
+			// The next record is returned when the record doesn't exist
+			if len(output.ResourceRecordSets) > 0 && len(output.ResourceRecordSets[0].ResourceRecords) > 0 &&
+				strings.TrimSuffix(aws.StringValue(output.ResourceRecordSets[0].Name), ".") == strings.TrimSuffix(name, ".") &&
+				aws.StringValue(output.ResourceRecordSets[0].Type) == recordType {
+				return output.ResourceRecordSets[0].ResourceRecords[0].Value, nil
+			} else {
+				return nil, fmt.Errorf("route53 entry:%s not found", name)
+			}
+		} else {
+			return nil, err
+		}
+	} else {
+		return nil, e
+	}
+}
+
+func (provider *route53Provider) changeResourceRecordSetsInput(cmd, name, address string, wait bool) error {
+	if svc, e := createRoute53Client(provider.config); e != nil {
+		return e
+	} else {
+		input := &route53.ChangeResourceRecordSetsInput{
+			HostedZoneId: aws.String(provider.config.Network.ZoneID),
+			ChangeBatch: &route53.ChangeBatch{
+				Comment: aws.String("Kubernetes worker node"),
+				Changes: []*route53.Change{
+					{
+						Action: aws.String(cmd),
+						ResourceRecordSet: &route53.ResourceRecordSet{
+							Name: aws.String(name),
+							TTL:  aws.Int64(60),
+							Type: aws.String(dnsRecordType(address)),
+							ResourceRecords: []*route53.ResourceRecord{
+								{
+									Value: aws.String(address),
+								},
+							},
+						},
+					},
+				},
+			},
+		}
+
+		result, err := svc.ChangeResourceRecordSets(input)
+
+		if err != nil {
+			if aerr, ok := err.(awserr.Error); ok {
+				switch aerr.Code() {
+				case route53.ErrCodeNoSuchHostedZone:
+					return fmt.Errorf("%s, %v", route53.ErrCodeNoSuchHostedZone, aerr.Error())
+				case route53.ErrCodeNoSuchHealthCheck:
+					return fmt.Errorf("%s, %v", route53.ErrCodeNoSuchHealthCheck, aerr.Error())
+				case route53.ErrCodeInvalidChangeBatch:
+					return fmt.Errorf("%s, %v", route53.ErrCodeInvalidChangeBatch, aerr.Error())
+				case route53.ErrCodeInvalidInput:
+					return fmt.Errorf("%s, %v", route53.ErrCodeInvalidInput, aerr.Error())
+				case route53.ErrCodePriorRequestNotComplete:
+					return fmt.Errorf("%s, %v", route53.ErrCodePriorRequestNotComplete, aerr.Error())
+				default:
+					return aerr
+				}
+			} else {
+				return err
+			}
+		}
+
+		if wait {
+			input := &route53.GetChangeInput{
+				Id: result.ChangeInfo.Id,
+			}
+
+			return svc.WaitUntilResourceRecordSetsChanged(input)
+		}
+
+		return nil
+	}
+}
+
+// Register upsert the A or AAAA record of the node in the hosted zone
+func (provider *route53Provider) Register(name, address string, wait bool) error {
+	return provider.changeResourceRecordSetsInput(route53_UpsertCmd, name, address, wait)
+}
+
+// Unregister delete the A and AAAA records of the node from the hosted zone
+func (provider *route53Provider) Unregister(name string, wait bool) error {
+	for _, recordType := range []string{route53.RRTypeA, route53.RRTypeAaaa} {
+		if address, err := provider.getRegisteredRecordSetAddress(name, recordType); err == nil {
+			if err = provider.changeResourceRecordSetsInput(route53_DeleteCmd, name, *address, wait); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
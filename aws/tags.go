@@ -0,0 +1,182 @@
+package aws
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/Fred78290/kubernetes-aws-autoscaler/constantes"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+)
+
+// TagTemplateData values usable in tag value templates, like {{ .NodeGroup }}-{{ .NodeIndex }}
+type TagTemplateData struct {
+	ClusterName string
+	NodeGroup   string
+	NodeName    string
+	NodeIndex   int
+	MachineType string
+}
+
+// GetClusterName return the cluster name used by tag templates, the node group name if not declared
+func (conf *Configuration) GetClusterName(nodeGroup string) string {
+	if isNullOrEmpty(conf.ClusterName) {
+		return nodeGroup
+	}
+
+	return conf.ClusterName
+}
+
+// ClusterTagKey return the key of the tag marking the instances owned by the cluster
+func ClusterTagKey(clusterName string) string {
+	return fmt.Sprintf("kubernetes.io/cluster/%s", clusterName)
+}
+
+// renderTagValue execute the tag value template, a value without template is returned as is
+func renderTagValue(key, value string, data *TagTemplateData) (string, error) {
+	if !strings.Contains(value, "{{") {
+		return value, nil
+	}
+
+	var buffer bytes.Buffer
+
+	tmpl, err := template.New(key).Option("missingkey=error").Parse(value)
+
+	if err == nil {
+		err = tmpl.Execute(&buffer, data)
+	}
+
+	if err != nil {
+		return "", fmt.Errorf(constantes.ErrUnableToRenderTag, key, err)
+	}
+
+	return buffer.String(), nil
+}
+
+// buildTags return the tags of the instance, the declared tags values are rendered with the node values
+func (instance *Ec2Instance) buildTags(create *CreateInput) ([]*ec2.Tag, error) {
+	clusterName := instance.config.GetClusterName(create.NodeGroup)
+	tags := make([]*ec2.Tag, 0, len(instance.config.Tags)+4)
+
+	tags = append(tags, &ec2.Tag{
+		Key:   aws.String("Name"),
+		Value: aws.String(instance.InstanceName),
+	})
+
+	tags = append(tags, &ec2.Tag{
+		Key:   aws.String("NodeGroup"),
+		Value: aws.String(create.NodeGroup),
+	})
+
+	tags = append(tags, &ec2.Tag{
+		Key:   aws.String("NodeIndex"),
+		Value: aws.String(strconv.Itoa(create.NodeIndex)),
+	})
+
+	// The cluster tag is read as the cluster ID by kubernetes controllers, never guess it
+	if !isNullOrEmpty(instance.config.ClusterName) {
+		tags = append(tags, &ec2.Tag{
+			Key:   aws.String(ClusterTagKey(instance.config.ClusterName)),
+			Value: aws.String("owned"),
+		})
+	}
+
+	data := &TagTemplateData{
+		ClusterName: clusterName,
+		NodeGroup:   create.NodeGroup,
+		NodeName:    instance.InstanceName,
+		NodeIndex:   create.NodeIndex,
+		MachineType: create.InstanceType,
+	}
+
+	for _, tag := range instance.config.Tags {
+		value, err := renderTagValue(tag.Key, tag.Value, data)
+
+		if err != nil {
+			return nil, err
+		}
+
+		tags = append(tags, &ec2.Tag{
+			Key:   aws.String(tag.Key),
+			Value: aws.String(value),
+		})
+	}
+
+	return tags, nil
+}
+
+// copyTags return a copy of tags, each tag specification must own its tags
+func copyTags(tags []*ec2.Tag) []*ec2.Tag {
+	result := make([]*ec2.Tag, 0, len(tags))
+
+	for _, tag := range tags {
+		result = append(result, &ec2.Tag{
+			Key:   tag.Key,
+			Value: tag.Value,
+		})
+	}
+
+	return result
+}
+
+// TagResources add tags to the instance, and to its volumes and network interfaces when tags are propagated
+func (instance *Ec2Instance) TagResources(tags map[string]string) error {
+	if len(tags) == 0 {
+		return nil
+	}
+
+	resources := []*string{
+		instance.InstanceID,
+	}
+
+	if instance.config.PropagateTags {
+		ec2Instance, err := instance.getEc2Instance()
+
+		if err != nil {
+			return err
+		}
+
+		for _, mapping := range ec2Instance.BlockDeviceMappings {
+			if mapping.Ebs != nil && mapping.Ebs.VolumeId != nil {
+				resources = append(resources, mapping.Ebs.VolumeId)
+			}
+		}
+
+		for _, inf := range ec2Instance.NetworkInterfaces {
+			resources = append(resources, inf.NetworkInterfaceId)
+		}
+	}
+
+	keys := make([]string, 0, len(tags))
+
+	for key := range tags {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	input := &ec2.CreateTagsInput{
+		Resources: resources,
+		Tags:      make([]*ec2.Tag, 0, len(tags)),
+	}
+
+	for _, key := range keys {
+		input.Tags = append(input.Tags, &ec2.Tag{
+			Key:   aws.String(key),
+			Value: aws.String(tags[key]),
+		})
+	}
+
+	ctx := instance.NewContext()
+	defer ctx.Cancel()
+
+	instance.cache.Invalidate(instance.InstanceID)
+
+	_, err := instance.client.CreateTagsWithContext(ctx, input)
+
+	return err
+}
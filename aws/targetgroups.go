@@ -0,0 +1,137 @@
+package aws
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Fred78290/kubernetes-aws-autoscaler/constantes"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/elbv2"
+	glog "github.com/sirupsen/logrus"
+)
+
+// defaultDeregistrationDelay is the time in seconds to wait for connection draining, the default delay of target groups
+const defaultDeregistrationDelay = 300
+
+// TargetGroup declare an ELBv2 target group receiving the instances, port default to the target group port
+type TargetGroup struct {
+	ARN  string `json:"arn"`
+	Port int    `json:"port,omitempty"`
+}
+
+// GetDeregistrationDelay return the maximum time to wait for connection draining
+func (conf *Configuration) GetDeregistrationDelay() time.Duration {
+	if conf.DeregistrationDelay <= 0 {
+		return defaultDeregistrationDelay * time.Second
+	}
+
+	return conf.DeregistrationDelay * time.Second
+}
+
+// target return the target description of the instance in the target group
+func (instance *Ec2Instance) target(targetGroup *TargetGroup) *elbv2.TargetDescription {
+	target := &elbv2.TargetDescription{
+		Id: instance.InstanceID,
+	}
+
+	if targetGroup.Port > 0 {
+		target.Port = aws.Int64(int64(targetGroup.Port))
+	}
+
+	return target
+}
+
+// RegisterTargets register the instance in the declared target groups
+func (instance *Ec2Instance) RegisterTargets() error {
+	if len(instance.config.TargetGroups) == 0 {
+		return nil
+	}
+
+	client, err := createELBv2Client(instance.config)
+
+	if err != nil {
+		return err
+	}
+
+	ctx := instance.NewContext()
+	defer ctx.Cancel()
+
+	for _, targetGroup := range instance.config.TargetGroups {
+		input := &elbv2.RegisterTargetsInput{
+			TargetGroupArn: aws.String(targetGroup.ARN),
+			Targets: []*elbv2.TargetDescription{
+				instance.target(&targetGroup),
+			},
+		}
+
+		if _, err = client.RegisterTargetsWithContext(ctx, input); err != nil {
+			return fmt.Errorf(constantes.ErrUnableToRegisterTarget, instance.InstanceName, targetGroup.ARN, err)
+		}
+
+		glog.Infof("Registered instance %s in target group %s", instance.InstanceName, targetGroup.ARN)
+	}
+
+	return nil
+}
+
+// DeregisterTargets deregister the instance from the declared target groups and wait for connection draining
+func (instance *Ec2Instance) DeregisterTargets() error {
+	if len(instance.config.TargetGroups) == 0 {
+		return nil
+	}
+
+	client, err := createELBv2Client(instance.config)
+
+	if err != nil {
+		return err
+	}
+
+	ctx := instance.NewContext()
+	defer ctx.Cancel()
+
+	for _, targetGroup := range instance.config.TargetGroups {
+		input := &elbv2.DeregisterTargetsInput{
+			TargetGroupArn: aws.String(targetGroup.ARN),
+			Targets: []*elbv2.TargetDescription{
+				instance.target(&targetGroup),
+			},
+		}
+
+		if _, err = client.DeregisterTargetsWithContext(ctx, input); err != nil {
+			return fmt.Errorf(constantes.ErrUnableToDeregisterTarget, instance.InstanceName, targetGroup.ARN, err)
+		}
+	}
+
+	// Targets drain in parallel, wait until none of them is draining
+	return instance.pollImmediate(time.Second, instance.config.GetDeregistrationDelay(), func() (bool, error) {
+		ctx := instance.NewContext()
+		defer ctx.Cancel()
+
+		for _, targetGroup := range instance.config.TargetGroups {
+			input := &elbv2.DescribeTargetHealthInput{
+				TargetGroupArn: aws.String(targetGroup.ARN),
+				Targets: []*elbv2.TargetDescription{
+					instance.target(&targetGroup),
+				},
+			}
+
+			output, err := client.DescribeTargetHealthWithContext(ctx, input)
+
+			if err != nil {
+				return false, fmt.Errorf(constantes.ErrUnableToDeregisterTarget, instance.InstanceName, targetGroup.ARN, err)
+			}
+
+			for _, description := range output.TargetHealthDescriptions {
+				if description.TargetHealth != nil && aws.StringValue(description.TargetHealth.State) == elbv2.TargetHealthStateEnumDraining {
+					glog.Debugf("DeregisterTargets: instance %s is draining from target group %s", instance.InstanceName, targetGroup.ARN)
+
+					return false, nil
+				}
+			}
+		}
+
+		glog.Infof("Deregistered instance %s from target groups", instance.InstanceName)
+
+		return true, nil
+	})
+}
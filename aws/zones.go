@@ -0,0 +1,118 @@
+package aws
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Fred78290/kubernetes-aws-autoscaler/constantes"
+	"github.com/Fred78290/kubernetes-aws-autoscaler/context"
+	glog "github.com/sirupsen/logrus"
+)
+
+// defaultZoneCoolDown is the time in seconds a zone is skipped after a failed launch
+const defaultZoneCoolDown = 300
+
+// zoneFailures record the last failed launch by region and availability zone
+var zoneFailures sync.Map
+
+// GetZoneCoolDown return the duration a zone is skipped after a failed launch
+func (conf *Configuration) GetZoneCoolDown() time.Duration {
+	if conf.ZoneCoolDown <= 0 {
+		return defaultZoneCoolDown * time.Second
+	}
+
+	return conf.ZoneCoolDown * time.Second
+}
+
+// MarkZoneFailed skip the zone for subnet selection during the cool-down period
+func (conf *Configuration) MarkZoneFailed(zone string) {
+	glog.Infof("Availability zone %s skipped for %v after failed launch", zone, conf.GetZoneCoolDown())
+
+	zoneFailures.Store(fmt.Sprintf("%s/%s", conf.Region, zone), time.Now())
+}
+
+// IsZoneCoolingDown return true if the zone recently failed to launch an instance
+func (conf *Configuration) IsZoneCoolingDown(zone string) bool {
+	if failedAt, found := zoneFailures.Load(fmt.Sprintf("%s/%s", conf.Region, zone)); found {
+		return time.Since(failedAt.(time.Time)) < conf.GetZoneCoolDown()
+	}
+
+	return false
+}
+
+// selectZone return the least populated availability zone of the node group among the subnets of the primary interface.
+// Zones in cool-down are skipped unless all zones are cooling down, ties are broken by node index
+func (instance *Ec2Instance) selectZone(ctx *context.Context, create *CreateInput) (string, error) {
+	network := instance.networkInterfaces()
+
+	if len(create.Zone) > 0 || len(network) == 0 {
+		return create.Zone, nil
+	}
+
+	if desiredENI := create.DesiredENI; desiredENI != nil && (len(desiredENI.SubnetID) > 0 || len(desiredENI.NetworkInterfaceID) > 0) {
+		return create.Zone, nil
+	}
+
+	subnetsID := network[0].SubnetsID
+
+	if len(subnetsID) < 2 {
+		return create.Zone, nil
+	}
+
+	subnetZones, err := instance.getSubnetZones(ctx, subnetsID)
+
+	if err != nil {
+		return "", err
+	}
+
+	zones := make([]string, 0, len(subnetsID))
+	found := make(map[string]bool)
+
+	for _, subnetID := range subnetsID {
+		if zone := subnetZones[subnetID]; len(zone) > 0 && !found[zone] {
+			found[zone] = true
+			zones = append(zones, zone)
+		}
+	}
+
+	if len(zones) < 2 {
+		return create.Zone, nil
+	}
+
+	counts, err := instance.config.GetInstanceCache(create.NodeGroup).CountByZone()
+
+	if err != nil {
+		return "", err
+	}
+
+	candidates := make([]string, 0, len(zones))
+
+	for index := range zones {
+		zone := zones[(create.NodeIndex+index)%len(zones)]
+
+		if !instance.config.IsZoneCoolingDown(zone) {
+			candidates = append(candidates, zone)
+		}
+	}
+
+	if len(candidates) == 0 {
+		glog.Warnf(constantes.WarnAllZonesCoolingDown, create.NodeGroup)
+
+		for index := range zones {
+			candidates = append(candidates, zones[(create.NodeIndex+index)%len(zones)])
+		}
+	}
+
+	selected := candidates[0]
+
+	for _, zone := range candidates[1:] {
+		if counts[zone] < counts[selected] {
+			selected = zone
+		}
+	}
+
+	glog.Debugf("selectZone: node group %s, nodes by zone: %v, selected zone: %s", create.NodeGroup, counts, selected)
+
+	return selected, nil
+}
@@ -35,6 +35,9 @@ const (
 	retrySleep                = time.Millisecond * 250
 )
 
+// eventSourceComponent source of the recorded node events
+const eventSourceComponent = "kubernetes-aws-autoscaler"
+
 // SingletonClientGenerator provides clients
 type SingletonClientGenerator struct {
 	KubeConfig           string
@@ -657,6 +660,51 @@ func (p *SingletonClientGenerator) TaintNode(nodeName string, taints ...apiv1.Ta
 	})
 }
 
+// RecordNodeEvent create a kubernetes event on the node
+func (p *SingletonClientGenerator) RecordNodeEvent(nodeName, eventType, reason, message string) error {
+	ctx := p.newRequestContext()
+	defer ctx.Cancel()
+
+	kubeclient, err := p.KubeClient()
+
+	if err != nil {
+		return err
+	}
+
+	nodeInfo, err := kubeclient.CoreV1().Nodes().Get(ctx, nodeName, metav1.GetOptions{})
+
+	if err != nil {
+		return err
+	}
+
+	now := metav1.Now()
+	event := &apiv1.Event{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: nodeName + ".",
+			Namespace:    metav1.NamespaceDefault,
+		},
+		InvolvedObject: apiv1.ObjectReference{
+			APIVersion: "v1",
+			Kind:       "Node",
+			Name:       nodeName,
+			UID:        nodeInfo.UID,
+		},
+		Reason:         reason,
+		Message:        message,
+		Type:           eventType,
+		Count:          1,
+		FirstTimestamp: now,
+		LastTimestamp:  now,
+		Source: apiv1.EventSource{
+			Component: eventSourceComponent,
+		},
+	}
+
+	_, err = kubeclient.CoreV1().Events(metav1.NamespaceDefault).Create(ctx, event, metav1.CreateOptions{})
+
+	return err
+}
+
 func NewClientGenerator(cfg *types.Config) types.ClientGenerator {
 	return &SingletonClientGenerator{
 		KubeConfig:       cfg.KubeConfig,
@@ -33,6 +33,9 @@ const (
 	// NodeLabelTopologyZone topology label
 	NodeLabelTopologyZone = "topology.kubernetes.io/zone"
 
+	// NodeLabelInstanceLifecycle spot or on-demand label
+	NodeLabelInstanceLifecycle = "node.kubernetes.io/lifecycle"
+
 	// AnnotationNodeGroupName k8s annotation
 	AnnotationNodeGroupName = "cluster.autoscaler.nodegroup/name"
 
@@ -51,6 +54,15 @@ const (
 	// AnnotationNodeManaged k8s annotation
 	AnnotationNodeManaged = "cluster.autoscaler.nodegroup/managed"
 
+	// AnnotationInstanceLifecycle k8s annotation
+	AnnotationInstanceLifecycle = "cluster.autoscaler.nodegroup/instance-lifecycle"
+
+	// AnnotationImageID k8s annotation
+	AnnotationImageID = "cluster.autoscaler.nodegroup/image-id"
+
+	// AnnotationWarmPool k8s annotation
+	AnnotationWarmPool = "cluster.autoscaler.nodegroup/warm-pool"
+
 	// AnnotationScaleDownDisabled k8s annotation
 	AnnotationScaleDownDisabled = "cluster-autoscaler.kubernetes.io/scale-down-disabled"
 )
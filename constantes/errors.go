@@ -119,7 +119,7 @@ const (
 	ErrStartVMFailed = "could not start VM: %s, reason: %v"
 
 	// ErrRegisterDNSVMFailed error msg
-	ErrRegisterDNSVMFailed = "could not register route53 record VM: %s, reason: %v"
+	ErrRegisterDNSVMFailed = "could not register DNS record VM: %s, reason: %v"
 
 	// ErrDeleteVMFailed error msg
 	ErrDeleteVMFailed = "could not delete VM: %s, reason: %v"
@@ -205,6 +205,57 @@ const (
 	// WarnFailedVMNotDeleted warn msg
 	WarnFailedVMNotDeleted = "the failed VM:%s is not deleted because status is:%s"
 
+	// WarnFallbackMachineTypeNotFound warn msg
+	WarnFallbackMachineTypeNotFound = "fallback machine type %s for node group %s is not declared in machines, ignored"
+
+	// WarnLaunchVMInsufficientCapacity warn msg
+	WarnLaunchVMInsufficientCapacity = "unable to launch VM:%s with instance type:%s in zone:%s, try next candidate, reason: %v"
+
+	// WarnAllZonesCoolingDown warn msg
+	WarnAllZonesCoolingDown = "all availability zones of node group %s recently failed, cool-down ignored"
+
+	// WarnMachineTypesStaleCache warn msg
+	WarnMachineTypesStaleCache = "unable to discover machine types, use stale cache %s, reason: %v"
+
+	// WarnUnableToSaveMachineTypesCache warn msg
+	WarnUnableToSaveMachineTypesCache = "unable to save machine types cache %s, reason: %v"
+
+	// WarnUnableToSavePriceCatalog warn msg
+	WarnUnableToSavePriceCatalog = "unable to save price catalog %s, reason: %v"
+
+	// WarnPriceRefreshFailed warn msg
+	WarnPriceRefreshFailed = "unable to refresh price of instance type %s from price list api, use catalog, reason: %v"
+
+	// WarnUnableToReturnToWarmPool warn msg
+	WarnUnableToReturnToWarmPool = "unable to return node %s to warm pool, terminate it, reason: %v"
+
+	// WarnCapacityReservationExhausted warn msg
+	WarnCapacityReservationExhausted = "targeted capacity reservation exhausted for VM:%s, launch with open preference, reason: %v"
+
+	// WarnSubnetWithoutFreeIPAddresses warn msg
+	WarnSubnetWithoutFreeIPAddresses = "subnet %s skipped, only %d free IP addresses"
+
+	// WarnOrphanedInstance warn msg
+	WarnOrphanedInstance = "instance %s (%s) of nodegroup %s has no node, report only"
+
+	// WarnUnparsableInterruptionEvent warn msg
+	WarnUnparsableInterruptionEvent = "unable to parse interruption event %s, reason: %v"
+
+	// WarnScheduledEventDeadlinePassed warn msg
+	WarnScheduledEventDeadlinePassed = "node %s is retired after the deadline of event %s at %v"
+
+	// WarnWarmPoolSpot warn msg
+	WarnWarmPoolSpot = "warm pool of nodegroup %s is not filled, spot instances can't be stopped"
+
+	// WarnOrphanCollectorReportOnly warn msg
+	WarnOrphanCollectorReportOnly = "cluster name of nodegroup %s is not declared, orphaned instances are only reported"
+
+	// WarnUnableToUnstageArchive warn msg
+	WarnUnableToUnstageArchive = "unable to remove staged archive %s of instance %s, reason: %v"
+
+	// WarnCircuitBreakerStateChanged warn msg
+	WarnCircuitBreakerStateChanged = "circuit breaker of region %s changed from %v to %v"
+
 	// ErrWrongStateMachine error msg
 	ErrWrongStateMachine = "unexpected instance state %s for instance %s, expected prending or running"
 
@@ -243,4 +294,148 @@ const (
 
 	// ErrFatalKubernetesPKIMissingOrUnreadable err msg
 	ErrFatalKubernetesPKIMissingOrUnreadable = "%s kubernetes pki directory is missing or unreadable"
+
+	// ErrUnableToDescribeLaunchTemplate err msg
+	ErrUnableToDescribeLaunchTemplate = "unable to describe launch template %s version %s, reason: %v"
+
+	// ErrLaunchTemplateNotFound err msg
+	ErrLaunchTemplateNotFound = "launch template %s version %s not found"
+
+	// ErrUnableToDescribeImage err msg
+	ErrUnableToDescribeImage = "unable to describe image %s, reason: %v"
+
+	// ErrUnableToDescribeSubnets err msg
+	ErrUnableToDescribeSubnets = "unable to describe subnets, reason: %v"
+
+	// ErrNoSubnetInZone err msg
+	ErrNoSubnetInZone = "no subnet found in availability zone %s"
+
+	// ErrUnableToCountNodesByZone err msg
+	ErrUnableToCountNodesByZone = "unable to count nodes by zone for node group %s, reason: %v"
+
+	// ErrUnableToRefreshInstanceCache err msg
+	ErrUnableToRefreshInstanceCache = "unable to refresh instances cache for node group %s, reason: %v"
+
+	// ErrRFC2136UpdateFailed err msg
+	ErrRFC2136UpdateFailed = "dynamic update to DNS server %s failed, reason: %v"
+
+	// ErrCircuitBreakerOpen err msg
+	ErrCircuitBreakerOpen = "aws api of region %s is unhealthy, circuit breaker is open"
+
+	// ErrUnableToIncreaseSize err msg
+	ErrUnableToIncreaseSize = "unable to increase size of node group %s, reason: %v"
+
+	// ErrUnableToDiscoverMachineTypes err msg
+	ErrUnableToDiscoverMachineTypes = "unable to discover machine types in region %s, reason: %v"
+
+	// ErrMachineTypesCacheRegionMismatch err msg
+	ErrMachineTypesCacheRegionMismatch = "machine types cache %s is for region %s, expected %s"
+
+	// ErrUnableToLoadPriceCatalog err msg
+	ErrUnableToLoadPriceCatalog = "unable to load price catalog %s, reason: %v"
+
+	// ErrPriceNotFound err msg
+	ErrPriceNotFound = "price of instance type %s not found in region %s"
+
+	// ErrUnableToRefreshPrice err msg
+	ErrUnableToRefreshPrice = "unable to refresh price of instance type %s, reason: %v"
+
+	// ErrUnableToDescribeInstanceType err msg
+	ErrUnableToDescribeInstanceType = "unable to describe instance type %s, reason: %v"
+
+	// ErrSSMParameterEmpty err msg
+	ErrSSMParameterEmpty = "ssm parameter %s is empty"
+
+	// ErrNoImageMatchFilters err msg
+	ErrNoImageMatchFilters = "no available image match filters for architecture %s"
+
+	// ErrNoImageForArchitecture err msg
+	ErrNoImageForArchitecture = "no image declared for architecture %s of instance type %s"
+
+	// ErrUnableToResolveImage err msg
+	ErrUnableToResolveImage = "unable to resolve image for architecture %s, reason: %v"
+
+	// ErrBlockDeviceSizeUndefined err msg
+	ErrBlockDeviceSizeUndefined = "volume size is not defined for block device %s"
+
+	// ErrUnableToFillWarmPool err msg
+	ErrUnableToFillWarmPool = "unable to fill warm pool of node group %s, reason: %v"
+
+	// ErrUnableToRenderTag err msg
+	ErrUnableToRenderTag = "unable to render value of tag %s, reason: %v"
+
+	// ErrUnableToCopyLabelsToTags err msg
+	ErrUnableToCopyLabelsToTags = "unable to copy labels of node %s to tags, reason: %v"
+
+	// ErrUnableToDiscoverSubnets err msg
+	ErrUnableToDiscoverSubnets = "unable to discover subnets matching %v, reason: %v"
+
+	// ErrNoSubnetMatchSelector err msg
+	ErrNoSubnetMatchSelector = "no available subnet with at least %d free IP addresses match %v"
+
+	// ErrUnableToDiscoverSecurityGroups err msg
+	ErrUnableToDiscoverSecurityGroups = "unable to discover security groups matching %v, reason: %v"
+
+	// ErrNoSecurityGroupMatchSelector err msg
+	ErrNoSecurityGroupMatchSelector = "no security group match %v"
+
+	// ErrUnableToRegisterTarget err msg
+	ErrUnableToRegisterTarget = "unable to register instance %s in target group %s, reason: %v"
+
+	// ErrUnableToDeregisterTarget err msg
+	ErrUnableToDeregisterTarget = "unable to deregister instance %s from target group %s, reason: %v"
+
+	// ErrRegisterTargetsVMFailed err msg
+	ErrRegisterTargetsVMFailed = "unable to register VM: %s in target groups, reason: %v"
+
+	// ErrUnableToDeregisterTargets err msg
+	ErrUnableToDeregisterTargets = "unable to deregister VM: %s from target groups, reason: %v"
+
+	// ErrUnableToDeleteOrphan err msg
+	ErrUnableToDeleteOrphan = "unable to terminate orphaned instance %s of nodegroup %s, reason: %v"
+
+	// ErrUnableToCollectOrphans err msg
+	ErrUnableToCollectOrphans = "unable to collect orphaned instances of nodegroup %s, reason: %v"
+
+	// ErrUnableToReceiveInterruptionEvents err msg
+	ErrUnableToReceiveInterruptionEvents = "unable to receive interruption events from queue %s, reason: %v"
+
+	// ErrUnableToDeleteInterruptionEvent err msg
+	ErrUnableToDeleteInterruptionEvent = "unable to delete interruption event %s, reason: %v"
+
+	// ErrUnableToLaunchReplacement err msg
+	ErrUnableToLaunchReplacement = "unable to launch replacement of interrupted node %s, reason: %v"
+
+	// ErrUnableToDescribeInstanceStatus err msg
+	ErrUnableToDescribeInstanceStatus = "unable to describe instance status, reason: %v"
+
+	// ErrUnableToRecordNodeEvent err msg
+	ErrUnableToRecordNodeEvent = "unable to record event on node %s, reason: %v"
+
+	// ErrUnableToRetireNode err msg
+	ErrUnableToRetireNode = "unable to retire node %s, reason: %v"
+
+	// ErrUnableToCheckScheduledEvents err msg
+	ErrUnableToCheckScheduledEvents = "unable to check scheduled events of nodegroup %s, reason: %v"
+
+	// ErrUnableToSendCommand err msg
+	ErrUnableToSendCommand = "unable to send command to instance %s, reason: %v"
+
+	// ErrRemoteCommandFailed err msg
+	ErrRemoteCommandFailed = "command on instance %s ended with status %s, output: %s"
+
+	// ErrUnableToDescribeManagedInstance err msg
+	ErrUnableToDescribeManagedInstance = "unable to describe SSM agent of instance %s, reason: %v"
+
+	// ErrUnableToStageArchive err msg
+	ErrUnableToStageArchive = "unable to stage archive %s for instance %s, reason: %v"
+
+	// ErrArchiveTooLarge err msg
+	ErrArchiveTooLarge = "archive %s of %d bytes exceed the SSM parameter size limit, declare a bucket to transfer it"
+
+	// ErrUnableToDescribeSecurityGroups err msg
+	ErrUnableToDescribeSecurityGroups = "unable to describe security groups, reason: %v"
+
+	// ErrUnknownNetworkVpc err msg
+	ErrUnknownNetworkVpc = "unable to find the vpc of subnets %v and security groups %v, declare vpcId, a subnet or a security group"
 )
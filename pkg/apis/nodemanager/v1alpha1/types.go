@@ -22,6 +22,7 @@ type ManagedNodeNetwork struct {
 	SecurityGroupID    string `json:"securityGroup,omitempty"`
 	NetworkInterfaceID string `json:"networkInterfaceID,omitempty"`
 	PrivateAddress     string `json:"privateAddress,omitempty"`
+	IPv6Address        string `json:"ipv6Address,omitempty"`
 	PublicIP           bool   `json:"publicIP,omitempty"`
 }
 
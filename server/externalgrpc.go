@@ -3,6 +3,7 @@ package server
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/Fred78290/kubernetes-aws-autoscaler/constantes"
 	"github.com/Fred78290/kubernetes-aws-autoscaler/externalgrpc"
@@ -103,6 +104,11 @@ func (v *externalgrpcServerApp) NodeGroupForNode(ctx context.Context, request *e
 			return nil, fmt.Errorf(constantes.ErrNodeGroupForNodeNotFound, nodegroupName, request.Node.Name)
 		}
 
+		// Stopped nodes of the warm pool are not processed by cluster autoscaler
+		if nodeGroup.findWarmNode(request.Node.Name) != nil {
+			return &externalgrpc.NodeGroupForNodeResponse{}, nil
+		}
+
 		return &externalgrpc.NodeGroupForNodeResponse{
 			NodeGroup: &externalgrpc.NodeGroup{
 				Id:      nodeGroup.NodeGroupIdentifier,
@@ -115,6 +121,15 @@ func (v *externalgrpcServerApp) NodeGroupForNode(ctx context.Context, request *e
 	}
 }
 
+// timeOf return the time or the zero time when undefined
+func timeOf(t *metav1.Time) time.Time {
+	if t == nil {
+		return time.Time{}
+	}
+
+	return t.Time
+}
+
 // PricingNodePrice returns a theoretical minimum price of running a node for
 // a given period of time on a perfectly matching machine.
 // Implementation optional.
@@ -122,7 +137,7 @@ func (v *externalgrpcServerApp) PricingNodePrice(ctx context.Context, request *e
 	glog.Debugf("Call server NodePrice: %v", request)
 
 	return &externalgrpc.PricingNodePriceResponse{
-		Price: v.appServer.configuration.NodePrice,
+		Price: v.appServer.nodePrice(request.GetNode().GetAnnotations(), timeOf(request.GetStartTime()), timeOf(request.GetEndTime())),
 	}, nil
 }
 
@@ -133,7 +148,7 @@ func (v *externalgrpcServerApp) PricingPodPrice(ctx context.Context, request *ex
 	glog.Debugf("Call server PodPrice: %v", request)
 
 	return &externalgrpc.PricingPodPriceResponse{
-		Price: v.appServer.configuration.PodPrice,
+		Price: v.appServer.podPrice(request.GetPod(), timeOf(request.GetStartTime()), timeOf(request.GetEndTime())),
 	}, nil
 }
 
@@ -170,7 +185,9 @@ func (v *externalgrpcServerApp) Cleanup(ctx context.Context, request *externalgr
 
 	glog.Debug("Leave server Cleanup, done")
 
+	v.appServer.groupsLock.Lock()
 	v.appServer.Groups = make(map[string]*AutoScalerServerNodeGroup)
+	v.appServer.groupsLock.Unlock()
 
 	return &externalgrpc.CleanupResponse{}, lastError
 }
@@ -0,0 +1,129 @@
+package server
+
+import (
+	"time"
+
+	"github.com/Fred78290/kubernetes-aws-autoscaler/aws"
+	"github.com/Fred78290/kubernetes-aws-autoscaler/constantes"
+	glog "github.com/sirupsen/logrus"
+)
+
+// findNodeByInstanceID return the node group and the node running the instance, nil if not found
+func (s *AutoScalerServerApp) findNodeByInstanceID(instanceID string) (*AutoScalerServerNodeGroup, *AutoScalerServerNode) {
+	for _, ng := range s.nodeGroups() {
+		for _, node := range ng.AllNodes() {
+			if node.runningInstance != nil && node.runningInstance.InstanceID != nil && *node.runningInstance.InstanceID == instanceID {
+				return ng, node
+			}
+		}
+	}
+
+	return nil, nil
+}
+
+// launchReplacement increase the node group size by one to replace the interrupted node
+func (s *AutoScalerServerApp) launchReplacement(ng *AutoScalerServerNodeGroup, node *AutoScalerServerNode) {
+	glog.Infof("Launch replacement of interrupted node:%s in nodegroup:%s", node.InstanceName, ng.NodeGroupIdentifier)
+
+	if _, err := ng.increaseSize(s.kubeClient, 1); err != nil {
+		glog.Errorf(constantes.ErrUnableToLaunchReplacement, node.InstanceName, err)
+	}
+}
+
+// acceptInterruption return the node targeted by the event and mark it interrupted,
+// nil if the instance is not a node or already handled. Return false if the instance is not a node
+func (s *AutoScalerServerApp) acceptInterruption(event *aws.InterruptionEvent) (*AutoScalerServerNodeGroup, *AutoScalerServerNode, bool) {
+	ng, node := s.findNodeByInstanceID(event.InstanceID)
+
+	if node == nil {
+		glog.Debugf("Ignore %s of instance:%s, not a node", event.Kind, event.InstanceID)
+
+		return nil, nil, false
+	}
+
+	ng.Lock()
+	defer ng.Unlock()
+
+	if node.Interrupted {
+		glog.Debugf("Ignore %s of node:%s, already handled", event.Kind, node.InstanceName)
+
+		return nil, nil, true
+	}
+
+	glog.Infof("Received %s for node:%s in nodegroup:%s", event.Kind, node.InstanceName, ng.NodeGroupIdentifier)
+
+	node.Interrupted = true
+
+	return ng, node, true
+}
+
+// handleInterruption cordon and drain the node before the instance is interrupted,
+// the replacement is launched first if enabled
+func (s *AutoScalerServerApp) handleInterruption(options *aws.InterruptionQueueOptions, ng *AutoScalerServerNodeGroup, node *AutoScalerServerNode) {
+	if options.LaunchReplacement {
+		go s.launchReplacement(ng, node)
+	}
+
+	if err := s.kubeClient.MarkDrainNode(node.NodeName); err != nil {
+		glog.Errorf(constantes.ErrCordonNodeReturnError, node.NodeName, err)
+	}
+
+	if err := s.kubeClient.DrainNode(node.NodeName, true, true); err != nil {
+		glog.Errorf(constantes.ErrDrainNodeReturnError, node.NodeName, err)
+	}
+}
+
+// pollInterruptionQueue receive the pending events of the queue, each event targeting a node is deleted
+// and handled in background so a long drain doesn't delay the other events. Each node is handled once.
+// Events of other instances are left in the queue for other consumers sharing it
+func (s *AutoScalerServerApp) pollInterruptionQueue(awsConfig *aws.Configuration) error {
+	events, err := awsConfig.ReceiveInterruptionEvents()
+
+	if err != nil {
+		return err
+	}
+
+	for _, event := range events {
+		ng, node, owned := s.acceptInterruption(event)
+
+		if !owned {
+			continue
+		}
+
+		if err = awsConfig.DeleteInterruptionEvent(event); err != nil {
+			glog.Errorf(constantes.ErrUnableToDeleteInterruptionEvent, event.InstanceID, err)
+		}
+
+		if node != nil {
+			go s.handleInterruption(awsConfig.InterruptionQueue, ng, node)
+		}
+	}
+
+	return nil
+}
+
+// startInterruptionHandler consume each interruption queue declared in aws configurations
+func (s *AutoScalerServerApp) startInterruptionHandler() {
+	queues := make(map[string]bool)
+
+	for _, awsConfig := range s.configuration.AwsInfos {
+		if awsConfig.InterruptionQueue == nil || queues[awsConfig.InterruptionQueue.URL] {
+			continue
+		}
+
+		queues[awsConfig.InterruptionQueue.URL] = true
+
+		glog.Infof("Start interruption handler on queue:%s", awsConfig.InterruptionQueue.URL)
+
+		go func(awsConfig *aws.Configuration) {
+			for {
+				if err := s.pollInterruptionQueue(awsConfig); err != nil {
+					glog.Error(err)
+
+					// Don't spin while the queue is unreachable
+					time.Sleep(awsConfig.InterruptionQueue.GetWaitTime())
+				}
+			}
+		}(awsConfig)
+	}
+}
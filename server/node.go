@@ -3,7 +3,6 @@ package server
 import (
 	"encoding/base64"
 	"fmt"
-	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
@@ -63,6 +62,12 @@ const (
 	AutoScalerServerNodeManaged
 )
 
+// launchCandidate instance type and zone tried to launch a VM
+type launchCandidate struct {
+	instanceType string
+	zone         string
+}
+
 // AutoScalerServerNode Describe a AutoScaler VM
 // Node name and instance name could be differ when using AWS cloud provider
 type AutoScalerServerNode struct {
@@ -76,13 +81,18 @@ type AutoScalerServerNode struct {
 	DiskSize         int                       `json:"diskSize"`
 	DiskType         string                    `default:"standard" json:"diskType"`
 	InstanceType     string                    `json:"instance-Type"`
+	SpotInstance     bool                      `json:"spot-instance,omitempty"`
 	IPAddress        string                    `json:"address"`
+	IPv6Address      string                    `json:"ipv6-address,omitempty"`
 	State            AutoScalerServerNodeState `json:"state"`
 	NodeType         AutoScalerServerNodeType  `json:"type"`
 	ControlPlaneNode bool                      `json:"control-plane,omitempty"`
 	AllowDeployment  bool                      `json:"allow-deployment,omitempty"`
 	ExtraLabels      types.KubernetesLabel     `json:"labels,omitempty"`
 	ExtraAnnotations types.KubernetesLabel     `json:"annotations,omitempty"`
+	Interrupted      bool                      `json:"interrupted,omitempty"`
+	ScheduledEvent   string                    `json:"scheduled-event,omitempty"`
+	retiringEvent    *aws.ScheduledEvent
 	awsConfig        *aws.Configuration
 	runningInstance  *aws.Ec2Instance
 	desiredENI       *aws.UserDefinedNetworkInterface
@@ -105,15 +115,7 @@ func (vm *AutoScalerServerNode) recopyEtcdSslFilesIfNeeded() error {
 	if vm.ControlPlaneNode || *vm.serverConfig.UseExternalEtdc {
 		glog.Infof("Recopy Etcd ssl files for instance: %s in node group: %s", vm.InstanceName, vm.NodeGroupID)
 
-		if err = utils.Scp(vm.serverConfig.SSH, vm.IPAddress, vm.serverConfig.ExtSourceEtcdSslDir, "."); err != nil {
-			glog.Errorf("scp failed: %v", err)
-		} else if _, err = utils.Sudo(vm.serverConfig.SSH, vm.IPAddress, vm.awsConfig.Timeout, fmt.Sprintf("mkdir -p %s", vm.serverConfig.ExtDestinationEtcdSslDir)); err != nil {
-			glog.Errorf("mkdir failed: %v", err)
-		} else if _, err = utils.Sudo(vm.serverConfig.SSH, vm.IPAddress, vm.awsConfig.Timeout, fmt.Sprintf("cp -r %s/* %s", filepath.Base(vm.serverConfig.ExtSourceEtcdSslDir), vm.serverConfig.ExtDestinationEtcdSslDir)); err != nil {
-			glog.Errorf("mv failed: %v", err)
-		} else if _, err = utils.Sudo(vm.serverConfig.SSH, vm.IPAddress, vm.awsConfig.Timeout, fmt.Sprintf("chown -R root:root %s", vm.serverConfig.ExtDestinationEtcdSslDir)); err != nil {
-			glog.Errorf("chown failed: %v", err)
-		}
+		err = vm.remoteExecutor(vm.IPAddress, vm.awsConfig.Timeout).copyDir(vm.serverConfig.ExtSourceEtcdSslDir, vm.serverConfig.ExtDestinationEtcdSslDir)
 	}
 
 	return err
@@ -125,15 +127,7 @@ func (vm *AutoScalerServerNode) recopyKubernetesPKIIfNeeded() error {
 	if vm.ControlPlaneNode {
 		glog.Infof("Recopy PKI for instance: %s in node group: %s", vm.InstanceName, vm.NodeGroupID)
 
-		if err = utils.Scp(vm.serverConfig.SSH, vm.IPAddress, vm.serverConfig.KubernetesPKISourceDir, "."); err != nil {
-			glog.Errorf("scp failed: %v", err)
-		} else if _, err = utils.Sudo(vm.serverConfig.SSH, vm.IPAddress, vm.awsConfig.Timeout, fmt.Sprintf("mkdir -p %s", vm.serverConfig.KubernetesPKIDestDir)); err != nil {
-			glog.Errorf("mkdir failed: %v", err)
-		} else if _, err = utils.Sudo(vm.serverConfig.SSH, vm.IPAddress, vm.awsConfig.Timeout, fmt.Sprintf("cp -r %s/* %s", filepath.Base(vm.serverConfig.KubernetesPKISourceDir), vm.serverConfig.KubernetesPKIDestDir)); err != nil {
-			glog.Errorf("mv failed: %v", err)
-		} else if _, err = utils.Sudo(vm.serverConfig.SSH, vm.IPAddress, vm.awsConfig.Timeout, fmt.Sprintf("chown -R root:root %s", vm.serverConfig.KubernetesPKIDestDir)); err != nil {
-			glog.Errorf("chown failed: %v", err)
-		}
+		err = vm.remoteExecutor(vm.IPAddress, vm.awsConfig.Timeout).copyDir(vm.serverConfig.KubernetesPKISourceDir, vm.serverConfig.KubernetesPKIDestDir)
 	}
 
 	return err
@@ -168,8 +162,9 @@ func (vm *AutoScalerServerNode) kubeAdmJoin(c types.ClientGenerator) error {
 	}
 
 	command := strings.Join(args, " ")
+	executor := vm.remoteExecutor(vm.IPAddress, vm.awsConfig.Timeout)
 
-	if out, err := utils.Sudo(vm.serverConfig.SSH, vm.IPAddress, vm.awsConfig.Timeout, command); err != nil {
+	if out, err := executor.sudo(command); err != nil {
 		return fmt.Errorf("unable to execute command: %s, output: %s, reason:%v", command, out, err)
 	}
 
@@ -183,7 +178,7 @@ func (vm *AutoScalerServerNode) kubeAdmJoin(c types.ClientGenerator) error {
 
 		glog.Infof("Restart kubelet for node:%s for nodegroup: %s", vm.NodeName, vm.NodeGroupID)
 
-		if out, err := utils.Sudo(vm.serverConfig.SSH, vm.IPAddress, vm.awsConfig.Timeout, "systemctl restart kubelet"); err != nil {
+		if out, err := executor.sudo("systemctl restart kubelet"); err != nil {
 			return false, fmt.Errorf("unable to restart kubelet, output: %s, reason:%v", out, err)
 		}
 
@@ -231,7 +226,7 @@ func (vm *AutoScalerServerNode) k3sAgentJoin(c types.ClientGenerator) error {
 	glog.Infof("Join cluster for node:%s for nodegroup: %s", vm.NodeName, vm.NodeGroupID)
 
 	command := fmt.Sprintf("sh -c \"%s\"", strings.Join(args, " && "))
-	if out, err := utils.Sudo(vm.serverConfig.SSH, vm.IPAddress, vm.awsConfig.Timeout, command); err != nil {
+	if out, err := vm.remoteExecutor(vm.IPAddress, vm.awsConfig.Timeout).sudo(command); err != nil {
 		return fmt.Errorf("unable to execute command: %s, output: %s, reason:%v", command, out, err)
 	}
 
@@ -254,8 +249,9 @@ func (vm *AutoScalerServerNode) joinCluster(c types.ClientGenerator) error {
 
 func (vm *AutoScalerServerNode) setNodeLabels(c types.ClientGenerator, nodeLabels, systemLabels types.KubernetesLabel) error {
 	topology := types.KubernetesLabel{
-		constantes.NodeLabelTopologyRegion: *vm.runningInstance.Region,
-		constantes.NodeLabelTopologyZone:   *vm.runningInstance.Zone,
+		constantes.NodeLabelTopologyRegion:    *vm.runningInstance.Region,
+		constantes.NodeLabelTopologyZone:      *vm.runningInstance.Zone,
+		constantes.NodeLabelInstanceLifecycle: vm.runningInstance.Lifecycle(),
 	}
 
 	labels := utils.MergeKubernetesLabel(nodeLabels, topology, systemLabels, vm.ExtraLabels)
@@ -272,6 +268,8 @@ func (vm *AutoScalerServerNode) setNodeLabels(c types.ClientGenerator, nodeLabel
 		constantes.AnnotationNodeIndex:            strconv.Itoa(vm.NodeIndex),
 		constantes.AnnotationInstanceName:         vm.InstanceName,
 		constantes.AnnotationInstanceID:           *vm.runningInstance.InstanceID,
+		constantes.AnnotationInstanceLifecycle:    vm.runningInstance.Lifecycle(),
+		constantes.AnnotationImageID:              vm.runningInstance.AMI(),
 	}
 
 	annotations = utils.MergeKubernetesLabel(annotations, vm.ExtraAnnotations)
@@ -303,12 +301,41 @@ func (vm *AutoScalerServerNode) setNodeLabels(c types.ClientGenerator, nodeLabel
 	return nil
 }
 
-// WaitSSHReady method SSH test IP
+// copyNodeLabelsToTags copy the declared kubernetes node labels onto the instance tags
+func (vm *AutoScalerServerNode) copyNodeLabelsToTags(c types.ClientGenerator) error {
+	if len(vm.awsConfig.NodeLabelsToTags) == 0 || vm.runningInstance == nil {
+		return nil
+	}
+
+	node, err := c.GetNode(vm.NodeName)
+
+	if err != nil {
+		return err
+	}
+
+	tags := make(map[string]string)
+
+	for _, label := range vm.awsConfig.NodeLabelsToTags {
+		if value, found := node.Labels[label]; found {
+			tags[label] = value
+		}
+	}
+
+	return vm.runningInstance.TagResources(tags)
+}
+
+// WaitSSHReady method test the node accept commands, over SSH or SSM
 func (vm *AutoScalerServerNode) WaitSSHReady(nodename, address string) error {
+	executor := vm.remoteExecutor(address, 1)
+
 	return utils.PollImmediate(time.Second, time.Duration(vm.serverConfig.SSH.WaitSshReadyInSeconds)*time.Second, func() (bool, error) {
+		if ready, err := executor.ready(); err != nil || !ready {
+			return false, err
+		}
+
 		// Set hostname
-		if _, err := utils.Sudo(vm.serverConfig.SSH, address, time.Second, fmt.Sprintf("hostnamectl set-hostname %s", nodename)); err != nil {
-			if strings.HasSuffix(err.Error(), "connection refused") || strings.HasSuffix(err.Error(), "i/o timeout") {
+		if _, err := executor.sudo(fmt.Sprintf("hostnamectl set-hostname %s", nodename)); err != nil {
+			if executor.unreachable(err) {
 				return false, nil
 			}
 
@@ -318,7 +345,7 @@ func (vm *AutoScalerServerNode) WaitSSHReady(nodename, address string) error {
 		// Node name and instance name could be differ when using AWS cloud provider
 		if vm.serverConfig.CloudProvider == "aws" {
 
-			if nodeName, err := utils.Sudo(vm.serverConfig.SSH, address, 1, "curl -s http://169.254.169.254/latest/meta-data/local-hostname"); err == nil {
+			if nodeName, err := executor.sudo(metadataCommand("local-hostname", "$("+metadataTokenCommand+")")); err == nil {
 				vm.NodeName = nodeName
 
 				glog.Debugf("Launch VM:%s set to nodeName: %s", nodename, nodeName)
@@ -343,12 +370,18 @@ func (vm *AutoScalerServerNode) registerDNS(address string) error {
 
 	aws := vm.awsConfig
 
-	if len(aws.Network.ZoneID) > 0 {
+	if provider := aws.GetDNSProvider(); provider != nil {
 		hostname := fmt.Sprintf("%s.%s", vm.InstanceName, aws.Network.PrivateZoneName)
 
-		glog.Infof("Register route53 entry for instance %s, node group: %s, hostname: %s with IP:%s", vm.InstanceName, vm.NodeGroupID, hostname, address)
+		glog.Infof("Register DNS entry for instance %s, node group: %s, hostname: %s with IP:%s", vm.InstanceName, vm.NodeGroupID, hostname, address)
+
+		if err = provider.Register(hostname, address, vm.serverConfig.SSH.TestMode); err == nil && vm.runningInstance.AddressIPv6 != nil {
+			addressIPv6 := *vm.runningInstance.AddressIPv6
+
+			glog.Infof("Register DNS entry for instance %s, node group: %s, hostname: %s with IPv6:%s", vm.InstanceName, vm.NodeGroupID, hostname, addressIPv6)
 
-		err = vm.runningInstance.RegisterDNS(aws, hostname, address, vm.serverConfig.SSH.TestMode)
+			err = provider.Register(hostname, addressIPv6, vm.serverConfig.SSH.TestMode)
+		}
 	}
 
 	return err
@@ -359,17 +392,39 @@ func (vm *AutoScalerServerNode) unregisterDNS(address string) error {
 
 	aws := vm.awsConfig
 
-	if len(aws.Network.ZoneID) > 0 {
+	if provider := aws.GetDNSProvider(); provider != nil {
 		hostname := fmt.Sprintf("%s.%s", vm.InstanceName, aws.Network.PrivateZoneName)
 
-		glog.Infof("Unregister route53 entry for instance %s, node group: %s, hostname: %s with IP:%s", vm.InstanceName, vm.NodeGroupID, hostname, address)
+		glog.Infof("Unregister DNS entry for instance %s, node group: %s, hostname: %s with IP:%s", vm.InstanceName, vm.NodeGroupID, hostname, address)
 
-		err = vm.runningInstance.UnRegisterDNS(aws, hostname, false)
+		err = provider.Unregister(hostname, false)
 	}
 
 	return err
 }
 
+// isDualStack return true if the node request an IPv6 address
+func (vm *AutoScalerServerNode) isDualStack() bool {
+	if vm.desiredENI != nil && len(vm.desiredENI.IPv6Address) > 0 {
+		return true
+	}
+
+	return vm.awsConfig.Network.IsDualStack()
+}
+
+const (
+	// metadataEndpoint instance metadata service
+	metadataEndpoint = "http://169.254.169.254/latest"
+
+	// metadataTokenCommand request an IMDSv2 session token
+	metadataTokenCommand = "curl -sf -X PUT -H \"X-aws-ec2-metadata-token-ttl-seconds: 300\" " + metadataEndpoint + "/api/token"
+)
+
+// metadataCommand return the command reading the instance metadata path with the IMDSv2 session token
+func metadataCommand(path, token string) string {
+	return fmt.Sprintf("curl -sf -H \"X-aws-ec2-metadata-token: %s\" %s/meta-data/%s", token, metadataEndpoint, path)
+}
+
 func (vm *AutoScalerServerNode) kubeletDefault() *string {
 	var maxPods = vm.serverConfig.MaxPods
 	var kubeletExtraArgs string
@@ -383,23 +438,142 @@ func (vm *AutoScalerServerNode) kubeletDefault() *string {
 		cloudProvider = fmt.Sprintf("--cloud-provider=%s", vm.serverConfig.CloudProvider)
 	}
 
-	kubeletExtraArgs = fmt.Sprintf("KUBELET_EXTRA_ARGS=\\\"$KUBELET_EXTRA_ARGS --max-pods=%d --node-ip=$LOCAL_IP --provider-id=aws://$ZONEID/$INSTANCEID %s\\\"", maxPods, cloudProvider)
+	kubeletExtraArgs = fmt.Sprintf("KUBELET_EXTRA_ARGS=\\\"$KUBELET_EXTRA_ARGS --max-pods=%d --node-ip=$NODE_IP --provider-id=aws://$ZONEID/$INSTANCEID %s\\\"", maxPods, cloudProvider)
 
 	kubeletDefault := []string{
 		"#!/bin/bash",
 		"source /etc/default/kubelet",
-		"INSTANCEID=$(curl http://169.254.169.254/latest/meta-data/instance-id)",
-		"ZONEID=$(curl http://169.254.169.254/latest/meta-data/placement/availability-zone)",
-		"LOCAL_IP=$(curl http://169.254.169.254/latest/meta-data/local-ipv4)",
-		"echo \"" + kubeletExtraArgs + "\" > /etc/default/kubelet",
-		"systemctl restart kubelet",
+		// IMDSv2, session token required when http tokens are required
+		"TOKEN=$(" + metadataTokenCommand + ")",
+		"INSTANCEID=$(" + metadataCommand("instance-id", "$TOKEN") + ")",
+		"ZONEID=$(" + metadataCommand("placement/availability-zone", "$TOKEN") + ")",
+		"LOCAL_IP=$(" + metadataCommand("local-ipv4", "$TOKEN") + ")",
+		"NODE_IP=$LOCAL_IP",
 	}
 
+	// Dual stack, kubelet node-ip declare both families
+	if vm.isDualStack() {
+		kubeletDefault = append(kubeletDefault,
+			"MAC=$("+metadataCommand("mac", "$TOKEN")+")",
+			"LOCAL_IPV6=$("+metadataCommand("network/interfaces/macs/$MAC/ipv6s", "$TOKEN")+" | head -n 1)",
+			"[ -n \"$LOCAL_IPV6\" ] && NODE_IP=$LOCAL_IP,$LOCAL_IPV6")
+	}
+
+	kubeletDefault = append(kubeletDefault,
+		"echo \""+kubeletExtraArgs+"\" > /etc/default/kubelet",
+		"systemctl restart kubelet")
+
 	result := base64.StdEncoding.EncodeToString([]byte(strings.Join(kubeletDefault, "\n")))
 
 	return &result
 }
 
+// getSpotOptions return spot options declared by machine type or by node group
+func (vm *AutoScalerServerNode) getSpotOptions(instanceType string) *aws.SpotOptions {
+	if machine, found := vm.serverConfig.Machines[instanceType]; found && machine.Spot != nil {
+		return machine.Spot
+	}
+
+	return vm.awsConfig.Spot
+}
+
+// getBlockDevices return volumes declared by machine type or by node group
+func (vm *AutoScalerServerNode) getBlockDevices(instanceType string) []aws.BlockDevice {
+	if machine, found := vm.serverConfig.Machines[instanceType]; found && len(machine.BlockDevices) > 0 {
+		return machine.BlockDevices
+	}
+
+	return vm.awsConfig.BlockDevices
+}
+
+// getLaunchCandidates return the ordered instance types and zones to try when launching the VM.
+// The node instance type in any zone is tried first
+func (vm *AutoScalerServerNode) getLaunchCandidates() []launchCandidate {
+	instanceTypes := []string{vm.InstanceType}
+	zones := []string{""}
+
+	if fallback := vm.awsConfig.Fallback; fallback != nil {
+		for _, instanceType := range fallback.InstanceTypes {
+			if _, found := vm.serverConfig.Machines[instanceType]; !found {
+				glog.Warnf(constantes.WarnFallbackMachineTypeNotFound, instanceType, vm.NodeGroupID)
+			} else if instanceType != vm.InstanceType {
+				instanceTypes = append(instanceTypes, instanceType)
+			}
+		}
+
+		// Zone can't be changed if the subnet or the ENI is forced
+		if vm.desiredENI == nil || (len(vm.desiredENI.SubnetID) == 0 && len(vm.desiredENI.NetworkInterfaceID) == 0) {
+			zones = append(zones, fallback.Zones...)
+		}
+	}
+
+	candidates := make([]launchCandidate, 0, len(instanceTypes)*len(zones))
+
+	for _, instanceType := range instanceTypes {
+		for _, zone := range zones {
+			candidates = append(candidates, launchCandidate{
+				instanceType: instanceType,
+				zone:         zone,
+			})
+		}
+	}
+
+	return candidates
+}
+
+// useInstanceType update the node with the characteristics of the instance type really launched
+func (vm *AutoScalerServerNode) useInstanceType(instanceType string) {
+	if instanceType != vm.InstanceType {
+		glog.Infof("VM:%s launched with fallback instance type:%s instead of:%s", vm.InstanceName, instanceType, vm.InstanceType)
+	}
+
+	vm.InstanceType = instanceType
+
+	if machine, found := vm.serverConfig.Machines[instanceType]; found {
+		vm.CPU = machine.Vcpu
+		vm.Memory = machine.Memory
+	}
+}
+
+// createInstance launch the EC2 instance, the next candidate is tried on capacity error
+func (vm *AutoScalerServerNode) createInstance() (*aws.Ec2Instance, error) {
+	var err error
+	var instance *aws.Ec2Instance
+
+	userData := vm.kubeletDefault()
+
+	for _, candidate := range vm.getLaunchCandidates() {
+		create := &aws.CreateInput{
+			NodeIndex:    vm.NodeIndex,
+			NodeGroup:    vm.NodeGroupID,
+			InstanceType: candidate.instanceType,
+			Zone:         candidate.zone,
+			DiskType:     vm.DiskType,
+			DiskSize:     vm.DiskSize,
+			BlockDevices: vm.getBlockDevices(candidate.instanceType),
+			UserData:     userData,
+			DesiredENI:   vm.desiredENI,
+			Spot:         vm.getSpotOptions(candidate.instanceType),
+		}
+
+		if machine, found := vm.serverConfig.Machines[candidate.instanceType]; found {
+			create.Architectures = machine.Architectures
+		}
+
+		if instance, err = vm.awsConfig.Create(vm.InstanceName, create); err == nil {
+			vm.useInstanceType(candidate.instanceType)
+
+			return instance, nil
+		} else if !aws.IsCapacityError(err) {
+			return nil, err
+		}
+
+		glog.Warnf(constantes.WarnLaunchVMInsufficientCapacity, vm.InstanceName, candidate.instanceType, candidate.zone, err)
+	}
+
+	return nil, err
+}
+
 func (vm *AutoScalerServerNode) launchVM(c types.ClientGenerator, nodeLabels, systemLabels types.KubernetesLabel) error {
 	glog.Debugf("AutoScalerNode::launchVM, node:%s", vm.InstanceName)
 
@@ -415,7 +589,7 @@ func (vm *AutoScalerServerNode) launchVM(c types.ClientGenerator, nodeLabels, sy
 		return fmt.Errorf(constantes.ErrVMAlreadyCreated, vm.NodeName)
 	}
 
-	if aws.Exists(vm.NodeName) {
+	if aws.Exists(vm.NodeGroupID, vm.NodeName) {
 		glog.Warnf(constantes.ErrVMAlreadyExists, vm.NodeName)
 		return fmt.Errorf(constantes.ErrVMAlreadyExists, vm.NodeName)
 	}
@@ -426,7 +600,7 @@ func (vm *AutoScalerServerNode) launchVM(c types.ClientGenerator, nodeLabels, sy
 
 		err = fmt.Errorf(constantes.ErrVMNotProvisionnedByMe, vm.InstanceName)
 
-	} else if vm.runningInstance, err = aws.Create(vm.NodeIndex, vm.NodeGroupID, vm.InstanceName, vm.InstanceType, vm.DiskType, vm.DiskSize, vm.kubeletDefault(), vm.desiredENI); err != nil {
+	} else if vm.runningInstance, err = vm.createInstance(); err != nil {
 
 		err = fmt.Errorf(constantes.ErrUnableToLaunchVM, vm.InstanceName, err)
 
@@ -470,8 +644,19 @@ func (vm *AutoScalerServerNode) launchVM(c types.ClientGenerator, nodeLabels, sy
 
 		err = fmt.Errorf(constantes.ErrNodeIsNotReady, vm.InstanceName)
 
-	} else {
-		err = vm.setNodeLabels(c, nodeLabels, systemLabels)
+	} else if err = vm.runningInstance.RegisterTargets(); err != nil {
+
+		err = fmt.Errorf(constantes.ErrRegisterTargetsVMFailed, vm.InstanceName, err)
+
+	} else if err = vm.setNodeLabels(c, nodeLabels, systemLabels); err == nil {
+		// Not fatal, tags are only used for cost allocation
+		if e := vm.copyNodeLabelsToTags(c); e != nil {
+			glog.Errorf(constantes.ErrUnableToCopyLabelsToTags, vm.NodeName, e)
+		}
+	}
+
+	if vm.runningInstance != nil {
+		vm.SpotInstance = vm.runningInstance.Spot
 	}
 
 	if err == nil {
@@ -556,6 +741,10 @@ func (vm *AutoScalerServerNode) stopVM(c types.ClientGenerator) error {
 
 		err = fmt.Errorf(constantes.ErrStopVMFailed, vm.InstanceName, err)
 
+	} else if vm.runningInstance.Spot {
+
+		err = fmt.Errorf(constantes.ErrStopVMFailed, vm.InstanceName, "spot instance can't be stopped")
+
 	} else if state == AutoScalerServerNodeStateRunning {
 
 		if err = c.CordonNode(vm.NodeName); err != nil {
@@ -596,6 +785,12 @@ func (vm *AutoScalerServerNode) deleteVM(c types.ClientGenerator) error {
 			if err = vm.unregisterDNS(status.Address); err != nil {
 				glog.Warnf("unable to unregister DNS entry, reason: %v", err)
 			}
+
+			// Stop sending new connections before draining pods, not fatal, terminated targets are deregistered by aws
+			if err = vm.runningInstance.DeregisterTargets(); err != nil {
+				glog.Errorf(constantes.ErrUnableToDeregisterTargets, vm.InstanceName, err)
+			}
+
 			if status.Powered {
 				// Delete kubernetes node only is alive
 				if _, err = c.GetNode(vm.NodeName); err == nil {
@@ -612,7 +807,12 @@ func (vm *AutoScalerServerNode) deleteVM(c types.ClientGenerator) error {
 					}
 				}
 
-				if err = vm.runningInstance.PowerOff(); err != nil {
+				// One time spot instance can't be stopped
+				if vm.runningInstance.Spot {
+					if err = vm.runningInstance.Delete(); err != nil {
+						err = fmt.Errorf(constantes.ErrDeleteVMFailed, vm.InstanceName, err)
+					}
+				} else if err = vm.runningInstance.PowerOff(); err != nil {
 					err = fmt.Errorf(constantes.ErrStopVMFailed, vm.InstanceName, err)
 				} else {
 					vm.State = AutoScalerServerNodeStateStopped
@@ -658,6 +858,7 @@ func (vm *AutoScalerServerNode) statusVM() (AutoScalerServerNodeState, error) {
 
 	if status != nil {
 		vm.IPAddress = status.Address
+		vm.IPv6Address = status.AddressIPv6
 
 		if status.Powered {
 			vm.State = AutoScalerServerNodeStateRunning
@@ -66,12 +66,15 @@ type AutoScalerServerNodeGroup struct {
 	LastCreatedNodeIndex       int                              `json:"node-index"`
 	RunningNodes               map[int]ServerNodeState          `json:"running-nodes-state"`
 	pendingNodes               map[string]*AutoScalerServerNode
+	warmNodes                  map[string]*AutoScalerServerNode
+	fillingWarmNodes           map[string]*AutoScalerServerNode
 	pendingNodesWG             sync.WaitGroup
 	numOfControlPlanes         int
 	numOfExternalNodes         int
 	numOfProvisionnedNodes     int
 	numOfManagedNodes          int
 	configuration              *types.AutoScalerServerConfig
+	instanceCache              *aws.InstanceCache
 }
 
 func CreateLabelOrAnnotation(values []string) types.KubernetesLabel {
@@ -110,7 +113,10 @@ func (g *AutoScalerServerNodeGroup) cleanup(c types.ClientGenerator) error {
 
 	var lastError error
 
+	// Pending warm pool launches read the status in background
+	g.Lock()
 	g.Status = NodegroupDeleting
+	g.Unlock()
 
 	g.pendingNodesWG.Wait()
 
@@ -129,6 +135,8 @@ func (g *AutoScalerServerNodeGroup) cleanup(c types.ClientGenerator) error {
 		}
 	}
 
+	g.destroyWarmNodes(c)
+
 	g.RunningNodes = make(map[int]ServerNodeState)
 	g.Nodes = make(map[string]*AutoScalerServerNode)
 	g.pendingNodes = make(map[string]*AutoScalerServerNode)
@@ -166,20 +174,62 @@ func (g *AutoScalerServerNodeGroup) setNodeGroupSize(c types.ClientGenerator, ne
 			return g.deleteNodes(c, delta)
 		}
 	} else if delta > 0 {
-		if prepareOnly {
-			return g.prepareNodes(c, delta)
-		} else {
-			return g.addNodes(c, delta)
-		}
+		return g.growNodes(c, delta, prepareOnly)
 	}
 
 	return []*AutoScalerServerNode{}, nil
 }
 
+func (g *AutoScalerServerNodeGroup) growNodes(c types.ClientGenerator, delta int, prepareOnly bool) ([]*AutoScalerServerNode, error) {
+	// Fail fast while the aws api is unhealthy
+	if err := g.configuration.GetAwsConfiguration(g.NodeGroupIdentifier).GetCircuitBreaker().Check(); err != nil {
+		glog.Errorf(constantes.ErrUnableToIncreaseSize, g.NodeGroupIdentifier, err)
+
+		return nil, err
+	}
+
+	if prepareOnly {
+		return g.prepareNodes(c, delta)
+	} else {
+		return g.addNodes(c, delta)
+	}
+}
+
+// increaseSize add delta nodes if the node group doesn't exceed its max size,
+// the size is checked under the lock held by the resize
+func (g *AutoScalerServerNodeGroup) increaseSize(c types.ClientGenerator, delta int) ([]*AutoScalerServerNode, error) {
+	glog.Debugf("AutoScalerServerNodeGroup::increaseSize, nodeGroupID:%s", g.NodeGroupIdentifier)
+
+	g.Lock()
+	defer g.Unlock()
+
+	if newSize := g.targetSize() + delta; newSize > g.MaxNodeSize {
+		return nil, fmt.Errorf(constantes.ErrIncreaseSizeTooLarge, newSize, g.MaxNodeSize)
+	}
+
+	return g.growNodes(c, delta, false)
+}
+
+// getInstanceCache return the instance cache shared by the nodes of the group, created by setConfiguration
+func (g *AutoScalerServerNodeGroup) getInstanceCache() *aws.InstanceCache {
+	return g.instanceCache
+}
+
 func (g *AutoScalerServerNodeGroup) refresh() {
 	glog.Debugf("AutoScalerServerNodeGroup::refresh, nodeGroupID:%s", g.NodeGroupIdentifier)
 
+	instanceCache := g.getInstanceCache()
+
+	// One describe for all nodes, status fallback to describe each node on error
+	if err := instanceCache.Refresh(); err != nil {
+		glog.Errorf("refresh instances cache return an error: %v", err)
+	}
+
 	for _, node := range g.AllNodes() {
+		if node.runningInstance != nil {
+			node.runningInstance.SetInstanceCache(instanceCache)
+		}
+
 		if _, err := node.statusVM(); err != nil {
 			glog.Infof("status VM return an error: %v", err)
 		}
@@ -273,12 +323,13 @@ func (g *AutoScalerServerNodeGroup) addManagedNode(crd *v1alpha1.ManagedNode) (*
 
 		if crd.Spec.ENI != nil {
 			eni := crd.Spec.ENI
-			if len(eni.SubnetID)+len(eni.SecurityGroupID) > 0 {
+			if len(eni.SubnetID)+len(eni.SecurityGroupID)+len(eni.IPv6Address) > 0 {
 				desiredENI = &aws.UserDefinedNetworkInterface{
 					NetworkInterfaceID: eni.NetworkInterfaceID,
 					SubnetID:           eni.SubnetID,
 					SecurityGroupID:    eni.SecurityGroupID,
 					PrivateAddress:     eni.PrivateAddress,
+					IPv6Address:        eni.IPv6Address,
 					PublicIP:           eni.PublicIP,
 				}
 			}
@@ -327,19 +378,59 @@ func (g *AutoScalerServerNodeGroup) addManagedNode(crd *v1alpha1.ManagedNode) (*
 	}
 }
 
-func (g *AutoScalerServerNodeGroup) prepareNodes(c types.ClientGenerator, delta int) ([]*AutoScalerServerNode, error) {
-	tempNodes := make([]*AutoScalerServerNode, 0, delta)
+// newAutoscaledNode return a not created node provisionned by autoscaler
+func (g *AutoScalerServerNodeGroup) newAutoscaledNode(nodeName string, nodeIndex int, awsConfig *aws.Configuration) *AutoScalerServerNode {
 	annoteMaster := ""
 
 	if g.configuration.UseK3S != nil && *g.configuration.UseK3S {
 		annoteMaster = "true"
 	}
 
+	extraAnnotations := types.KubernetesLabel{}
+	extraLabels := types.KubernetesLabel{
+		constantes.NodeLabelWorkerRole: annoteMaster,
+		"worker":                       "true",
+	}
+
+	return &AutoScalerServerNode{
+		NodeGroupID:      g.NodeGroupIdentifier,
+		InstanceName:     nodeName,
+		NodeName:         nodeName,
+		NodeIndex:        nodeIndex,
+		InstanceType:     g.InstanceType,
+		DiskType:         g.DiskType,
+		DiskSize:         g.DiskSize,
+		NodeType:         AutoScalerServerNodeAutoscaled,
+		ExtraAnnotations: extraAnnotations,
+		ExtraLabels:      extraLabels,
+		ControlPlaneNode: false,
+		AllowDeployment:  true,
+		awsConfig:        awsConfig,
+		serverConfig:     g.configuration,
+	}
+}
+
+func (g *AutoScalerServerNodeGroup) prepareNodes(c types.ClientGenerator, delta int) ([]*AutoScalerServerNode, error) {
+	tempNodes := make([]*AutoScalerServerNode, 0, delta)
+
 	if g.Status != NodegroupCreated {
 		glog.Debugf("AutoScalerServerNodeGroup::addNodes, nodeGroupID:%s -> g.status != nodegroupCreated", g.NodeGroupIdentifier)
 		return []*AutoScalerServerNode{}, fmt.Errorf(constantes.ErrNodeGroupNotFound, g.NodeGroupIdentifier)
 	}
 
+	// Start warm nodes before creating new instances
+	for _, node := range g.takeWarmNodes(delta) {
+		tempNodes = append(tempNodes, node)
+
+		g.pendingNodes[node.InstanceName] = node
+
+		delta--
+	}
+
+	if delta == 0 {
+		return tempNodes, nil
+	}
+
 	for {
 		nodeName, nodeIndex := g.nodeName(g.findNextNodeIndex(false), false, false)
 
@@ -347,28 +438,7 @@ func (g *AutoScalerServerNodeGroup) prepareNodes(c types.ClientGenerator, delta
 
 			g.RunningNodes[nodeIndex] = ServerNodeStateCreating
 
-			extraAnnotations := types.KubernetesLabel{}
-			extraLabels := types.KubernetesLabel{
-				constantes.NodeLabelWorkerRole: annoteMaster,
-				"worker":                       "true",
-			}
-
-			node := &AutoScalerServerNode{
-				NodeGroupID:      g.NodeGroupIdentifier,
-				InstanceName:     nodeName,
-				NodeName:         nodeName,
-				NodeIndex:        nodeIndex,
-				InstanceType:     g.InstanceType,
-				DiskType:         g.DiskType,
-				DiskSize:         g.DiskSize,
-				NodeType:         AutoScalerServerNodeAutoscaled,
-				ExtraAnnotations: extraAnnotations,
-				ExtraLabels:      extraLabels,
-				ControlPlaneNode: false,
-				AllowDeployment:  true,
-				awsConfig:        awsConfig,
-				serverConfig:     g.configuration,
-			}
+			node := g.newAutoscaledNode(nodeName, nodeIndex, awsConfig)
 
 			tempNodes = append(tempNodes, node)
 
@@ -418,7 +488,13 @@ func (g *AutoScalerServerNodeGroup) createNodes(c types.ClientGenerator, nodes [
 			return fmt.Errorf(constantes.ErrUnableToLaunchVMNodeGroupNotReady, node.InstanceName)
 		}
 
-		if err = node.launchVM(c, g.NodeLabels, g.SystemLabels); err != nil {
+		if node.State == AutoScalerServerNodeStateStopped {
+			err = g.startWarmNode(c, node)
+		} else {
+			err = node.launchVM(c, g.NodeLabels, g.SystemLabels)
+		}
+
+		if err != nil {
 			glog.Errorf(constantes.ErrUnableToLaunchVM, node.InstanceName, err)
 
 			node.cleanOnLaunchError(c, err)
@@ -612,6 +688,7 @@ func (g *AutoScalerServerNodeGroup) autoDiscoveryNodes(client types.ClientGenera
 
 	g.Nodes = make(map[string]*AutoScalerServerNode)
 	g.pendingNodes = make(map[string]*AutoScalerServerNode)
+	g.warmNodes = make(map[string]*AutoScalerServerNode)
 	g.RunningNodes = make(map[int]ServerNodeState)
 	g.LastCreatedNodeIndex = 0
 	g.numOfExternalNodes = 0
@@ -620,6 +697,11 @@ func (g *AutoScalerServerNodeGroup) autoDiscoveryNodes(client types.ClientGenera
 	g.numOfControlPlanes = 0
 
 	awsConfig := g.configuration.GetAwsConfiguration(g.NodeGroupIdentifier)
+	instanceCache := g.getInstanceCache()
+
+	if err = instanceCache.Refresh(); err != nil {
+		glog.Errorf("refresh instances cache return an error: %v", err)
+	}
 
 	for _, nodeInfo := range nodeInfos.Items {
 		if nodegroupName, found := nodeInfo.Annotations[constantes.AnnotationNodeGroupName]; found {
@@ -675,7 +757,7 @@ func (g *AutoScalerServerNodeGroup) autoDiscoveryNodes(client types.ClientGenera
 					}
 
 					// Node name and instance name could be differ when using AWS cloud provider
-					if ec2Instance, err = aws.GetEc2Instance(awsConfig, instanceName); err == nil {
+					if ec2Instance, err = instanceCache.GetEc2Instance(instanceName); err == nil {
 						if node == nil {
 							glog.Infof("Add node:%s with IP:%s to nodegroup:%s", instanceName, runningIP, g.NodeGroupIdentifier)
 
@@ -692,6 +774,7 @@ func (g *AutoScalerServerNodeGroup) autoDiscoveryNodes(client types.ClientGenera
 								CPU:              int(nodeInfo.Status.Capacity.Cpu().Value()),
 								Memory:           int(nodeInfo.Status.Capacity.Memory().Value() / (1024 * 1024)),
 								DiskSize:         int(nodeInfo.Status.Capacity.Storage().Value() / (1024 * 1024)),
+								SpotInstance:     ec2Instance.Spot,
 								awsConfig:        awsConfig,
 								runningInstance:  ec2Instance,
 								IPAddress:        runningIP,
@@ -706,6 +789,8 @@ func (g *AutoScalerServerNodeGroup) autoDiscoveryNodes(client types.ClientGenera
 								constantes.AnnotationNodeAutoProvisionned: strconv.FormatBool(autoProvisionned),
 								constantes.AnnotationNodeManaged:          strconv.FormatBool(managedNode),
 								constantes.AnnotationNodeIndex:            strconv.Itoa(node.NodeIndex),
+								constantes.AnnotationInstanceLifecycle:    ec2Instance.Lifecycle(),
+								constantes.AnnotationImageID:              ec2Instance.AMI(),
 							})
 
 							if err != nil {
@@ -721,30 +806,40 @@ func (g *AutoScalerServerNodeGroup) autoDiscoveryNodes(client types.ClientGenera
 							}
 						} else {
 							node.runningInstance = ec2Instance
+							node.SpotInstance = ec2Instance.Spot
 							node.serverConfig = g.configuration
 							node.awsConfig = awsConfig
 
 							glog.Infof("Attach existing node:%s with IP:%s to nodegroup:%s", instanceName, runningIP, g.NodeGroupIdentifier)
 						}
 
-						g.Nodes[nodeInfo.Name] = node
 						g.RunningNodes[lastNodeIndex] = ServerNodeStateRunning
 
-						if controlPlane {
-							if managedNode {
+						warm, _ := strconv.ParseBool(nodeInfo.Annotations[constantes.AnnotationWarmPool])
+
+						if warm && autoProvisionned {
+							glog.Infof("Node:%s is in warm pool of nodegroup:%s", instanceName, g.NodeGroupIdentifier)
+
+							g.warmNodes[instanceName] = node
+						} else {
+							g.Nodes[nodeInfo.Name] = node
+
+							if controlPlane {
+								if managedNode {
+									g.numOfManagedNodes++
+								} else {
+									g.numOfExternalNodes++
+								}
+
+								g.numOfControlPlanes++
+
+							} else if autoProvisionned {
+								g.numOfProvisionnedNodes++
+							} else if managedNode {
 								g.numOfManagedNodes++
 							} else {
 								g.numOfExternalNodes++
 							}
-
-							g.numOfControlPlanes++
-
-						} else if autoProvisionned {
-							g.numOfProvisionnedNodes++
-						} else if managedNode {
-							g.numOfManagedNodes++
-						} else {
-							g.numOfExternalNodes++
 						}
 
 						lastNodeIndex++
@@ -766,10 +861,22 @@ func (g *AutoScalerServerNodeGroup) autoDiscoveryNodes(client types.ClientGenera
 func (g *AutoScalerServerNodeGroup) deleteNode(c types.ClientGenerator, node *AutoScalerServerNode) error {
 	var err error
 
+	// Keep the node stopped in the warm pool instead of terminating it
+	if g.returnToWarmPool(c, node) {
+		return nil
+	}
+
 	if err = node.deleteVM(c); err != nil {
 		glog.Errorf(constantes.ErrUnableToDeleteVM, node.InstanceName, err)
 	}
 
+	g.forgetNode(node)
+
+	return err
+}
+
+// forgetNode remove the deleted node from the node group
+func (g *AutoScalerServerNodeGroup) forgetNode(node *AutoScalerServerNode) {
 	g.RunningNodes[node.NodeIndex] = ServerNodeStateDeleted
 	g.removeNamedNode(node.InstanceName)
 
@@ -778,8 +885,6 @@ func (g *AutoScalerServerNodeGroup) deleteNode(c types.ClientGenerator, node *Au
 	} else {
 		g.numOfManagedNodes--
 	}
-
-	return err
 }
 
 func (g *AutoScalerServerNodeGroup) deleteNodeByName(c types.ClientGenerator, nodeName string) error {
@@ -797,6 +902,7 @@ func (g *AutoScalerServerNodeGroup) setConfiguration(config *types.AutoScalerSer
 	glog.Debugf("AutoScalerServerNodeGroup::setConfiguration, nodeGroupID:%s", g.NodeGroupIdentifier)
 
 	g.configuration = config
+	g.instanceCache = config.GetAwsConfiguration(g.NodeGroupIdentifier).GetInstanceCache(g.NodeGroupIdentifier)
 
 	for _, node := range g.AllNodes() {
 		node.setServerConfiguration(config)
@@ -835,7 +941,7 @@ func (g *AutoScalerServerNodeGroup) getManagedNodePrefix() string {
 
 func (g *AutoScalerServerNodeGroup) nodeName(vmIndex int, controlplane, managed bool) (string, int) {
 	var start int
-	config := g.configuration.GetAwsConfiguration(g.NodeGroupIdentifier)
+	instanceCache := g.getInstanceCache()
 
 	if controlplane {
 		start = 2
@@ -854,8 +960,8 @@ func (g *AutoScalerServerNodeGroup) nodeName(vmIndex int, controlplane, managed
 			nodeName = fmt.Sprintf("%s-%s-%02d", g.NodeGroupIdentifier, g.getProvisionnedNodePrefix(), index)
 		}
 
-		if found := g.findNamedNode(nodeName); found == nil {
-			if !config.Exists(nodeName) {
+		if found := g.findNamedNode(nodeName); found == nil && g.warmNodes[nodeName] == nil && g.fillingWarmNodes[nodeName] == nil {
+			if !instanceCache.Exists(nodeName) {
 				return nodeName, vmIndex
 			} else {
 				glog.Warnf(constantes.ErrVMAlreadyExists, nodeName)
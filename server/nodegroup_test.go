@@ -1,21 +1,27 @@
 package server
 
 import (
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/Fred78290/kubernetes-aws-autoscaler/aws"
+	"github.com/Fred78290/kubernetes-aws-autoscaler/aws/fake"
 	"github.com/Fred78290/kubernetes-aws-autoscaler/constantes"
 	managednodeClientset "github.com/Fred78290/kubernetes-aws-autoscaler/pkg/generated/clientset/versioned"
 	"github.com/Fred78290/kubernetes-aws-autoscaler/types"
 	"github.com/Fred78290/kubernetes-aws-autoscaler/utils"
 	awssdk "github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
 	"github.com/stretchr/testify/assert"
 	apiv1 "k8s.io/api/core/v1"
 	apiextension "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 )
@@ -23,6 +29,7 @@ import (
 type baseTest struct {
 	testConfig *aws.Configuration
 	t          *testing.T
+	events     []string
 }
 
 type nodegroupTest struct {
@@ -284,6 +291,12 @@ func (m *baseTest) WaitNodeToBeReady(nodeName string) error {
 	return nil
 }
 
+func (m *baseTest) RecordNodeEvent(nodeName, eventType, reason, message string) error {
+	m.events = append(m.events, fmt.Sprintf("%s/%s", nodeName, reason))
+
+	return nil
+}
+
 func (m *baseTest) newTestNode(name ...string) (*autoScalerServerNodeGroupTest, *AutoScalerServerNode, error) {
 	if ng, err := m.newTestNodeGroup(); err == nil {
 		vm := ng.createTestNode(name...)
@@ -318,11 +331,12 @@ func (m *baseTest) newTestNodeGroup() (*autoScalerServerNodeGroupTest, error) {
 				Nodes:                      make(map[string]*AutoScalerServerNode),
 				RunningNodes:               make(map[int]ServerNodeState),
 				pendingNodes:               make(map[string]*AutoScalerServerNode),
-				configuration:              config,
 				NodeLabels:                 config.NodeLabels,
 			},
 		}
 
+		ng.setConfiguration(config)
+
 		return ng, err
 	}
 
@@ -440,3 +454,747 @@ func TestNodeGroupGroup_deleteNode(t *testing.T) {
 func TestNodeGroupGroup_deleteNodeGroup(t *testing.T) {
 	createTestNodegroup(t).deleteNodeGroup()
 }
+
+func TestNodeGroup_createInstanceFallback(t *testing.T) {
+	awsConfig, clients := fake.NewConfiguration(testRegion)
+
+	awsConfig.Fallback = &aws.FallbackOptions{
+		InstanceTypes: []string{"t3a.large", "t3a.unknown"},
+		Zones:         []string{"us-east-1b"},
+	}
+
+	node := &AutoScalerServerNode{
+		NodeGroupID:  "fallback",
+		InstanceName: "fallback-vm-01",
+		NodeName:     "fallback-vm-01",
+		NodeIndex:    0,
+		InstanceType: "t3a.medium",
+		NodeType:     AutoScalerServerNodeAutoscaled,
+		awsConfig:    awsConfig,
+		serverConfig: &types.AutoScalerServerConfig{
+			Machines: map[string]*types.MachineCharacteristic{
+				"t3a.medium": {
+					Memory: 4096,
+					Vcpu:   2,
+				},
+				"t3a.large": {
+					Memory: 8192,
+					Vcpu:   2,
+				},
+			},
+		},
+	}
+
+	candidates := node.getLaunchCandidates()
+
+	assert.Equal(t, []launchCandidate{
+		{instanceType: "t3a.medium"},
+		{instanceType: "t3a.medium", zone: "us-east-1b"},
+		{instanceType: "t3a.large"},
+		{instanceType: "t3a.large", zone: "us-east-1b"},
+	}, candidates)
+
+	// No capacity for the node type, neither the first fallback candidate in default zone
+	clients.EC2.InsufficientCapacity["t3a.medium"] = true
+	clients.EC2.InsufficientCapacity["t3a.large/us-east-1a"] = true
+
+	if instance, err := node.createInstance(); assert.NoError(t, err) {
+		assert.Equal(t, "us-east-1b", *instance.Zone)
+		assert.Equal(t, "t3a.large", node.InstanceType)
+		assert.Equal(t, 8192, node.Memory)
+		assert.Equal(t, 2, node.CPU)
+	}
+}
+
+func TestNodeGroup_createInstanceWithoutCapacity(t *testing.T) {
+	awsConfig, clients := fake.NewConfiguration(testRegion)
+
+	node := &AutoScalerServerNode{
+		NodeGroupID:  "fallback",
+		InstanceName: "fallback-vm-02",
+		NodeName:     "fallback-vm-02",
+		InstanceType: "t3a.medium",
+		NodeType:     AutoScalerServerNodeAutoscaled,
+		awsConfig:    awsConfig,
+		serverConfig: &types.AutoScalerServerConfig{},
+	}
+
+	clients.EC2.InsufficientCapacity["t3a.medium"] = true
+
+	_, err := node.createInstance()
+
+	assert.True(t, aws.IsCapacityError(err))
+	assert.Empty(t, clients.EC2.Instances)
+}
+
+func TestNodeGroup_kubeletDefaultDualStack(t *testing.T) {
+	awsConfig, _ := fake.NewConfiguration(testRegion)
+
+	node := &AutoScalerServerNode{
+		NodeGroupID:  "dualstack",
+		InstanceName: "dualstack-vm-01",
+		awsConfig:    awsConfig,
+		serverConfig: &types.AutoScalerServerConfig{},
+	}
+
+	decode := func() string {
+		script, _ := base64.StdEncoding.DecodeString(*node.kubeletDefault())
+
+		return string(script)
+	}
+
+	script := decode()
+
+	assert.Contains(t, script, "--node-ip=$NODE_IP")
+	assert.NotContains(t, script, "LOCAL_IPV6")
+
+	awsConfig.Network.ENI[0].IPv6AddressCount = 1
+
+	script = decode()
+
+	assert.Contains(t, script, "ipv6s")
+	assert.Contains(t, script, "NODE_IP=$LOCAL_IP,$LOCAL_IPV6")
+}
+
+func TestNodeGroup_kubeletDefaultIMDSv2(t *testing.T) {
+	awsConfig, _ := fake.NewConfiguration(testRegion)
+
+	node := &AutoScalerServerNode{
+		NodeGroupID:  "imdsv2",
+		InstanceName: "imdsv2-vm-01",
+		awsConfig:    awsConfig,
+		serverConfig: &types.AutoScalerServerConfig{},
+	}
+
+	script, _ := base64.StdEncoding.DecodeString(*node.kubeletDefault())
+
+	// Session token fetched before any metadata read
+	lines := strings.Split(string(script), "\n")
+	tokenLine := -1
+
+	for index, line := range lines {
+		if strings.HasPrefix(line, "TOKEN=") {
+			tokenLine = index
+			assert.Contains(t, line, "-X PUT")
+			assert.Contains(t, line, "/latest/api/token")
+		} else if strings.Contains(line, "/meta-data/") {
+			assert.Greater(t, index, tokenLine, line)
+			assert.Contains(t, line, `-H "X-aws-ec2-metadata-token: $TOKEN"`)
+		}
+	}
+
+	assert.NotEqual(t, -1, tokenLine)
+}
+
+func TestServer_nodeAndPodPriceWithCatalog(t *testing.T) {
+	awsConfig, clients := fake.NewConfiguration(testRegion)
+
+	catalogFile := t.TempDir() + "/prices.json"
+	catalog := aws.PriceCatalog{
+		testRegion: {
+			"t3a.medium": {OnDemand: 0.04, Spot: 0.01},
+		},
+	}
+
+	assert.NoError(t, catalog.Save(catalogFile))
+
+	nodeGroup := &AutoScalerServerNodeGroup{
+		NodeGroupIdentifier: "pricing",
+		InstanceType:        "t3a.medium",
+		Nodes: map[string]*AutoScalerServerNode{
+			"pricing-vm-01": {
+				NodeName:     "pricing-vm-01",
+				InstanceName: "pricing-vm-01",
+				InstanceType: "t3a.medium",
+				SpotInstance: true,
+			},
+		},
+		pendingNodes: make(map[string]*AutoScalerServerNode),
+	}
+
+	s := &AutoScalerServerApp{
+		Groups: map[string]*AutoScalerServerNodeGroup{
+			"pricing": nodeGroup,
+		},
+		configuration: &types.AutoScalerServerConfig{
+			NodePrice:          1,
+			PodPrice:           1,
+			DefaultMachineType: "t3a.medium",
+			AwsInfos: map[string]*aws.Configuration{
+				"default": awsConfig,
+			},
+			Machines: map[string]*types.MachineCharacteristic{
+				"t3a.medium": {Vcpu: 2, Memory: 4096, SpotPrice: 0.02},
+				"m5.large":   {Vcpu: 2, Memory: 8192, Price: 0.1},
+			},
+		},
+	}
+
+	annotations := map[string]string{
+		constantes.AnnotationNodeGroupName: "pricing",
+		constantes.AnnotationInstanceName:  "pricing-vm-01",
+	}
+
+	start := time.Now()
+	end := start.Add(10 * time.Hour)
+
+	// Without pricing, legacy flat prices
+	assert.Equal(t, 1.0, s.nodePrice(annotations, start, end))
+	assert.Equal(t, 1.0, s.podPrice(&apiv1.Pod{}, start, end))
+
+	// Without pricing, spot price of the machine type for the time window
+	annotations[constantes.AnnotationInstanceLifecycle] = aws.InstanceLifecycleSpot
+	assert.InDelta(t, 0.2, s.nodePrice(annotations, start, end), 1e-9)
+	delete(annotations, constantes.AnnotationInstanceLifecycle)
+
+	s.configuration.Pricing = &aws.PricingOptions{
+		CatalogFile: catalogFile,
+	}
+
+	assert.NoError(t, s.createPricingProvider())
+
+	assert.InDelta(t, 0.4, s.nodePrice(annotations, start, end), 1e-9)
+
+	annotations[constantes.AnnotationInstanceLifecycle] = aws.InstanceLifecycleSpot
+	assert.InDelta(t, 0.1, s.nodePrice(annotations, start, end), 1e-9)
+
+	// Price from the machine declaration when missing in catalog
+	nodeGroup.InstanceType = "m5.large"
+	annotations[constantes.AnnotationInstanceName] = "unknown"
+	delete(annotations, constantes.AnnotationInstanceLifecycle)
+	assert.InDelta(t, 1.0, s.nodePrice(annotations, start, end), 1e-9)
+
+	// Pod requesting a quarter of the memory of its spot node
+	pod := &apiv1.Pod{
+		Spec: apiv1.PodSpec{
+			NodeName: "pricing-vm-01",
+			Containers: []apiv1.Container{
+				{
+					Resources: apiv1.ResourceRequirements{
+						Requests: apiv1.ResourceList{
+							apiv1.ResourceCPU:    resource.MustParse("250m"),
+							apiv1.ResourceMemory: resource.MustParse("1Gi"),
+						},
+					},
+				},
+			},
+		},
+	}
+
+	assert.InDelta(t, 0.025, s.podPrice(pod, start, end), 1e-9)
+
+	// Pending pod priced on the default machine type
+	pod.Spec.NodeName = ""
+	assert.InDelta(t, 0.1, s.podPrice(pod, start, end), 1e-9)
+
+	assert.Zero(t, clients.Pricing.GetProductsCalls)
+}
+
+func TestNodeGroup_warmPool(t *testing.T) {
+	awsConfig, clients := fake.NewConfiguration(testRegion)
+
+	awsConfig.WarmPool = &aws.WarmPoolOptions{
+		MaxSize: 1,
+	}
+
+	serverConfig := &types.AutoScalerServerConfig{
+		AwsInfos: map[string]*aws.Configuration{
+			"default": awsConfig,
+		},
+		Machines: map[string]*types.MachineCharacteristic{
+			"t3a.medium": {Vcpu: 2, Memory: 4096},
+		},
+	}
+
+	nodeGroup := &AutoScalerServerNodeGroup{
+		NodeGroupIdentifier: "warm",
+		InstanceType:        "t3a.medium",
+		Status:              NodegroupCreated,
+		MaxNodeSize:         5,
+		Nodes:               make(map[string]*AutoScalerServerNode),
+		RunningNodes:        make(map[int]ServerNodeState),
+		pendingNodes:        make(map[string]*AutoScalerServerNode),
+	}
+
+	nodeGroup.setConfiguration(serverConfig)
+
+	client := &baseTest{testConfig: awsConfig, t: t}
+
+	for index := 1; index <= 2; index++ {
+		nodeName, nodeIndex := nodeGroup.nodeName(index, false, false)
+		node := nodeGroup.newAutoscaledNode(nodeName, nodeIndex, awsConfig)
+
+		if instance, err := node.createInstance(); assert.NoError(t, err) {
+			node.runningInstance = instance
+			node.State = AutoScalerServerNodeStateRunning
+		}
+
+		nodeGroup.Nodes[nodeName] = node
+		nodeGroup.RunningNodes[nodeIndex] = ServerNodeStateRunning
+		nodeGroup.numOfProvisionnedNodes++
+	}
+
+	// First node return to the pool, the second is terminated because the pool is full
+	assert.NoError(t, nodeGroup.deleteNodeByName(client, "warm-autoscaled-01"))
+	assert.NoError(t, nodeGroup.deleteNodeByName(client, "warm-autoscaled-02"))
+
+	assert.Zero(t, nodeGroup.targetSize())
+	assert.Equal(t, 0, nodeGroup.numOfProvisionnedNodes)
+
+	if warmNodes := nodeGroup.WarmNodes(); assert.Len(t, warmNodes, 1) {
+		warmNode := warmNodes[0]
+
+		assert.Equal(t, "warm-autoscaled-01", warmNode.InstanceName)
+		assert.Equal(t, AutoScalerServerNodeState(AutoScalerServerNodeStateStopped), warmNode.State)
+		assert.Equal(t, ec2.InstanceStateNameStopped, *clients.EC2.Instances[*warmNode.runningInstance.InstanceID].State.Name)
+		assert.Equal(t, warmNode, nodeGroup.findWarmNode("warm-autoscaled-01"))
+	}
+
+	// Warm node is started first, then a new instance is created
+	if nodes, err := nodeGroup.prepareNodes(client, 2); assert.NoError(t, err) && assert.Len(t, nodes, 2) {
+		assert.Equal(t, "warm-autoscaled-01", nodes[0].InstanceName)
+		assert.Equal(t, AutoScalerServerNodeState(AutoScalerServerNodeStateStopped), nodes[0].State)
+		assert.NotEqual(t, "warm-autoscaled-01", nodes[1].InstanceName)
+		assert.Equal(t, AutoScalerServerNodeState(AutoScalerServerNodeStateNotCreated), nodes[1].State)
+	}
+
+	assert.Empty(t, nodeGroup.WarmNodes())
+	assert.Equal(t, 2, nodeGroup.targetSize())
+}
+
+func TestNodeGroup_prepareWarmNodes(t *testing.T) {
+	awsConfig, _ := fake.NewConfiguration(testRegion)
+
+	warmPool := &aws.WarmPoolOptions{
+		MinSize: 3,
+		MaxSize: 3,
+	}
+
+	awsConfig.WarmPool = warmPool
+
+	serverConfig := &types.AutoScalerServerConfig{
+		AwsInfos: map[string]*aws.Configuration{
+			"default": awsConfig,
+		},
+		Machines: map[string]*types.MachineCharacteristic{
+			"t3a.medium": {Vcpu: 2, Memory: 4096},
+		},
+	}
+
+	nodeGroup := &AutoScalerServerNodeGroup{
+		NodeGroupIdentifier: "warm",
+		InstanceType:        "t3a.medium",
+		Status:              NodegroupCreated,
+		MaxNodeSize:         3,
+		Nodes:               make(map[string]*AutoScalerServerNode),
+		RunningNodes:        make(map[int]ServerNodeState),
+		pendingNodes:        make(map[string]*AutoScalerServerNode),
+	}
+
+	nodeGroup.setConfiguration(serverConfig)
+
+	nodeName, nodeIndex := nodeGroup.nodeName(1, false, false)
+
+	nodeGroup.Nodes[nodeName] = nodeGroup.newAutoscaledNode(nodeName, nodeIndex, awsConfig)
+	nodeGroup.RunningNodes[nodeIndex] = ServerNodeStateRunning
+
+	// Spot instances can't be stopped
+	serverConfig.Machines["t3a.medium"].Spot = &aws.SpotOptions{Enabled: true}
+
+	assert.Empty(t, nodeGroup.prepareWarmNodes(warmPool))
+
+	serverConfig.Machines["t3a.medium"].Spot = nil
+
+	// Warm nodes count against MaxNodeSize
+	nodes := nodeGroup.prepareWarmNodes(warmPool)
+
+	if assert.Len(t, nodes, 2) {
+		assert.NotEqual(t, nodes[0].InstanceName, nodes[1].InstanceName)
+
+		for _, node := range nodes {
+			assert.NotEqual(t, nodeName, node.InstanceName)
+			assert.Equal(t, ServerNodeState(ServerNodeStateCreating), nodeGroup.RunningNodes[node.NodeIndex])
+		}
+	}
+
+	// Fill in progress
+	assert.Empty(t, nodeGroup.prepareWarmNodes(warmPool))
+
+	// Node group deleted before the launch, reserved nodes are released
+	nodeGroup.Lock()
+	nodeGroup.Status = NodegroupDeleting
+	nodeGroup.Unlock()
+
+	assert.Error(t, nodeGroup.launchWarmNodes(&baseTest{testConfig: awsConfig, t: t}, nodes))
+	assert.Empty(t, nodeGroup.fillingWarmNodes)
+
+	for _, node := range nodes {
+		assert.Equal(t, ServerNodeState(ServerNodeStateDeleted), nodeGroup.RunningNodes[node.NodeIndex])
+	}
+}
+
+func TestNodeGroup_collectOrphans(t *testing.T) {
+	awsConfig, clients := fake.NewConfiguration(testRegion)
+
+	awsConfig.ClusterName = "acme"
+	awsConfig.Network.ZoneID = "Z0123456789"
+	awsConfig.Network.PrivateZoneName = "aws.acme.com"
+
+	serverConfig := &types.AutoScalerServerConfig{
+		AwsInfos: map[string]*aws.Configuration{
+			"default": awsConfig,
+		},
+	}
+
+	nodeGroup := &AutoScalerServerNodeGroup{
+		NodeGroupIdentifier: "orphans",
+		InstanceType:        "t3a.medium",
+		Status:              NodegroupCreated,
+		MaxNodeSize:         5,
+		Nodes:               make(map[string]*AutoScalerServerNode),
+		RunningNodes:        make(map[int]ServerNodeState),
+		pendingNodes:        make(map[string]*AutoScalerServerNode),
+	}
+
+	nodeGroup.setConfiguration(serverConfig)
+
+	client := &baseTest{testConfig: awsConfig, t: t}
+	launchTime := time.Now().Add(-time.Hour)
+	instances := make(map[string]*aws.Ec2Instance)
+
+	for index := 1; index <= 5; index++ {
+		nodeName, nodeIndex := nodeGroup.nodeName(index, false, false)
+		node := nodeGroup.newAutoscaledNode(nodeName, nodeIndex, awsConfig)
+
+		if instance, err := node.createInstance(); assert.NoError(t, err) {
+			instances[nodeName] = instance
+
+			// Third instance is still in grace period
+			if index != 3 {
+				clients.EC2.Instances[*instance.InstanceID].LaunchTime = &launchTime
+			}
+
+			// Last instance belong to a same named node group of another cluster
+			if index == 4 {
+				for _, tag := range clients.EC2.Instances[*instance.InstanceID].Tags {
+					if *tag.Key == aws.ClusterTagKey("acme") {
+						tag.Key = awssdk.String(aws.ClusterTagKey("other"))
+					}
+				}
+			}
+		}
+
+		// Only the first instance is known
+		if index == 1 {
+			nodeGroup.Nodes[nodeName] = node
+		}
+
+		// Last instance is a warm node still launching
+		if index == 5 {
+			nodeGroup.fillingWarmNodes = map[string]*AutoScalerServerNode{
+				nodeName: node,
+			}
+		}
+	}
+
+	options := &types.OrphanCollectorOptions{
+		Enabled:    true,
+		ReportOnly: true,
+	}
+
+	orphan := instances["orphans-autoscaled-02"]
+
+	assert.NoError(t, awsConfig.GetDNSProvider().Register("orphans-autoscaled-02.aws.acme.com", "10.0.0.2", true))
+	assert.Len(t, clients.Route53.Zones[awsConfig.Network.ZoneID], 1)
+
+	if orphans, err := nodeGroup.collectOrphans(client, options); assert.NoError(t, err) {
+		assert.Equal(t, []string{"orphans-autoscaled-02"}, orphans)
+		assert.Equal(t, ec2.InstanceStateNameRunning, *clients.EC2.Instances[*orphan.InstanceID].State.Name)
+	}
+
+	options.ReportOnly = false
+
+	if orphans, err := nodeGroup.collectOrphans(client, options); assert.NoError(t, err) {
+		assert.Equal(t, []string{"orphans-autoscaled-02"}, orphans)
+		assert.Equal(t, ec2.InstanceStateNameTerminated, *clients.EC2.Instances[*orphan.InstanceID].State.Name)
+		assert.Empty(t, clients.Route53.Zones[awsConfig.Network.ZoneID])
+		assert.Equal(t, ec2.InstanceStateNameRunning, *clients.EC2.Instances[*instances["orphans-autoscaled-04"].InstanceID].State.Name)
+	}
+
+	// Terminated instance is no more an orphan
+	if orphans, err := nodeGroup.collectOrphans(client, options); assert.NoError(t, err) {
+		assert.Empty(t, orphans)
+		assert.Equal(t, ec2.InstanceStateNameRunning, *clients.EC2.Instances[*instances["orphans-autoscaled-05"].InstanceID].State.Name)
+	}
+
+	// Warm node launch is over without parking the node
+	delete(nodeGroup.fillingWarmNodes, "orphans-autoscaled-05")
+
+	if orphans, err := nodeGroup.collectOrphans(client, options); assert.NoError(t, err) {
+		assert.Equal(t, []string{"orphans-autoscaled-05"}, orphans)
+	}
+}
+
+func TestServer_handleInterruption(t *testing.T) {
+	awsConfig, clients := fake.NewConfiguration(testRegion)
+
+	queueURL := "https://sqs.us-east-1.amazonaws.com/123456789012/interruptions"
+
+	awsConfig.InterruptionQueue = &aws.InterruptionQueueOptions{
+		URL: queueURL,
+	}
+
+	serverConfig := &types.AutoScalerServerConfig{
+		AwsInfos: map[string]*aws.Configuration{
+			"default": awsConfig,
+		},
+	}
+
+	nodeGroup := &AutoScalerServerNodeGroup{
+		NodeGroupIdentifier: "spot",
+		InstanceType:        "t3a.medium",
+		Status:              NodegroupCreated,
+		MaxNodeSize:         5,
+		Nodes:               make(map[string]*AutoScalerServerNode),
+		RunningNodes:        make(map[int]ServerNodeState),
+		pendingNodes:        make(map[string]*AutoScalerServerNode),
+	}
+
+	nodeGroup.setConfiguration(serverConfig)
+
+	nodeName, nodeIndex := nodeGroup.nodeName(1, false, false)
+	node := nodeGroup.newAutoscaledNode(nodeName, nodeIndex, awsConfig)
+
+	if instance, err := node.createInstance(); assert.NoError(t, err) {
+		node.runningInstance = instance
+		node.State = AutoScalerServerNodeStateRunning
+	}
+
+	nodeGroup.Nodes[nodeName] = node
+
+	app := &AutoScalerServerApp{
+		kubeClient:    &baseTest{testConfig: awsConfig, t: t},
+		configuration: serverConfig,
+		Groups: map[string]*AutoScalerServerNodeGroup{
+			nodeGroup.NodeGroupIdentifier: nodeGroup,
+		},
+	}
+
+	event := `{"detail-type":"%s","source":"aws.ec2","time":"2023-05-01T10:00:00Z","detail":{"instance-id":"%s"}}`
+
+	clients.SQS.AddQueue(queueURL)
+	clients.SQS.PushMessage(queueURL, fmt.Sprintf(event, aws.RebalanceRecommendation, *node.runningInstance.InstanceID))
+	clients.SQS.PushMessage(queueURL, fmt.Sprintf(event, aws.SpotInterruptionWarning, *node.runningInstance.InstanceID))
+	clients.SQS.PushMessage(queueURL, fmt.Sprintf(event, aws.SpotInterruptionWarning, "i-unknown"))
+
+	if assert.NoError(t, app.pollInterruptionQueue(awsConfig)) {
+		assert.True(t, node.Interrupted)
+		assert.Empty(t, clients.SQS.Queues[queueURL])
+
+		// Event of an unknown instance is left to the visibility timeout
+		if assert.Len(t, clients.SQS.InFlight, 1) {
+			for _, message := range clients.SQS.InFlight {
+				assert.Contains(t, *message.Body, "i-unknown")
+			}
+		}
+	}
+}
+
+func TestNodeGroup_checkScheduledEvents(t *testing.T) {
+	awsConfig, clients := fake.NewConfiguration(testRegion)
+
+	serverConfig := &types.AutoScalerServerConfig{
+		AwsInfos: map[string]*aws.Configuration{
+			"default": awsConfig,
+		},
+	}
+
+	// Full node group, nodes are retired without replacement
+	nodeGroup := &AutoScalerServerNodeGroup{
+		NodeGroupIdentifier: "events",
+		InstanceType:        "t3a.medium",
+		Status:              NodegroupCreated,
+		MaxNodeSize:         2,
+		Nodes:               make(map[string]*AutoScalerServerNode),
+		RunningNodes:        make(map[int]ServerNodeState),
+		pendingNodes:        make(map[string]*AutoScalerServerNode),
+	}
+
+	nodeGroup.setConfiguration(serverConfig)
+
+	client := &baseTest{testConfig: awsConfig, t: t}
+
+	for index := 1; index <= 2; index++ {
+		nodeName, nodeIndex := nodeGroup.nodeName(index, false, false)
+		node := nodeGroup.newAutoscaledNode(nodeName, nodeIndex, awsConfig)
+
+		if instance, err := node.createInstance(); assert.NoError(t, err) {
+			node.runningInstance = instance
+			node.State = AutoScalerServerNodeStateRunning
+		}
+
+		nodeGroup.Nodes[nodeName] = node
+		nodeGroup.RunningNodes[nodeIndex] = ServerNodeStateRunning
+		nodeGroup.numOfProvisionnedNodes++
+	}
+
+	retiring := nodeGroup.Nodes["events-autoscaled-01"]
+	completed := nodeGroup.Nodes["events-autoscaled-02"]
+
+	clients.EC2.ScheduledEvents[*retiring.runningInstance.InstanceID] = []*ec2.InstanceStatusEvent{
+		{
+			Code:        awssdk.String(ec2.EventCodeInstanceRetirement),
+			Description: awssdk.String("The instance is running on degraded hardware"),
+			NotBefore:   awssdk.Time(time.Now().Add(48 * time.Hour)),
+		},
+	}
+
+	clients.EC2.ScheduledEvents[*completed.runningInstance.InstanceID] = []*ec2.InstanceStatusEvent{
+		{
+			Code:        awssdk.String(ec2.EventCodeSystemReboot),
+			Description: awssdk.String("[Completed] Scheduled reboot"),
+			NotBefore:   awssdk.Time(time.Now().Add(-time.Hour)),
+		},
+	}
+
+	// Failed termination keep the node
+	clients.EC2.TerminateInstancesError = fmt.Errorf("terminate failed")
+
+	if retired, err := nodeGroup.checkScheduledEvents(client); assert.NoError(t, err) {
+		assert.Empty(t, retired)
+		assert.Equal(t, ec2.EventCodeInstanceRetirement, retiring.ScheduledEvent)
+		assert.Equal(t, retiring, nodeGroup.findNamedNode("events-autoscaled-01"))
+	}
+
+	clients.EC2.TerminateInstancesError = nil
+
+	// Retried without another replacement
+	if retired, err := nodeGroup.checkScheduledEvents(client); assert.NoError(t, err) && assert.Len(t, retired, 1) {
+		assert.Equal(t, retiring, retired[0])
+		assert.Equal(t, ec2.EventCodeInstanceRetirement, retiring.ScheduledEvent)
+		assert.Equal(t, ec2.InstanceStateNameTerminated, *clients.EC2.Instances[*retiring.runningInstance.InstanceID].State.Name)
+		assert.Nil(t, nodeGroup.findNamedNode("events-autoscaled-01"))
+		assert.NotNil(t, nodeGroup.findNamedNode("events-autoscaled-02"))
+		assert.Equal(t, 1, nodeGroup.numOfProvisionnedNodes)
+		assert.Equal(t, []string{
+			"events-autoscaled-01/" + eventReasonScheduledEvent,
+			"events-autoscaled-01/" + eventReasonSurgeReplacement,
+			"events-autoscaled-01/" + eventReasonRetired,
+			"events-autoscaled-01/" + eventReasonRetired,
+		}, client.events)
+	}
+
+	// Nothing more to retire
+	if retired, err := nodeGroup.checkScheduledEvents(client); assert.NoError(t, err) {
+		assert.Empty(t, retired)
+	}
+}
+
+func TestNodeGroup_ssmBootstrap(t *testing.T) {
+	awsConfig, clients := fake.NewConfiguration(testRegion)
+	awsConfig.RemoteExec = &aws.RemoteExecOptions{
+		Transport: aws.RemoteTransportSSM,
+	}
+
+	pkiDir := t.TempDir()
+
+	if !assert.NoError(t, os.WriteFile(pkiDir+"/ca.crt", []byte("certificate"), 0600)) {
+		return
+	}
+
+	serverConfig := &types.AutoScalerServerConfig{
+		CloudProvider:          "aws",
+		KubernetesPKISourceDir: pkiDir,
+		KubernetesPKIDestDir:   "/etc/kubernetes/pki",
+		SSH: &types.AutoScalerServerSSH{
+			WaitSshReadyInSeconds: 1,
+		},
+		AwsInfos: map[string]*aws.Configuration{
+			"default": awsConfig,
+		},
+	}
+
+	nodeGroup := &AutoScalerServerNodeGroup{
+		NodeGroupIdentifier: "ssm",
+		InstanceType:        "t3a.medium",
+		Status:              NodegroupCreated,
+		MaxNodeSize:         2,
+		Nodes:               make(map[string]*AutoScalerServerNode),
+		RunningNodes:        make(map[int]ServerNodeState),
+		pendingNodes:        make(map[string]*AutoScalerServerNode),
+	}
+
+	nodeGroup.setConfiguration(serverConfig)
+
+	nodeName, nodeIndex := nodeGroup.nodeName(1, true, false)
+	node := nodeGroup.newAutoscaledNode(nodeName, nodeIndex, awsConfig)
+	node.ControlPlaneNode = true
+
+	instance, err := node.createInstance()
+
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	node.runningInstance = instance
+
+	var parameters []string
+
+	clients.SSM.CommandHandler = func(instanceID string, commands []string) (string, error) {
+		parameters = append(parameters, utils.Values(clients.SSM.Parameters)...)
+
+		if strings.Contains(commands[1], "local-hostname") {
+			return "ip-10-0-0-1.us-east-1.compute.internal", nil
+		}
+
+		return "", nil
+	}
+
+	// The node is not ready until the agent is online
+	clients.SSM.Offline[*instance.InstanceID] = true
+
+	assert.Error(t, node.WaitSSHReady(node.InstanceName, "10.0.0.1"))
+	assert.Empty(t, clients.SSM.Commands)
+
+	delete(clients.SSM.Offline, *instance.InstanceID)
+
+	if assert.NoError(t, node.WaitSSHReady(node.InstanceName, "10.0.0.1")) {
+		assert.Equal(t, "ip-10-0-0-1.us-east-1.compute.internal", node.NodeName)
+		assert.Contains(t, clients.SSM.Commands[*instance.InstanceID][0], "hostnamectl set-hostname "+node.InstanceName)
+	}
+
+	// The PKI is transferred through a SSM parameter removed after the copy
+	if assert.NoError(t, node.recopyKubernetesPKIIfNeeded()) {
+		commands := clients.SSM.Commands[*instance.InstanceID]
+		script := strings.Join(commands[len(commands)-1], "\n")
+
+		assert.Contains(t, script, "aws ssm get-parameter")
+		assert.Contains(t, script, "tar -xzf /tmp/"+filepath.Base(pkiDir)+".tgz -C /etc/kubernetes/pki")
+		assert.Len(t, parameters, 1)
+		assert.Empty(t, clients.SSM.Parameters)
+	}
+}
+
+func TestServer_checkPrivateKeyExists(t *testing.T) {
+	awsConfig, _ := fake.NewConfiguration(testRegion)
+
+	app := &AutoScalerServerApp{
+		configuration: &types.AutoScalerServerConfig{
+			SSH: &types.AutoScalerServerSSH{
+				AuthKeys: "/nonexistent/id_rsa",
+			},
+			AwsInfos: map[string]*aws.Configuration{
+				"default": awsConfig,
+			},
+		},
+	}
+
+	assert.False(t, app.checkPrivateKeyExists())
+
+	// No ssh key required when all node groups use SSM
+	awsConfig.RemoteExec = &aws.RemoteExecOptions{
+		Transport: aws.RemoteTransportSSM,
+	}
+
+	assert.True(t, app.checkPrivateKeyExists())
+}
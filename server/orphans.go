@@ -0,0 +1,175 @@
+package server
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Fred78290/kubernetes-aws-autoscaler/aws"
+	"github.com/Fred78290/kubernetes-aws-autoscaler/constantes"
+	"github.com/Fred78290/kubernetes-aws-autoscaler/types"
+	glog "github.com/sirupsen/logrus"
+)
+
+// kubernetesInstances return the instance names and provider IDs of the kubernetes nodes
+func kubernetesInstances(c types.ClientGenerator) (map[string]bool, []string, error) {
+	nodeInfos, err := c.NodeList()
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	names := make(map[string]bool)
+	providerIDs := make([]string, 0, len(nodeInfos.Items))
+
+	for _, nodeInfo := range nodeInfos.Items {
+		names[nodeInfo.Name] = true
+
+		if instanceName, found := nodeInfo.Annotations[constantes.AnnotationInstanceName]; found {
+			names[instanceName] = true
+		}
+
+		if len(nodeInfo.Spec.ProviderID) > 0 {
+			providerIDs = append(providerIDs, nodeInfo.Spec.ProviderID)
+		}
+	}
+
+	return names, providerIDs, nil
+}
+
+// knownInstanceNames return a snapshot of the instance and node names known by the node group,
+// including the nodes of the warm pool and the warm nodes still launching
+func (g *AutoScalerServerNodeGroup) knownInstanceNames() map[string]bool {
+	g.Lock()
+	defer g.Unlock()
+
+	known := make(map[string]bool)
+
+	for _, nodes := range []map[string]*AutoScalerServerNode{g.Nodes, g.pendingNodes, g.warmNodes, g.fillingWarmNodes} {
+		for name, node := range nodes {
+			known[name] = true
+			known[node.InstanceName] = true
+			known[node.NodeName] = true
+		}
+	}
+
+	return known
+}
+
+// isOrphan return true if the instance is unknown by the node group and has no kubernetes node
+func isOrphan(instance *aws.Ec2Instance, known, names map[string]bool, providerIDs []string) bool {
+	if known[instance.InstanceName] || names[instance.InstanceName] {
+		return false
+	}
+
+	// Node name and instance name could be differ when using AWS cloud provider
+	for _, providerID := range providerIDs {
+		if strings.HasSuffix(providerID, "/"+*instance.InstanceID) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// deleteOrphan remove the DNS records then terminate the orphaned instance
+func (g *AutoScalerServerNodeGroup) deleteOrphan(instance *aws.Ec2Instance) error {
+	awsConfig := g.configuration.GetAwsConfiguration(g.NodeGroupIdentifier)
+
+	if provider := awsConfig.GetDNSProvider(); provider != nil {
+		hostname := fmt.Sprintf("%s.%s", instance.InstanceName, awsConfig.Network.PrivateZoneName)
+
+		if err := provider.Unregister(hostname, false); err != nil {
+			glog.Warnf("unable to unregister DNS entry, reason: %v", err)
+		}
+	}
+
+	return instance.Delete()
+}
+
+// collectOrphans terminate the instances tagged with the node group and the cluster, without kubernetes node and unknown by the group.
+// Instances launched during the grace period are skipped, they could be joining. Without cluster name,
+// instances have no cluster tag to tell clusters apart, orphans are only reported.
+// Return the names of orphaned instances
+func (g *AutoScalerServerNodeGroup) collectOrphans(c types.ClientGenerator, options *types.OrphanCollectorOptions) ([]string, error) {
+	if g.Status != NodegroupCreated {
+		return nil, nil
+	}
+
+	reportOnly := options.ReportOnly
+
+	if len(g.configuration.GetAwsConfiguration(g.NodeGroupIdentifier).ClusterName) == 0 && !reportOnly {
+		glog.Warnf(constantes.WarnOrphanCollectorReportOnly, g.NodeGroupIdentifier)
+
+		reportOnly = true
+	}
+
+	// Don't hold the lock during aws and kubernetes calls, instances launched since the snapshot are in grace period
+	known := g.knownInstanceNames()
+	instances, err := g.getInstanceCache().AliveInstances()
+
+	if err != nil {
+		return nil, err
+	}
+
+	names, providerIDs, err := kubernetesInstances(c)
+
+	if err != nil {
+		return nil, err
+	}
+
+	orphans := make([]string, 0)
+
+	for _, instance := range instances {
+		if instance.LaunchTime != nil && time.Since(*instance.LaunchTime) < options.GetGracePeriod() {
+			continue
+		}
+
+		if !isOrphan(instance, known, names, providerIDs) {
+			continue
+		}
+
+		orphans = append(orphans, instance.InstanceName)
+
+		if reportOnly {
+			glog.Warnf(constantes.WarnOrphanedInstance, instance.InstanceName, *instance.InstanceID, g.NodeGroupIdentifier)
+		} else if err = g.deleteOrphan(instance); err != nil {
+			glog.Errorf(constantes.ErrUnableToDeleteOrphan, instance.InstanceName, g.NodeGroupIdentifier, err)
+		} else {
+			glog.Infof("Terminated orphaned instance:%s of nodegroup:%s", instance.InstanceName, g.NodeGroupIdentifier)
+		}
+	}
+
+	return orphans, nil
+}
+
+// collectOrphans run one pass of the orphan collector on each node group
+func (s *AutoScalerServerApp) collectOrphans() {
+	options := s.configuration.OrphanCollector
+
+	for _, ng := range s.nodeGroups() {
+		if _, err := ng.collectOrphans(s.kubeClient, options); err != nil {
+			glog.Errorf(constantes.ErrUnableToCollectOrphans, ng.NodeGroupIdentifier, err)
+		}
+	}
+}
+
+// startOrphanCollector periodically collect orphaned instances if enabled
+func (s *AutoScalerServerApp) startOrphanCollector() {
+	options := s.configuration.OrphanCollector
+
+	if options == nil || !options.Enabled {
+		return
+	}
+
+	glog.Infof("Start orphan collector, interval:%v, grace period:%v, report only:%v", options.GetInterval(), options.GetGracePeriod(), options.ReportOnly)
+
+	go func() {
+		ticker := time.NewTicker(options.GetInterval())
+		defer ticker.Stop()
+
+		for range ticker.C {
+			s.collectOrphans()
+		}
+	}()
+}
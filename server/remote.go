@@ -0,0 +1,130 @@
+package server
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Fred78290/kubernetes-aws-autoscaler/aws"
+	"github.com/Fred78290/kubernetes-aws-autoscaler/constantes"
+	"github.com/Fred78290/kubernetes-aws-autoscaler/types"
+	"github.com/Fred78290/kubernetes-aws-autoscaler/utils"
+	glog "github.com/sirupsen/logrus"
+)
+
+// remoteExecutor run the bootstrap commands as root on a node and copy directories onto it
+type remoteExecutor interface {
+	// ready return true when the node accept commands
+	ready() (bool, error)
+
+	// unreachable return true if the error means the node doesn't accept commands yet
+	unreachable(err error) bool
+
+	// sudo run the commands as root, stop at the first failure
+	sudo(command ...string) (string, error)
+
+	// copyDir copy the content of the local directory into the remote directory, owned by root
+	copyDir(src, dst string) error
+}
+
+// sshExecutor run commands over ssh, port 22 of the node must be reachable
+type sshExecutor struct {
+	ssh     *types.AutoScalerServerSSH
+	address string
+	timeout time.Duration
+}
+
+// ssmExecutor run commands with SSM Run Command, the SSM agent of the node must be online
+type ssmExecutor struct {
+	instance *aws.Ec2Instance
+}
+
+// remoteExecutor return the executor selected by the node group configuration
+func (vm *AutoScalerServerNode) remoteExecutor(address string, timeout time.Duration) remoteExecutor {
+	if vm.awsConfig.UseSSMTransport() {
+		return &ssmExecutor{
+			instance: vm.runningInstance,
+		}
+	}
+
+	return &sshExecutor{
+		ssh:     vm.serverConfig.SSH,
+		address: address,
+		timeout: timeout,
+	}
+}
+
+// ready always return true, an unreachable node fail the first command
+func (e *sshExecutor) ready() (bool, error) {
+	return true, nil
+}
+
+func (e *sshExecutor) unreachable(err error) bool {
+	return strings.HasSuffix(err.Error(), "connection refused") || strings.HasSuffix(err.Error(), "i/o timeout")
+}
+
+func (e *sshExecutor) sudo(command ...string) (string, error) {
+	return utils.Sudo(e.ssh, e.address, e.timeout, command...)
+}
+
+func (e *sshExecutor) copyDir(src, dst string) error {
+	var err error
+
+	if err = utils.Scp(e.ssh, e.address, src, "."); err != nil {
+		glog.Errorf("scp failed: %v", err)
+	} else if _, err = e.sudo(fmt.Sprintf("mkdir -p %s", dst)); err != nil {
+		glog.Errorf("mkdir failed: %v", err)
+	} else if _, err = e.sudo(fmt.Sprintf("cp -r %s/* %s", filepath.Base(src), dst)); err != nil {
+		glog.Errorf("mv failed: %v", err)
+	} else if _, err = e.sudo(fmt.Sprintf("chown -R root:root %s", dst)); err != nil {
+		glog.Errorf("chown failed: %v", err)
+	}
+
+	return err
+}
+
+func (e *ssmExecutor) ready() (bool, error) {
+	return e.instance.IsManaged()
+}
+
+// unreachable return false, readiness is given by the agent status
+func (e *ssmExecutor) unreachable(err error) bool {
+	return false
+}
+
+func (e *ssmExecutor) sudo(command ...string) (string, error) {
+	return e.instance.RunCommand(command...)
+}
+
+// copyDir stage the directory as archive in S3 or SSM parameter, the node download and extract it
+func (e *ssmExecutor) copyDir(src, dst string) error {
+	name := filepath.Base(src) + ".tgz"
+	path := filepath.Join("/tmp", name)
+
+	archive, err := utils.ArchiveDir(src)
+
+	if err != nil {
+		return err
+	}
+
+	fetch, err := e.instance.StageArchive(name, archive, path)
+
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err := e.instance.UnstageArchive(name); err != nil {
+			glog.Warnf(constantes.WarnUnableToUnstageArchive, name, e.instance.InstanceName, err)
+		}
+	}()
+
+	if out, err := e.sudo(fetch, fmt.Sprintf("mkdir -p %s", dst), fmt.Sprintf("tar -xzf %s -C %s", path, dst), fmt.Sprintf("rm -f %s", path), fmt.Sprintf("chown -R root:root %s", dst)); err != nil {
+		glog.Errorf("copy failed: %v, output: %s", err, out)
+
+		return err
+	}
+
+	return nil
+}
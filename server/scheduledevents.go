@@ -0,0 +1,176 @@
+package server
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Fred78290/kubernetes-aws-autoscaler/aws"
+	"github.com/Fred78290/kubernetes-aws-autoscaler/constantes"
+	"github.com/Fred78290/kubernetes-aws-autoscaler/types"
+	glog "github.com/sirupsen/logrus"
+	apiv1 "k8s.io/api/core/v1"
+)
+
+const (
+	// eventReasonScheduledEvent reason of the node event recorded when a scheduled event is found
+	eventReasonScheduledEvent = "ScheduledEvent"
+
+	// eventReasonSurgeReplacement reason of the node event recorded when the replacement is launched
+	eventReasonSurgeReplacement = "SurgeReplacement"
+
+	// eventReasonRetired reason of the node event recorded before the node is terminated
+	eventReasonRetired = "Retired"
+)
+
+// recordNodeEvent record a kubernetes event on the node, errors are only logged
+func (vm *AutoScalerServerNode) recordNodeEvent(c types.ClientGenerator, eventType, reason, message string) {
+	if err := c.RecordNodeEvent(vm.NodeName, eventType, reason, message); err != nil {
+		glog.Errorf(constantes.ErrUnableToRecordNodeEvent, vm.NodeName, err)
+	}
+}
+
+// scheduledEventCandidates return the running autoscaled nodes not already replaced by instance ID,
+// and the replaced nodes whose retirement failed
+func (g *AutoScalerServerNodeGroup) scheduledEventCandidates() (map[string]*AutoScalerServerNode, []*AutoScalerServerNode) {
+	candidates := make(map[string]*AutoScalerServerNode)
+	retiring := make([]*AutoScalerServerNode, 0)
+
+	for _, node := range g.Nodes {
+		if node.NodeType == AutoScalerServerNodeAutoscaled && node.runningInstance != nil {
+			if len(node.ScheduledEvent) > 0 {
+				retiring = append(retiring, node)
+			} else if node.State == AutoScalerServerNodeStateRunning {
+				candidates[*node.runningInstance.InstanceID] = node
+			}
+		}
+	}
+
+	return candidates, retiring
+}
+
+// replaceNode launch a replacement if the node group is not full. The node keep the scheduled event,
+// its retirement is retried without launching another replacement
+func (g *AutoScalerServerNodeGroup) replaceNode(c types.ClientGenerator, node *AutoScalerServerNode, event *aws.ScheduledEvent) {
+	glog.Infof("Node:%s of nodegroup:%s has scheduled event %s at %v", node.InstanceName, g.NodeGroupIdentifier, event.Code, event.NotBefore)
+
+	node.ScheduledEvent = event.Code
+	node.retiringEvent = event
+	node.recordNodeEvent(c, apiv1.EventTypeWarning, eventReasonScheduledEvent, fmt.Sprintf("%s scheduled at %s: %s", event.Code, event.NotBefore.Format(time.RFC3339), event.Description))
+
+	if nodes, err := g.increaseSize(c, 1); err != nil {
+		node.recordNodeEvent(c, apiv1.EventTypeWarning, eventReasonSurgeReplacement, fmt.Sprintf("node is retired without replacement, reason: %v", err))
+	} else if len(nodes) > 0 {
+		node.recordNodeEvent(c, apiv1.EventTypeNormal, eventReasonSurgeReplacement, fmt.Sprintf("replaced by node %s", nodes[0].NodeName))
+	}
+}
+
+// retireNode cordon, drain and terminate the node, the node is kept in the node group if the termination failed
+func (g *AutoScalerServerNodeGroup) retireNode(c types.ClientGenerator, node *AutoScalerServerNode) error {
+	// Event unknown when the state is restored
+	event := node.retiringEvent
+
+	if event == nil {
+		event = &aws.ScheduledEvent{
+			Code:      node.ScheduledEvent,
+			NotBefore: time.Now(),
+		}
+	}
+
+	if time.Now().After(event.NotBefore) {
+		glog.Warnf(constantes.WarnScheduledEventDeadlinePassed, node.InstanceName, event.Code, event.NotBefore)
+	}
+
+	node.recordNodeEvent(c, apiv1.EventTypeNormal, eventReasonRetired, fmt.Sprintf("node is drained and terminated before %s %s", event.Code, event.NotBefore.Format(time.RFC3339)))
+
+	g.Lock()
+	defer g.Unlock()
+
+	if err := node.deleteVM(c); err != nil {
+		return err
+	}
+
+	g.forgetNode(node)
+
+	return nil
+}
+
+// checkScheduledEvents retire the nodes having an upcoming scheduled event, each node is replaced once
+// and its retirement retried on next check if failed. Return the retired nodes
+func (g *AutoScalerServerNodeGroup) checkScheduledEvents(c types.ClientGenerator) ([]*AutoScalerServerNode, error) {
+	if g.Status != NodegroupCreated {
+		return nil, nil
+	}
+
+	g.Lock()
+	candidates, retiring := g.scheduledEventCandidates()
+	g.Unlock()
+
+	retired := make([]*AutoScalerServerNode, 0, len(retiring))
+
+	retire := func(node *AutoScalerServerNode) {
+		if err := g.retireNode(c, node); err != nil {
+			glog.Errorf(constantes.ErrUnableToRetireNode, node.InstanceName, err)
+		} else {
+			retired = append(retired, node)
+		}
+	}
+
+	for _, node := range retiring {
+		retire(node)
+	}
+
+	if len(candidates) == 0 {
+		return retired, nil
+	}
+
+	instanceIDs := make([]string, 0, len(candidates))
+
+	for instanceID := range candidates {
+		instanceIDs = append(instanceIDs, instanceID)
+	}
+
+	events, err := g.configuration.GetAwsConfiguration(g.NodeGroupIdentifier).DescribeScheduledEvents(instanceIDs)
+
+	if err != nil {
+		return retired, err
+	}
+
+	for instanceID, event := range events {
+		node := candidates[instanceID]
+
+		g.replaceNode(c, node, event)
+
+		retire(node)
+	}
+
+	return retired, nil
+}
+
+// checkScheduledEvents run one check of scheduled events on each node group
+func (s *AutoScalerServerApp) checkScheduledEvents() {
+	for _, ng := range s.nodeGroups() {
+		if _, err := ng.checkScheduledEvents(s.kubeClient); err != nil {
+			glog.Errorf(constantes.ErrUnableToCheckScheduledEvents, ng.NodeGroupIdentifier, err)
+		}
+	}
+}
+
+// startScheduledEventsChecker periodically check scheduled events if enabled
+func (s *AutoScalerServerApp) startScheduledEventsChecker() {
+	options := s.configuration.ScheduledEvents
+
+	if options == nil || !options.Enabled {
+		return
+	}
+
+	glog.Infof("Start scheduled events checker, interval:%v", options.GetInterval())
+
+	go func() {
+		ticker := time.NewTicker(options.GetInterval())
+		defer ticker.Stop()
+
+		for range ticker.C {
+			s.checkScheduledEvents()
+		}
+	}()
+}
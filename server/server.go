@@ -7,10 +7,13 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"math"
 	"net"
 	"os"
+	"sync"
 	"time"
 
+	"github.com/Fred78290/kubernetes-aws-autoscaler/aws"
 	"github.com/Fred78290/kubernetes-aws-autoscaler/externalgrpc"
 	apigrpc "github.com/Fred78290/kubernetes-aws-autoscaler/grpc"
 	"github.com/Fred78290/kubernetes-aws-autoscaler/pkg/signals"
@@ -58,6 +61,8 @@ type AutoScalerServerApp struct {
 	running         bool
 	kubeClient      types.ClientGenerator
 	requestTimeout  time.Duration
+	pricing         *aws.PricingProvider
+	groupsLock      sync.RWMutex
 }
 
 var phSavedState = ""
@@ -75,6 +80,14 @@ func (s *AutoScalerServerApp) getResourceLimiter() *types.ResourceLimiter {
 	return s.ResourceLimiter
 }
 
+// nodeGroups return a snapshot of the node groups, safe to iterate from background goroutines
+func (s *AutoScalerServerApp) nodeGroups() []*AutoScalerServerNodeGroup {
+	s.groupsLock.RLock()
+	defer s.groupsLock.RUnlock()
+
+	return utils.Values(s.Groups)
+}
+
 func (s *AutoScalerServerApp) getNodeGroup(nodegroupName string) (*AutoScalerServerNodeGroup, error) {
 	if ng, found := s.Groups[nodegroupName]; found {
 		return ng, nil
@@ -112,16 +125,20 @@ func (s *AutoScalerServerApp) newNodeGroup(nodeGroupID string, minNodeSize, maxN
 		DiskSize:                   machine.DiskSize,
 		Status:                     NodegroupNotCreated,
 		pendingNodes:               make(map[string]*AutoScalerServerNode),
+		warmNodes:                  make(map[string]*AutoScalerServerNode),
 		Nodes:                      make(map[string]*AutoScalerServerNode),
 		MinNodeSize:                int(minNodeSize),
 		MaxNodeSize:                int(maxNodeSize),
 		NodeLabels:                 labels,
 		SystemLabels:               systemLabels,
 		AutoProvision:              autoProvision,
-		configuration:              s.configuration,
 	}
 
+	nodeGroup.setConfiguration(s.configuration)
+
+	s.groupsLock.Lock()
 	s.Groups[nodeGroupID] = nodeGroup
+	s.groupsLock.Unlock()
 
 	return nodeGroup, nil
 }
@@ -141,7 +158,9 @@ func (s *AutoScalerServerApp) deleteNodeGroup(nodeGroupID string) error {
 		return err
 	}
 
+	s.groupsLock.Lock()
 	delete(s.Groups, nodeGroupID)
+	s.groupsLock.Unlock()
 
 	return nil
 }
@@ -168,6 +187,8 @@ func (s *AutoScalerServerApp) createNodeGroup(nodeGroupID string) (*AutoScalerSe
 		}
 
 		nodeGroup.Status = NodegroupCreated
+
+		nodeGroup.fillWarmPool(s.kubeClient)
 	}
 
 	return nodeGroup, nil
@@ -218,6 +239,8 @@ func (s *AutoScalerServerApp) doAutoProvision() error {
 
 			// Drop VM if kubernetes nodes removed
 			ng.findManagedNodeDeleted(s.kubeClient, formerNodes)
+
+			ng.fillWarmPool(s.kubeClient)
 		}
 	}
 
@@ -359,6 +382,15 @@ func (s *AutoScalerServerApp) NodeGroupForNode(ctx context.Context, request *api
 			}, nil
 		}
 
+		// Stopped nodes of the warm pool are not processed by cluster autoscaler
+		if nodeGroup.findWarmNode(node.Name) != nil {
+			return &apigrpc.NodeGroupForNodeReply{
+				Response: &apigrpc.NodeGroupForNodeReply_NodeGroup{
+					NodeGroup: &apigrpc.NodeGroup{},
+				},
+			}, nil
+		}
+
 		return &apigrpc.NodeGroupForNodeReply{
 			Response: &apigrpc.NodeGroupForNodeReply_NodeGroup{
 				NodeGroup: &apigrpc.NodeGroup{
@@ -659,7 +691,9 @@ func (s *AutoScalerServerApp) Cleanup(ctx context.Context, request *apigrpc.Clou
 
 	glog.Debug("Leave server Cleanup, done")
 
+	s.groupsLock.Lock()
 	s.Groups = make(map[string]*AutoScalerServerNodeGroup)
+	s.groupsLock.Unlock()
 
 	return &apigrpc.CleanupReply{
 		Error: lastError,
@@ -1402,13 +1436,132 @@ func (s *AutoScalerServerApp) NodePrice(ctx context.Context, request *apigrpc.No
 		return nil, fmt.Errorf(constantes.ErrMismatchingProvider)
 	}
 
+	var annotations map[string]string
+
+	if node, err := utils.NodeFromJSON(request.GetNode()); err == nil {
+		annotations = node.Annotations
+	}
+
 	return &apigrpc.NodePriceReply{
 		Response: &apigrpc.NodePriceReply_Price{
-			Price: s.configuration.NodePrice,
+			Price: s.nodePrice(annotations, time.Unix(request.GetStartTime(), 0), time.Unix(request.GetEndTime(), 0)),
 		},
 	}, nil
 }
 
+// createPricingProvider build the pricing provider once at startup when pricing is declared
+func (s *AutoScalerServerApp) createPricingProvider() error {
+	if s.configuration.Pricing != nil {
+		provider, err := aws.NewPricingProvider(s.configuration.GetAwsConfiguration("default"), s.configuration.Pricing)
+
+		if err != nil {
+			return err
+		}
+
+		s.pricing = provider
+	}
+
+	return nil
+}
+
+// hourlyPrice return the hourly price of the machine type from the price catalog or from the machine declaration
+func (s *AutoScalerServerApp) hourlyPrice(instanceType string, spot bool) (float64, bool) {
+	if s.pricing != nil {
+		if price, found := s.pricing.HourlyPrice(instanceType, spot); found {
+			return price, true
+		}
+	}
+
+	if machine := s.getMachineType(instanceType); machine != nil {
+		if spot && machine.SpotPrice > 0 {
+			return machine.SpotPrice, true
+		}
+
+		if machine.Price > 0 {
+			return machine.Price, true
+		}
+	}
+
+	return 0, false
+}
+
+// nodeInstanceType return the instance type of the node and if it's a spot instance
+func (s *AutoScalerServerApp) nodeInstanceType(annotations map[string]string) (string, bool) {
+	var instanceType string
+
+	if nodeGroup, err := s.getNodeGroup(annotations[constantes.AnnotationNodeGroupName]); err == nil {
+		instanceType = nodeGroup.InstanceType
+
+		if node := nodeGroup.findNamedNode(annotations[constantes.AnnotationInstanceName]); node != nil {
+			instanceType = node.InstanceType
+		}
+	}
+
+	return instanceType, annotations[constantes.AnnotationInstanceLifecycle] == aws.InstanceLifecycleSpot
+}
+
+// nodePrice return the cost of the node for the time window when pricing is declared.
+// Without pricing, return the cost from the spot price of the machine type if the node is a spot instance, else the default node price
+func (s *AutoScalerServerApp) nodePrice(annotations map[string]string, startTime, endTime time.Time) float64 {
+	instanceType, spot := s.nodeInstanceType(annotations)
+
+	if s.pricing != nil {
+		if price, found := s.hourlyPrice(instanceType, spot); found {
+			return price * endTime.Sub(startTime).Hours()
+		}
+	} else if spot {
+		if machine, found := s.configuration.Machines[instanceType]; found && machine.SpotPrice > 0 {
+			return machine.SpotPrice * endTime.Sub(startTime).Hours()
+		}
+	}
+
+	return s.configuration.NodePrice
+}
+
+// podPrice return the cost of the pod for the time window when pricing is declared,
+// as the share of its node or of the default machine type used by its requests
+func (s *AutoScalerServerApp) podPrice(pod *apiv1.Pod, startTime, endTime time.Time) float64 {
+	if pod == nil || s.pricing == nil {
+		return s.configuration.PodPrice
+	}
+
+	instanceType := s.configuration.DefaultMachineType
+	spot := false
+
+	if len(pod.Spec.NodeName) > 0 {
+		for _, nodeGroup := range s.nodeGroups() {
+			if node := nodeGroup.findNamedNode(pod.Spec.NodeName); node != nil {
+				instanceType = node.InstanceType
+				spot = node.SpotInstance
+				break
+			}
+		}
+	}
+
+	machine := s.getMachineType(instanceType)
+
+	if machine == nil || machine.Vcpu == 0 || machine.Memory == 0 {
+		return s.configuration.PodPrice
+	}
+
+	price, found := s.hourlyPrice(instanceType, spot)
+
+	if !found {
+		return s.configuration.PodPrice
+	}
+
+	var cpu, memory int64
+
+	for _, container := range pod.Spec.Containers {
+		cpu += container.Resources.Requests.Cpu().MilliValue()
+		memory += container.Resources.Requests.Memory().Value()
+	}
+
+	share := math.Max(float64(cpu)/float64(machine.Vcpu*1000), float64(memory)/float64(int64(machine.Memory)*1024*1024))
+
+	return price * math.Min(share, 1) * endTime.Sub(startTime).Hours()
+}
+
 // PodPrice returns a theoretical minimum price of running a pod for a given
 // period of time on a perfectly matching machine.
 func (s *AutoScalerServerApp) PodPrice(ctx context.Context, request *apigrpc.PodPriceRequest) (*apigrpc.PodPriceReply, error) {
@@ -1419,9 +1572,17 @@ func (s *AutoScalerServerApp) PodPrice(ctx context.Context, request *apigrpc.Pod
 		return nil, fmt.Errorf(constantes.ErrMismatchingProvider)
 	}
 
+	var pod *apiv1.Pod
+
+	if request.GetPod() != "" {
+		if p, err := utils.PodFromJSON(request.GetPod()); err == nil {
+			pod = p
+		}
+	}
+
 	return &apigrpc.PodPriceReply{
 		Response: &apigrpc.PodPriceReply_Price{
-			Price: s.configuration.PodPrice,
+			Price: s.podPrice(pod, time.Unix(request.GetStartTime(), 0), time.Unix(request.GetEndTime(), 0)),
 		},
 	}, nil
 }
@@ -1591,7 +1752,7 @@ func (s *AutoScalerServerApp) run(config *types.AutoScalerServerConfig) {
 }
 
 func (s *AutoScalerServerApp) checkPrivateKeyExists() bool {
-	if len(s.configuration.SSH.Password) > 0 {
+	if s.useSSMTransportOnly() || len(s.configuration.SSH.Password) > 0 {
 		return true
 	}
 
@@ -1602,6 +1763,17 @@ func (s *AutoScalerServerApp) checkPrivateKeyExists() bool {
 	return utils.FileExistAndReadable(s.configuration.SSH.AuthKeys)
 }
 
+// useSSMTransportOnly return true if all node groups run the bootstrap commands with SSM Run Command, ssh is not used
+func (s *AutoScalerServerApp) useSSMTransportOnly() bool {
+	for _, awsConfig := range s.configuration.AwsInfos {
+		if !awsConfig.UseSSMTransport() {
+			return false
+		}
+	}
+
+	return len(s.configuration.AwsInfos) > 0
+}
+
 func (s *AutoScalerServerApp) checkKubernetesPKIReadable() bool {
 	return utils.DirExistAndReadable(s.configuration.KubernetesPKISourceDir)
 }
@@ -1691,6 +1863,16 @@ func StartServer(kubeClient types.ClientGenerator, c *types.Config) {
 
 	config.ManagedNodeResourceLimiter = c.GetManagedNodeResourceLimiter()
 
+	if config.MachinesDiscovery != nil && config.MachinesDiscovery.Enabled {
+		machineTypes, err := config.GetAwsConfiguration("default").DiscoverMachineTypes(config.MachinesDiscovery)
+
+		if err != nil {
+			glog.Fatalf("failed to discover machine types, error:%v", err)
+		}
+
+		config.MergeMachineTypes(machineTypes)
+	}
+
 	if !phSaveState || !utils.FileExists(phSavedState) {
 		autoScalerServer = &AutoScalerServerApp{
 			kubeClient:      kubeClient,
@@ -1720,6 +1902,10 @@ func StartServer(kubeClient types.ClientGenerator, c *types.Config) {
 		}
 	}
 
+	if err = autoScalerServer.createPricingProvider(); err != nil {
+		glog.Fatalf("failed to create pricing provider, error:%v", err)
+	}
+
 	if !autoScalerServer.checkPrivateKeyExists() {
 		log.Fatalf(constantes.ErrFatalMissingSSHKey, autoScalerServer.configuration.SSH.AuthKeys)
 	}
@@ -1736,6 +1922,10 @@ func StartServer(kubeClient types.ClientGenerator, c *types.Config) {
 		glog.Fatalf("Can't start controller, reason:%s", err)
 	}
 
+	autoScalerServer.startOrphanCollector()
+	autoScalerServer.startInterruptionHandler()
+	autoScalerServer.startScheduledEventsChecker()
+
 	if *config.UseVanillaGrpcProvider {
 		autoScalerServer.runVanillaGrpc(&config)
 	} else {
@@ -0,0 +1,287 @@
+package server
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/Fred78290/kubernetes-aws-autoscaler/aws"
+	"github.com/Fred78290/kubernetes-aws-autoscaler/constantes"
+	"github.com/Fred78290/kubernetes-aws-autoscaler/types"
+	"github.com/Fred78290/kubernetes-aws-autoscaler/utils"
+	glog "github.com/sirupsen/logrus"
+)
+
+// getWarmPool return the warm pool options of the node group, nil if disabled
+func (g *AutoScalerServerNodeGroup) getWarmPool() *aws.WarmPoolOptions {
+	if awsConfig := g.configuration.GetAwsConfiguration(g.NodeGroupIdentifier); awsConfig != nil {
+		return awsConfig.WarmPool
+	}
+
+	return nil
+}
+
+// WarmNodes return the stopped nodes of the warm pool
+func (g *AutoScalerServerNodeGroup) WarmNodes() []*AutoScalerServerNode {
+	return utils.Values(g.warmNodes)
+}
+
+// findWarmNode return the node of the warm pool by name, nil if not found
+func (g *AutoScalerServerNodeGroup) findWarmNode(nodeName string) *AutoScalerServerNode {
+	for _, node := range g.warmNodes {
+		if node.InstanceName == nodeName || node.NodeName == nodeName {
+			return node
+		}
+	}
+
+	return nil
+}
+
+// takeWarmNodes remove at most count nodes from the warm pool, lowest index first
+func (g *AutoScalerServerNodeGroup) takeWarmNodes(count int) []*AutoScalerServerNode {
+	nodes := g.WarmNodes()
+
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i].NodeIndex < nodes[j].NodeIndex
+	})
+
+	if len(nodes) > count {
+		nodes = nodes[:count]
+	}
+
+	for _, node := range nodes {
+		delete(g.warmNodes, node.InstanceName)
+	}
+
+	return nodes
+}
+
+// parkWarmNode stop and cordon a joined node then keep it in the warm pool
+func (g *AutoScalerServerNodeGroup) parkWarmNode(c types.ClientGenerator, node *AutoScalerServerNode) error {
+	if err := node.stopVM(c); err != nil {
+		return err
+	}
+
+	if err := c.AnnoteNode(node.NodeName, map[string]string{
+		constantes.AnnotationWarmPool: strconv.FormatBool(true),
+	}); err != nil {
+		glog.Errorf(constantes.ErrAnnoteNodeReturnError, node.NodeName, err)
+	}
+
+	if g.warmNodes == nil {
+		g.warmNodes = make(map[string]*AutoScalerServerNode)
+	}
+
+	g.warmNodes[node.InstanceName] = node
+	g.RunningNodes[node.NodeIndex] = ServerNodeStateRunning
+
+	glog.Infof("Node:%s parked in warm pool of nodegroup:%s", node.InstanceName, g.NodeGroupIdentifier)
+
+	return nil
+}
+
+// startWarmNode start a node taken from the warm pool, startVM uncordon it
+func (g *AutoScalerServerNodeGroup) startWarmNode(c types.ClientGenerator, node *AutoScalerServerNode) error {
+	if err := node.startVM(c); err != nil {
+		return err
+	}
+
+	if err := c.AnnoteNode(node.NodeName, map[string]string{
+		constantes.AnnotationWarmPool: strconv.FormatBool(false),
+	}); err != nil {
+		glog.Errorf(constantes.ErrAnnoteNodeReturnError, node.NodeName, err)
+	}
+
+	glog.Infof("Node:%s started from warm pool of nodegroup:%s", node.InstanceName, g.NodeGroupIdentifier)
+
+	return nil
+}
+
+// returnToWarmPool drain and stop the node instead of terminating it while the warm pool is not full.
+// Spot instances can't be stopped and are never returned to the pool
+func (g *AutoScalerServerNodeGroup) returnToWarmPool(c types.ClientGenerator, node *AutoScalerServerNode) bool {
+	warmPool := g.getWarmPool()
+
+	if warmPool == nil || len(g.warmNodes) >= warmPool.GetMaxSize() {
+		return false
+	}
+
+	// Retired nodes are terminated before their scheduled event
+	if node.NodeType != AutoScalerServerNodeAutoscaled || node.SpotInstance || node.runningInstance == nil || len(node.ScheduledEvent) > 0 {
+		return false
+	}
+
+	if err := c.CordonNode(node.NodeName); err != nil {
+		glog.Errorf(constantes.ErrCordonNodeReturnError, node.NodeName, err)
+	}
+
+	if err := c.DrainNode(node.NodeName, true, true); err != nil {
+		glog.Errorf(constantes.ErrDrainNodeReturnError, node.NodeName, err)
+	}
+
+	if err := g.parkWarmNode(c, node); err != nil {
+		glog.Warnf(constantes.WarnUnableToReturnToWarmPool, node.InstanceName, err)
+
+		return false
+	}
+
+	g.removeNamedNode(node.InstanceName)
+	g.numOfProvisionnedNodes--
+
+	return true
+}
+
+// isSpot return true if the nodes of the group are launched on the spot market
+func (g *AutoScalerServerNodeGroup) isSpot() bool {
+	if machine, found := g.configuration.Machines[g.InstanceType]; found && machine.Spot != nil {
+		return machine.Spot.IsSpot()
+	}
+
+	return g.configuration.GetAwsConfiguration(g.NodeGroupIdentifier).Spot.IsSpot()
+}
+
+// prepareWarmNodes reserve the nodes missing in the warm pool, warm nodes count against MaxNodeSize.
+// Spot instances can't be stopped, the pool is never filled with them
+func (g *AutoScalerServerNodeGroup) prepareWarmNodes(warmPool *aws.WarmPoolOptions) []*AutoScalerServerNode {
+	g.Lock()
+	defer g.Unlock()
+
+	if len(g.fillingWarmNodes) > 0 || warmPool.MinSize <= len(g.warmNodes) {
+		return nil
+	}
+
+	if g.isSpot() {
+		glog.Warnf(constantes.WarnWarmPoolSpot, g.NodeGroupIdentifier)
+
+		return nil
+	}
+
+	missing := warmPool.MinSize - len(g.warmNodes)
+
+	if room := g.MaxNodeSize - g.targetSize() - len(g.warmNodes); room < missing {
+		missing = room
+	}
+
+	if g.fillingWarmNodes == nil {
+		g.fillingWarmNodes = make(map[string]*AutoScalerServerNode)
+	}
+
+	awsConfig := g.configuration.GetAwsConfiguration(g.NodeGroupIdentifier)
+	nodes := make([]*AutoScalerServerNode, 0, utils.MaxInt(missing, 0))
+
+	for ; missing > 0; missing-- {
+		nodeName, nodeIndex := g.nodeName(g.findNextNodeIndex(false), false, false)
+
+		g.RunningNodes[nodeIndex] = ServerNodeStateCreating
+
+		node := g.newAutoscaledNode(nodeName, nodeIndex, awsConfig)
+
+		// Reserve the name until the node is parked
+		g.fillingWarmNodes[nodeName] = node
+
+		nodes = append(nodes, node)
+	}
+
+	return nodes
+}
+
+// launchWarmNodes launch and join the reserved nodes then park them, the lock is only held to park them
+func (g *AutoScalerServerNodeGroup) launchWarmNodes(c types.ClientGenerator, nodes []*AutoScalerServerNode) error {
+	var err error
+
+	for index, node := range nodes {
+		// Status is written under lock by deleteNodeGroup
+		g.Lock()
+		status := g.Status
+		g.Unlock()
+
+		if status != NodegroupCreated {
+			err = fmt.Errorf(constantes.ErrUnableToLaunchVMNodeGroupNotReady, node.InstanceName)
+		} else if err = node.launchVM(c, g.NodeLabels, g.SystemLabels); err != nil {
+			node.cleanOnLaunchError(c, err)
+		}
+
+		g.Lock()
+
+		delete(g.fillingWarmNodes, node.InstanceName)
+
+		if err == nil {
+			if err = g.parkWarmNode(c, node); err != nil {
+				if e := g.destroyWarmNode(c, node); e != nil {
+					glog.Errorf(constantes.ErrUnableToDeleteVM, node.InstanceName, e)
+				}
+			}
+		}
+
+		if err != nil {
+			// Release the node and the remaining reserved nodes
+			for _, reserved := range nodes[index:] {
+				delete(g.fillingWarmNodes, reserved.InstanceName)
+
+				if g.RunningNodes[reserved.NodeIndex] == ServerNodeStateCreating {
+					g.RunningNodes[reserved.NodeIndex] = ServerNodeStateDeleted
+				}
+			}
+		}
+
+		g.Unlock()
+
+		if err != nil {
+			return fmt.Errorf(constantes.ErrUnableToFillWarmPool, g.NodeGroupIdentifier, err)
+		}
+	}
+
+	return nil
+}
+
+// fillWarmPool launch and join nodes in background, then stop them until the warm pool holds MinSize nodes
+func (g *AutoScalerServerNodeGroup) fillWarmPool(c types.ClientGenerator) {
+	warmPool := g.getWarmPool()
+
+	if warmPool == nil || g.Status != NodegroupCreated {
+		return
+	}
+
+	nodes := g.prepareWarmNodes(warmPool)
+
+	if len(nodes) == 0 {
+		return
+	}
+
+	glog.Infof("Fill warm pool of nodegroup:%s with %d nodes", g.NodeGroupIdentifier, len(nodes))
+
+	// Cleanup wait the pending launches
+	g.pendingNodesWG.Add(1)
+
+	go func() {
+		defer g.pendingNodesWG.Done()
+
+		// Not fatal, the pool is filled again on next auto provision
+		if err := g.launchWarmNodes(c, nodes); err != nil {
+			glog.Errorf(err.Error())
+		}
+	}()
+}
+
+// destroyWarmNode terminate the stopped node and delete the kubernetes node
+func (g *AutoScalerServerNodeGroup) destroyWarmNode(c types.ClientGenerator, node *AutoScalerServerNode) error {
+	delete(g.warmNodes, node.InstanceName)
+
+	g.RunningNodes[node.NodeIndex] = ServerNodeStateDeleted
+
+	// deleteVM delete the kubernetes node only when the instance is running
+	if err := c.DeleteNode(node.NodeName); err != nil {
+		glog.Errorf(constantes.ErrDeleteNodeReturnError, node.NodeName, err)
+	}
+
+	return node.deleteVM(c)
+}
+
+// destroyWarmNodes terminate all nodes of the warm pool
+func (g *AutoScalerServerNodeGroup) destroyWarmNodes(c types.ClientGenerator) {
+	for _, node := range g.WarmNodes() {
+		if err := g.destroyWarmNode(c, node); err != nil {
+			glog.Errorf(constantes.ErrNodeGroupCleanupFailOnVM, g.NodeGroupIdentifier, node.InstanceName, err)
+		}
+	}
+}
@@ -20,10 +20,13 @@ import (
 )
 
 const (
-	DefaultMaxGracePeriod    time.Duration = 120 * time.Second
-	DefaultMaxRequestTimeout time.Duration = 120 * time.Second
-	DefaultMaxDeletionPeriod time.Duration = 300 * time.Second
-	DefaultNodeReadyTimeout  time.Duration = 300 * time.Second
+	DefaultMaxGracePeriod          time.Duration = 120 * time.Second
+	DefaultMaxRequestTimeout       time.Duration = 120 * time.Second
+	DefaultMaxDeletionPeriod       time.Duration = 300 * time.Second
+	DefaultNodeReadyTimeout        time.Duration = 300 * time.Second
+	DefaultOrphanCollectorInterval time.Duration = 600 * time.Second
+	DefaultOrphanGracePeriod       time.Duration = 900 * time.Second
+	DefaultScheduledEventsInterval time.Duration = 300 * time.Second
 )
 
 const (
@@ -114,6 +117,7 @@ type ClientGenerator interface {
 	LabelNode(nodeName string, labels map[string]string) error
 	TaintNode(nodeName string, taints ...apiv1.Taint) error
 	WaitNodeToBeReady(nodeName string) error
+	RecordNodeEvent(nodeName, eventType, reason, message string) error
 }
 
 // ResourceLimiter define limit, not really used
@@ -124,11 +128,19 @@ type ResourceLimiter struct {
 
 // MachineCharacteristic defines VM kind
 type MachineCharacteristic struct {
-	Price    float64 `json:"price"`                  // VM price in USD
-	Memory   int     `json:"memsize"`                // VM Memory size in megabytes
-	Vcpu     int     `json:"vcpus"`                  // VM number of cpus
-	DiskType string  `default:"gp2" json:"diskType"` // VM disk size type gp2, gp3.....
-	DiskSize int     `json:"diskSize"`               // VM disk size in megabytes
+	Price           float64           `json:"price"`                     // VM price in USD
+	SpotPrice       float64           `json:"spotPrice,omitempty"`       // VM spot price in USD
+	Memory          int               `json:"memsize"`                   // VM Memory size in megabytes
+	Vcpu            int               `json:"vcpus"`                     // VM number of cpus
+	DiskType        string            `default:"gp2" json:"diskType"`    // VM disk size type gp2, gp3.....
+	DiskSize        int               `json:"diskSize"`                  // VM disk size in megabytes
+	Spot            *aws.SpotOptions  `json:"spot,omitempty"`            // VM spot options, override node group spot options
+	BlockDevices    []aws.BlockDevice `json:"blockDevices,omitempty"`    // VM EBS volumes, override node group volumes
+	Architectures   []string          `json:"architectures,omitempty"`   // VM supported architectures, x86_64, arm64...
+	GPU             int               `json:"gpus,omitempty"`            // VM number of gpus
+	MaxENI          int               `json:"maxENI,omitempty"`          // VM max network interfaces
+	MaxIPv4PerENI   int               `json:"maxIPv4PerENI,omitempty"`   // VM max ipv4 addresses per network interface
+	InstanceStorage int               `json:"instanceStorage,omitempty"` // VM instance store size in gigabytes
 }
 
 // KubeJoinConfig give element to join kube master
@@ -193,6 +205,48 @@ func (ssh *AutoScalerServerSSH) GetAuthKeys() string {
 	return ssh.AuthKeys
 }
 
+// OrphanCollectorOptions declare the periodic pass terminating instances tagged with a node group but unknown by the autoscaler.
+// Interval and grace period are in seconds, orphans are only logged in report only mode
+type OrphanCollectorOptions struct {
+	Enabled     bool          `json:"enabled"`
+	Interval    time.Duration `json:"interval,omitempty"`
+	GracePeriod time.Duration `json:"gracePeriod,omitempty"`
+	ReportOnly  bool          `json:"reportOnly,omitempty"`
+}
+
+// GetInterval return the time between two passes, default 10 minutes
+func (options *OrphanCollectorOptions) GetInterval() time.Duration {
+	if options.Interval <= 0 {
+		return DefaultOrphanCollectorInterval
+	}
+
+	return options.Interval * time.Second
+}
+
+// GetGracePeriod return the age of an instance before it could be an orphan, default 15 minutes
+func (options *OrphanCollectorOptions) GetGracePeriod() time.Duration {
+	if options.GracePeriod <= 0 {
+		return DefaultOrphanGracePeriod
+	}
+
+	return options.GracePeriod * time.Second
+}
+
+// ScheduledEventsOptions declare the periodic check of scheduled events of instances, interval is in seconds
+type ScheduledEventsOptions struct {
+	Enabled  bool          `json:"enabled"`
+	Interval time.Duration `json:"interval,omitempty"`
+}
+
+// GetInterval return the time between two checks, default 5 minutes
+func (options *ScheduledEventsOptions) GetInterval() time.Duration {
+	if options.Interval <= 0 {
+		return DefaultScheduledEventsInterval
+	}
+
+	return options.Interval * time.Second
+}
+
 type NodeGroupAutoscalingOptions struct {
 	// ScaleDownUtilizationThreshold sets threshold for nodes to be considered for scale down
 	// if cpu or memory utilization is over threshold.
@@ -241,10 +295,14 @@ type AutoScalerServerConfig struct {
 	DefaultMachineType         string                            `default:"standard" json:"default-machine"`
 	NodeLabels                 KubernetesLabel                   `json:"nodeLabels"`
 	Machines                   map[string]*MachineCharacteristic `default:"{\"standard\": {}}" json:"machines"` // Mandatory, Available machines
+	MachinesDiscovery          *aws.MachineTypesDiscovery        `json:"machines-discovery,omitempty"`          // Optional, discover machines with DescribeInstanceTypes
+	Pricing                    *aws.PricingOptions               `json:"pricing,omitempty"`                     // Optional, price nodes and pods from a price catalog
 	Optionals                  *AutoScalerServerOptionals        `json:"optionals"`
 	ManagedNodeResourceLimiter *ResourceLimiter                  `json:"managednodes-limits"`
 	SSH                        *AutoScalerServerSSH              `json:"ssh-infos"`
 	AutoScalingOptions         *NodeGroupAutoscalingOptions      `json:"autoscaling-options,omitempty"`
+	OrphanCollector            *OrphanCollectorOptions           `json:"orphan-collector,omitempty"` // Optional, terminate instances of node groups without node
+	ScheduledEvents            *ScheduledEventsOptions           `json:"scheduled-events,omitempty"` // Optional, replace nodes with upcoming scheduled events
 	CloudProvider              string                            `json:"cloud-provider"`
 	AwsInfos                   map[string]*aws.Configuration     `json:"aws"`
 	DebugMode                  *bool                             `json:"debug,omitempty"`
@@ -351,6 +409,42 @@ func (conf *AutoScalerServerConfig) GetAwsConfiguration(name string) *aws.Config
 	return aws
 }
 
+// MergeMachineTypes add discovered machine types to machines, declared machines keep their values and get missing characteristics
+func (conf *AutoScalerServerConfig) MergeMachineTypes(machineTypes map[string]*aws.MachineType) {
+	awsConf := conf.GetAwsConfiguration("default")
+
+	if conf.Machines == nil {
+		conf.Machines = make(map[string]*MachineCharacteristic)
+	}
+
+	for name, machineType := range machineTypes {
+		machine, found := conf.Machines[name]
+
+		if !found {
+			machine = &MachineCharacteristic{
+				DiskType: awsConf.DiskType,
+				DiskSize: awsConf.DiskSize,
+			}
+
+			conf.Machines[name] = machine
+		}
+
+		if machine.Memory == 0 {
+			machine.Memory = machineType.Memory
+		}
+
+		if machine.Vcpu == 0 {
+			machine.Vcpu = machineType.Vcpu
+		}
+
+		machine.Architectures = machineType.Architectures
+		machine.GPU = machineType.GPU
+		machine.MaxENI = machineType.MaxENI
+		machine.MaxIPv4PerENI = machineType.MaxIPv4PerENI
+		machine.InstanceStorage = machineType.InstanceStorage
+	}
+}
+
 // NewConfig returns new Config object
 func NewConfig() *Config {
 	return &Config{
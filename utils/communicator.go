@@ -1,10 +1,14 @@
 package utils
 
 import (
+	"archive/tar"
 	"bytes"
+	"compress/gzip"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"strings"
 	"time"
 
@@ -81,6 +85,64 @@ func Scp(connect *types.AutoScalerServerSSH, host, src, dst string) error {
 		fmt.Sprintf("%s@%s:%s", connect.GetUserName(), host, dst))
 }
 
+// ArchiveDir return the content of the directory as gzipped tar, paths are relative to the directory
+func ArchiveDir(dir string) ([]byte, error) {
+	var buffer bytes.Buffer
+
+	zw := gzip.NewWriter(&buffer)
+	tw := tar.NewWriter(zw)
+
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		name, err := filepath.Rel(dir, path)
+
+		if err != nil || name == "." {
+			return err
+		}
+
+		header, err := tar.FileInfoHeader(info, "")
+
+		if err != nil {
+			return err
+		}
+
+		header.Name = filepath.ToSlash(name)
+
+		if err = tw.WriteHeader(header); err != nil || !info.Mode().IsRegular() {
+			return err
+		}
+
+		file, err := os.Open(path)
+
+		if err != nil {
+			return err
+		}
+
+		defer file.Close()
+
+		_, err = io.Copy(tw, file)
+
+		return err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err = tw.Close(); err != nil {
+		return nil, err
+	}
+
+	if err = zw.Close(); err != nil {
+		return nil, err
+	}
+
+	return buffer.Bytes(), nil
+}
+
 // Sudo exec ssh command as sudo
 func Sudo(connect *types.AutoScalerServerSSH, host string, timeoutInSeconds time.Duration, command ...string) (string, error) {
 	var sshConfig *ssh.ClientConfig
@@ -44,6 +44,15 @@ func NodeFromJSON(s string) (*apiv1.Node, error) {
 	return data, err
 }
 
+// PodFromJSON deserialize a string to apiv1.Pod
+func PodFromJSON(s string) (*apiv1.Pod, error) {
+	data := &apiv1.Pod{}
+
+	err := json.Unmarshal([]byte(s), &data)
+
+	return data, err
+}
+
 // ToYAML serialize interface to yaml
 func ToYAML(v interface{}) string {
 	if v == nil {